@@ -1,13 +1,16 @@
 package client
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -36,6 +39,10 @@ var (
 )
 
 func (c *Client) post(url string, payload interface{}) (*http.Response, error) {
+	return c.postWithHeader(url, payload, nil)
+}
+
+func (c *Client) postWithHeader(url string, payload interface{}, header http.Header) (*http.Response, error) {
 	var payloadBytes []byte
 	if payload != nil {
 		b, err := json.Marshal(payload)
@@ -51,6 +58,9 @@ func (c *Client) post(url string, payload interface{}) (*http.Response, error) {
 	}
 
 	c.setHeaders(req)
+	for k, v := range header {
+		req.Header[k] = v
+	}
 
 	resp, err := c.HttpClient.Do(req)
 	if err != nil {
@@ -65,12 +75,19 @@ func (c *Client) post(url string, payload interface{}) (*http.Response, error) {
 }
 
 func (c *Client) get(url string, values url.Values) (*http.Response, error) {
+	return c.getWithHeader(url, values, nil)
+}
+
+func (c *Client) getWithHeader(url string, values url.Values, header http.Header) (*http.Response, error) {
 	req, err := http.NewRequest("GET", c.Address+url, nil)
 	if err != nil {
 		return nil, err
 	}
 
 	c.setHeaders(req)
+	for k, v := range header {
+		req.Header[k] = v
+	}
 
 	if values != nil {
 		q := req.URL.Query()
@@ -130,7 +147,7 @@ func (c *Client) setHeaders(req *http.Request) {
 }
 
 func (c *Client) checkStatusCode(resp *http.Response) error {
-	if resp.StatusCode != http.StatusOK {
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
 		defer resp.Body.Close()
 
 		ret := &structs.ErrorResponse{}
@@ -174,6 +191,23 @@ func (c *Client) PushJob(payload *structs.PushJobRequest) (*structs.Job, error)
 	return ret, nil
 }
 
+// PushJobAndWait pushes a new job and waits for it to finish up to the wait.
+// If the wait passed, the returned job has not finished yet.
+func (c *Client) PushJobAndWait(payload *structs.PushJobRequest, wait time.Duration) (*structs.Job, error) {
+	resp, err := c.post("/job?wait="+url.QueryEscape(wait.String()), payload)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	ret := &structs.Job{}
+	if err := respUnmarshal(resp, ret); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
 func (c *Client) GetJob(id uint64) (*structs.Job, error) {
 	resp, err := c.get(fmt.Sprintf("/job/%d", id), nil)
 	if err != nil {
@@ -189,6 +223,92 @@ func (c *Client) GetJob(id uint64) (*structs.Job, error) {
 	return ret, nil
 }
 
+// WaitJob blocks until the job finishes or the timeout passes, and returns the job.
+// If the timeout passed, the returned job has not finished yet.
+func (c *Client) WaitJob(id uint64, timeout time.Duration) (*structs.Job, error) {
+	var values url.Values = url.Values{}
+
+	if timeout > 0 {
+		values.Add("timeout", timeout.String())
+	}
+
+	resp, err := c.get(fmt.Sprintf("/job/%d/wait", id), values)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	ret := &structs.Job{}
+	if err := respUnmarshal(resp, ret); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// Events streams the events to the fn until the stream ends or the fn returns an error.
+// If the lastEventID is not 0, the stream resumes after the event of the id.
+// It returns the id of the last received event, so that the caller can resume the stream with it.
+func (c *Client) Events(payload *structs.EventsRequest, lastEventID uint64, fn func(*structs.Event) error) (uint64, error) {
+	var values url.Values = url.Values{}
+
+	if payload.Name != "" {
+		values.Add("name", payload.Name)
+	}
+
+	if payload.Status != "" {
+		values.Add("status", payload.Status)
+	}
+
+	if payload.ID != 0 {
+		values.Add("id", fmt.Sprintf("%d", payload.ID))
+	}
+
+	header := http.Header{}
+	if lastEventID != 0 {
+		header.Set("Last-Event-ID", fmt.Sprintf("%d", lastEventID))
+	}
+
+	resp, err := c.getWithHeader("/events", values, header)
+	if err != nil {
+		return lastEventID, err
+	}
+	defer resp.Body.Close()
+
+	// The data of an event is the json of structs.Event. The other fields are not needed.
+	r := bufio.NewReader(resp.Body)
+	data := ""
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			if err == io.EOF {
+				return lastEventID, nil
+			}
+			return lastEventID, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		if strings.HasPrefix(line, "data:") {
+			data += strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " ")
+			continue
+		}
+		if line != "" || data == "" {
+			continue
+		}
+
+		ev := &structs.Event{}
+		if err := json.Unmarshal([]byte(data), ev); err != nil {
+			return lastEventID, errors.Wrap(err, "failed to decode the event")
+		}
+		data = ""
+		lastEventID = ev.ID
+
+		if err := fn(ev); err != nil {
+			return lastEventID, err
+		}
+	}
+}
+
 func (c *Client) DeleteJob(id uint64) (*structs.DeletedJob, error) {
 	resp, err := c.delete(fmt.Sprintf("/job/%d", id), nil)
 	if err != nil {
@@ -234,6 +354,84 @@ func (c *Client) StopJob(id uint64) (*structs.Job, error) {
 	return ret, nil
 }
 
+func (c *Client) CompleteJob(id uint64, token string, payload *structs.CompleteJobRequest) (*structs.Job, error) {
+	resp, err := c.postWithHeader(fmt.Sprintf("/job/%d/complete", id), payload, callbackTokenHeader(token))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	ret := &structs.Job{}
+	if err := respUnmarshal(resp, ret); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (c *Client) HeartbeatJob(id uint64, token string) (*structs.Job, error) {
+	resp, err := c.postWithHeader(fmt.Sprintf("/job/%d/heartbeat", id), nil, callbackTokenHeader(token))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	ret := &structs.Job{}
+	if err := respUnmarshal(resp, ret); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (c *Client) ProgressJob(id uint64, token string, payload *structs.ProgressJobRequest) (*structs.Job, error) {
+	resp, err := c.postWithHeader(fmt.Sprintf("/job/%d/progress", id), payload, callbackTokenHeader(token))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	ret := &structs.Job{}
+	if err := respUnmarshal(resp, ret); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (c *Client) GetJobLog(id uint64, payload *structs.GetJobLogRequest) (*structs.JobLog, error) {
+	var values url.Values = url.Values{}
+
+	if payload.After != 0 {
+		values.Add("after", fmt.Sprintf("%d", payload.After))
+	}
+
+	if payload.Limit != 0 {
+		values.Add("limit", fmt.Sprintf("%d", payload.Limit))
+	}
+
+	resp, err := c.get(fmt.Sprintf("/job/%d/log", id), values)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	ret := &structs.JobLog{
+		Lines: []*structs.JobLogLine{},
+	}
+	if err := respUnmarshal(resp, ret); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func callbackTokenHeader(token string) http.Header {
+	header := http.Header{}
+	header.Set("X-Hq-Callback-Token", token)
+	return header
+}
+
 func (c *Client) ListJobs(payload *structs.ListJobsRequest) (*structs.JobList, error) {
 	var values url.Values = url.Values{}
 
@@ -288,6 +486,233 @@ func (c *Client) Stats() (*structs.Stats, error) {
 	return ret, nil
 }
 
+func (c *Client) ListBreakers() (*structs.BreakerList, error) {
+	resp, err := c.get("/breakers", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	ret := &structs.BreakerList{}
+	if err := respUnmarshal(resp, ret); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (c *Client) CreateBatch(payload *structs.CreateBatchRequest) (*structs.Batch, error) {
+	resp, err := c.post("/batch", payload)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	ret := &structs.Batch{}
+	if err := respUnmarshal(resp, ret); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (c *Client) GetBatch(id uint64) (*structs.Batch, error) {
+	resp, err := c.get(fmt.Sprintf("/batch/%d", id), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	ret := &structs.Batch{}
+	if err := respUnmarshal(resp, ret); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (c *Client) Pause(payload *structs.PauseRequest) (*structs.PauseState, error) {
+	resp, err := c.post("/pause", payload)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	ret := &structs.PauseState{}
+	if err := respUnmarshal(resp, ret); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (c *Client) Resume(payload *structs.PauseRequest) (*structs.PauseState, error) {
+	resp, err := c.post("/resume", payload)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	ret := &structs.PauseState{}
+	if err := respUnmarshal(resp, ret); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (c *Client) Drain(payload *structs.DrainRequest) (*structs.DrainState, error) {
+	resp, err := c.post("/drain", payload)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	ret := &structs.DrainState{}
+	if err := respUnmarshal(resp, ret); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (c *Client) GetDrain() (*structs.DrainState, error) {
+	resp, err := c.get("/drain", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	ret := &structs.DrainState{}
+	if err := respUnmarshal(resp, ret); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (c *Client) StopDrain() (*structs.DrainState, error) {
+	resp, err := c.delete("/drain", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	ret := &structs.DrainState{}
+	if err := respUnmarshal(resp, ret); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (c *Client) Reload() (*structs.ReloadResult, error) {
+	resp, err := c.post("/admin/reload", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	ret := &structs.ReloadResult{}
+	if err := respUnmarshal(resp, ret); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (c *Client) CreateSchedule(payload *structs.CreateScheduleRequest) (*structs.Schedule, error) {
+	resp, err := c.post("/schedule", payload)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	ret := &structs.Schedule{}
+	if err := respUnmarshal(resp, ret); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (c *Client) GetSchedule(name string) (*structs.Schedule, error) {
+	resp, err := c.get("/schedule/"+url.PathEscape(name), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	ret := &structs.Schedule{}
+	if err := respUnmarshal(resp, ret); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (c *Client) ListSchedules() (*structs.ScheduleList, error) {
+	resp, err := c.get("/schedule", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	ret := &structs.ScheduleList{
+		Schedules: []*structs.Schedule{},
+	}
+	if err := respUnmarshal(resp, ret); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (c *Client) DeleteSchedule(name string) (*structs.DeletedSchedule, error) {
+	resp, err := c.delete("/schedule/"+url.PathEscape(name), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	ret := &structs.DeletedSchedule{}
+	if err := respUnmarshal(resp, ret); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (c *Client) PauseSchedule(name string) (*structs.Schedule, error) {
+	resp, err := c.post("/schedule/"+url.PathEscape(name)+"/pause", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	ret := &structs.Schedule{}
+	if err := respUnmarshal(resp, ret); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (c *Client) ResumeSchedule(name string) (*structs.Schedule, error) {
+	resp, err := c.post("/schedule/"+url.PathEscape(name)+"/resume", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	ret := &structs.Schedule{}
+	if err := respUnmarshal(resp, ret); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
 func respUnmarshal(resp *http.Response, v interface{}) error {
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
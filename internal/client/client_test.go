@@ -6,6 +6,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -69,6 +70,62 @@ func TestClient_PushJob(t *testing.T) {
 
 }
 
+func TestClient_PushJobAndWait(t *testing.T) {
+	c := New("http://127.0.0.1:19900")
+	c.HttpClient = testHttpClient(t, func(req *http.Request) *http.Response {
+		assert.Equal(t, "/job", req.URL.Path)
+		assert.Equal(t, "30s", req.URL.Query().Get("wait"))
+
+		// the job did not finish within the wait.
+		b, _ := json.Marshal(&structs.Job{
+			ID:  1,
+			URL: "http://example.com",
+		})
+
+		return &http.Response{
+			StatusCode: http.StatusAccepted,
+			Body:       ioutil.NopCloser(bytes.NewBuffer(b)),
+			Header:     make(http.Header),
+		}
+	})
+
+	job, err := c.PushJobAndWait(&structs.PushJobRequest{
+		URL: "http://example.com",
+	}, 30*time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), job.ID)
+	assert.Nil(t, job.FinishedAt)
+}
+
+func TestClient_Events(t *testing.T) {
+	c := New("http://127.0.0.1:19900")
+	c.HttpClient = testHttpClient(t, func(req *http.Request) *http.Response {
+		assert.Equal(t, "/events", req.URL.Path)
+		assert.Equal(t, "^example$", req.URL.Query().Get("name"))
+		assert.Equal(t, "10", req.Header.Get("Last-Event-ID"))
+
+		body := ": keep-alive\n\n" +
+			"id: 11\nevent: pushed\ndata: {\"id\":\"11\",\"type\":\"pushed\",\"job\":{\"id\":\"1\",\"name\":\"example\"}}\n\n" +
+			"id: 12\nevent: started\ndata: {\"id\":\"12\",\"type\":\"started\",\"job\":{\"id\":\"1\",\"name\":\"example\"}}\n\n"
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(body)),
+			Header:     make(http.Header),
+		}
+	})
+
+	types := []string{}
+	lastEventID, err := c.Events(&structs.EventsRequest{Name: "^example$"}, 10, func(ev *structs.Event) error {
+		assert.Equal(t, uint64(1), ev.Job.ID)
+		types = append(types, ev.Type)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(12), lastEventID)
+	assert.Equal(t, []string{"pushed", "started"}, types)
+}
+
 type RoundTripFunc func(req *http.Request) *http.Response
 
 func (f RoundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
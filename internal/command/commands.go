@@ -13,13 +13,19 @@ import (
 
 var Commands = []*cli.Command{
 	DeleteCommand,
+	DrainCommand,
+	EventsCommand,
 	InfoCommand,
 	ListCommand,
+	PauseCommand,
 	PushCommand,
 	RestartCommand,
+	ResumeCommand,
+	ScheduleCommand,
 	ServeCommand,
 	StatsCommand,
 	StopCommand,
+	WaitCommand,
 }
 
 // Flags
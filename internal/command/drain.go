@@ -0,0 +1,90 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/kohkimakimoto/hq/internal/structs"
+)
+
+// drainPollInterval is the interval to check whether the server has been drained.
+var drainPollInterval = 1 * time.Second
+
+var DrainCommand = &cli.Command{
+	Name:   "drain",
+	Usage:  "Puts the server into draining mode",
+	Action: drainAction,
+	Flags: []cli.Flag{
+		addressFlag,
+		&cli.BoolFlag{
+			Name:    "wait",
+			Aliases: []string{"w"},
+			Usage:   "Wait until the server has been drained.",
+		},
+		&cli.Int64Flag{
+			Name:    "timeout",
+			Aliases: []string{"t"},
+			Usage:   "Give up waiting after `SECONDS`. 0 means no timeout.",
+		},
+		&cli.BoolFlag{
+			Name:  "cancel",
+			Usage: "Put the server back into normal mode.",
+		},
+	},
+}
+
+func drainAction(ctx *cli.Context) error {
+	c := newClient(ctx)
+
+	if ctx.Bool("cancel") {
+		state, err := c.StopDrain()
+		if err != nil {
+			return err
+		}
+		return printDrainState(ctx, state)
+	}
+
+	state, err := c.Drain(&structs.DrainRequest{})
+	if err != nil {
+		return err
+	}
+
+	if ctx.Bool("wait") {
+		var deadline time.Time
+		if timeout := ctx.Int64("timeout"); timeout > 0 {
+			deadline = time.Now().Add(time.Duration(timeout) * time.Second)
+		}
+
+		for !state.Drained {
+			if !deadline.IsZero() && time.Now().After(deadline) {
+				_ = printDrainState(ctx, state)
+				return fmt.Errorf("timed out waiting for the server to be drained")
+			}
+
+			time.Sleep(drainPollInterval)
+
+			state, err = c.GetDrain()
+			if err != nil {
+				return err
+			}
+			if !state.Draining {
+				return fmt.Errorf("the draining was canceled")
+			}
+		}
+	}
+
+	return printDrainState(ctx, state)
+}
+
+func printDrainState(ctx *cli.Context, state *structs.DrainState) error {
+	b, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintln(ctx.App.Writer, string(b))
+	return nil
+}
@@ -0,0 +1,50 @@
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kohkimakimoto/hq/internal/structs"
+)
+
+func TestDrainCommand(t *testing.T) {
+	drainPollInterval = 10 * time.Millisecond
+
+	app := testApp(t)
+	polls := 0
+	testRegisterTestClient(t, app, func(req *http.Request) *http.Response {
+		state := &structs.DrainState{Draining: true}
+		if req.Method == http.MethodGet {
+			assert.Equal(t, "/drain", req.URL.Path)
+			polls++
+			if polls >= 2 {
+				state.Drained = true
+			}
+		} else {
+			assert.Equal(t, http.MethodPost, req.Method)
+			state.NumJobsRunning = 1
+		}
+
+		b, _ := json.Marshal(state)
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(bytes.NewBuffer(b)),
+			Header:     make(http.Header),
+		}
+	})
+
+	err := app.Run([]string{"hq", "drain", "--wait"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, polls)
+
+	ret := structs.DrainState{}
+	err = json.Unmarshal(app.Writer.(*bytes.Buffer).Bytes(), &ret)
+	assert.NoError(t, err)
+	assert.True(t, ret.Drained)
+}
@@ -0,0 +1,117 @@
+package command
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/kohkimakimoto/hq/internal/structs"
+)
+
+// eventsReconnectDelay is the time to wait before reconnecting to the event stream.
+var eventsReconnectDelay = 3 * time.Second
+
+// errEventsDone stops receiving the events.
+var errEventsDone = errors.New("received the events")
+
+var EventsCommand = &cli.Command{
+	Name:    "events",
+	Aliases: []string{"tail"},
+	Usage:   `Streams the events of the jobs`,
+	Action:  eventsAction,
+	Flags: []cli.Flag{
+		addressFlag,
+		&cli.StringFlag{
+			Name:    "name",
+			Aliases: []string{"n"},
+			Usage:   "Specifies a regular expression `STRING` to filter the events with job's name",
+		},
+		&cli.StringFlag{
+			Name:    "status",
+			Aliases: []string{"s"},
+			Usage:   "Specifies `STATUS` to filter the events with job's status ('running|running-async|waiting|scheduled|blocked|canceling|failure|success|canceled|expired|unfinished|unknown')",
+		},
+		&cli.Uint64Flag{
+			Name:  "id",
+			Usage: "Only display the events of the job `ID`.",
+		},
+		&cli.Uint64Flag{
+			Name:  "since",
+			Usage: "Resume the stream after the event `ID`.",
+		},
+		&cli.IntFlag{
+			Name:  "count",
+			Usage: "Exit after displaying `N` event(s). 0 means no limit.",
+		},
+		&cli.BoolFlag{
+			Name:  "json",
+			Usage: "Display the events as JSON lines.",
+		},
+	},
+}
+
+func eventsAction(ctx *cli.Context) error {
+	c := newClient(ctx)
+
+	payload := &structs.EventsRequest{
+		Name:   ctx.String("name"),
+		Status: ctx.String("status"),
+		ID:     ctx.Uint64("id"),
+	}
+	count := ctx.Int("count")
+	asJSON := ctx.Bool("json")
+
+	n := 0
+	display := func(ev *structs.Event) error {
+		if asJSON {
+			b, err := json.Marshal(ev)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(ctx.App.Writer, string(b))
+		} else {
+			fmt.Fprintln(ctx.App.Writer, formatEvent(ev))
+		}
+
+		n++
+		if count > 0 && n >= count {
+			return errEventsDone
+		}
+		return nil
+	}
+
+	lastEventID := ctx.Uint64("since")
+	connected := false
+	for {
+		id, err := c.Events(payload, lastEventID, display)
+		if err == errEventsDone {
+			return nil
+		}
+		if err != nil && !connected && id == lastEventID {
+			// The stream could not be started. e.g. the filter is invalid.
+			return err
+		}
+		connected = true
+		lastEventID = id
+
+		// The stream ended by the server shutdown or the network error. It resumes after the last event.
+		if err != nil {
+			fmt.Fprintf(ctx.App.ErrWriter, "%v. reconnecting in %v\n", err, eventsReconnectDelay)
+		}
+		time.Sleep(eventsReconnectDelay)
+	}
+}
+
+// formatEvent formats the event as a line like "2019-10-29T23:57:08Z  finished  109440416981450752  success  example".
+func formatEvent(ev *structs.Event) string {
+	line := fmt.Sprintf("%s  %-8s  %d  %s  %s", ev.Time.Local().Format(time.RFC3339), ev.Type, ev.Job.ID, ev.Job.Status(), ev.Job.Name)
+	if ev.Type == structs.EventTypeProgress {
+		if progress := formatProgress(ev.Job.Progress); progress != "" {
+			line += "  " + progress
+		}
+	}
+	return line
+}
@@ -0,0 +1,49 @@
+package command
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventsCommand(t *testing.T) {
+	app := testApp(t)
+	status := ""
+	testRegisterTestClient(t, app, func(req *http.Request) *http.Response {
+		assert.Equal(t, "/events", req.URL.Path)
+		status = req.URL.Query().Get("status")
+
+		body := "id: 11\nevent: finished\ndata: {\"id\":\"11\",\"type\":\"finished\",\"job\":{\"id\":\"1234\",\"name\":\"example\",\"success\":true}}\n\n" +
+			"id: 12\nevent: finished\ndata: {\"id\":\"12\",\"type\":\"finished\",\"job\":{\"id\":\"1235\",\"name\":\"example\",\"success\":true}}\n\n"
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(body)),
+			Header:     make(http.Header),
+		}
+	})
+
+	err := app.Run([]string{"hq", "events", "--status", "success", "--count", "2"})
+	assert.NoError(t, err)
+	assert.Equal(t, "success", status)
+
+	b, err := ioutil.ReadAll(app.Writer.(*bytes.Buffer))
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if assert.Len(t, lines, 2) {
+		assert.True(t, strings.HasSuffix(lines[0], "  finished  1234  success  example"))
+		assert.True(t, strings.HasSuffix(lines[1], "  finished  1235  success  example"))
+	}
+
+	err = app.Run([]string{"hq", "tail", "--json", "--count", "1"})
+	assert.NoError(t, err)
+
+	b, err = ioutil.ReadAll(app.Writer.(*bytes.Buffer))
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `"type":"finished"`)
+	assert.Contains(t, string(b), `"status":"success"`)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/dustin/go-humanize"
 	"github.com/labstack/gommon/color"
@@ -49,7 +50,7 @@ var ListCommand = &cli.Command{
 		},
 		&cli.StringFlag{
 			Name:  "status, s",
-			Usage: "Specifies `STATUS` to filter the jobs with job's status ('running|waiting|canceling|failure|success|canceled|unfinished|unknown')",
+			Usage: "Specifies `STATUS` to filter the jobs with job's status ('running|running-async|waiting|scheduled|blocked|canceling|failure|success|canceled|unfinished|unknown')",
 		},
 	},
 }
@@ -105,7 +106,7 @@ func listAction(ctx *cli.Context) error {
 
 	if !quiet {
 		if detail {
-			t.AddLine("ID", "NAME", "COMMENT", "URL", "CREATED", "STARTED", "FINISHED", "DURATION", "STATUS")
+			t.AddLine("ID", "NAME", "QUEUE", "PRIORITY", "COMMENT", "URL", "CREATED", "STARTED", "FINISHED", "DURATION", "STATUS", "PROGRESS")
 		} else {
 			t.AddLine("ID", "NAME", "CREATED", "DURATION", "STATUS")
 		}
@@ -123,12 +124,18 @@ func listAction(ctx *cli.Context) error {
 			status = color.Red(status)
 		case "success":
 			status = color.Green(status)
-		case "running":
+		case "running", "running-async":
 			status = color.Cyan(status)
 		case "waiting":
 			status = color.Reset(status)
+		case "scheduled":
+			status = color.Blue(status)
+		case "blocked":
+			status = color.Magenta(status)
 		case "canceled":
 			status = color.Grey(status)
+		case "expired":
+			status = color.Grey(status)
 		case "canceling":
 			status = color.Grey(status)
 		case "unfinished":
@@ -149,11 +156,18 @@ func listAction(ctx *cli.Context) error {
 		}
 		if job.StartedAt != nil && job.FinishedAt != nil {
 			duration = fmt.Sprintf("%v", job.FinishedAt.Sub(*job.StartedAt))
+		} else if job.StartedAt != nil && (job.Running || job.RunningAsync) {
+			// the elapsed time of the running job.
+			duration = fmt.Sprintf("%v", time.Since(*job.StartedAt).Truncate(time.Second))
 		}
 
 		comment := strings.Replace(job.Comment, "\n", " ", -1)
+		queue := job.Queue
+		if queue == "" {
+			queue = "default"
+		}
 		if detail {
-			t.AddLine(job.ID, job.Name, comment, job.URL, createdAt, startedAt, finishedAt, duration, status)
+			t.AddLine(job.ID, job.Name, queue, job.Priority, comment, job.URL, createdAt, startedAt, finishedAt, duration, status, formatProgress(job.Progress))
 		} else {
 			t.AddLine(job.ID, job.Name, createdAt, duration, status)
 		}
@@ -162,3 +176,19 @@ func listAction(ctx *cli.Context) error {
 	t.Print()
 	return nil
 }
+
+// formatProgress formats the progress like "45% uploading files".
+func formatProgress(progress *structs.JobProgress) string {
+	if progress == nil {
+		return ""
+	}
+
+	ret := []string{}
+	if progress.Percent != nil {
+		ret = append(ret, fmt.Sprintf("%d%%", *progress.Percent))
+	}
+	if progress.Message != "" {
+		ret = append(ret, strings.Replace(progress.Message, "\n", " ", -1))
+	}
+	return strings.Join(ret, " ")
+}
@@ -37,3 +37,10 @@ func TestListCommand(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "1234\n1235\n1236\n", string(b))
 }
+
+func TestFormatProgress(t *testing.T) {
+	percent := 45
+	assert.Equal(t, "", formatProgress(nil))
+	assert.Equal(t, "45% uploading files", formatProgress(&structs.JobProgress{Percent: &percent, Message: "uploading files"}))
+	assert.Equal(t, "uploading", formatProgress(&structs.JobProgress{Message: "uploading"}))
+}
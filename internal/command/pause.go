@@ -0,0 +1,74 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/kohkimakimoto/hq/internal/structs"
+)
+
+var PauseCommand = &cli.Command{
+	Name:   "pause",
+	Usage:  "Pauses dispatching jobs",
+	Action: pauseAction,
+	Flags: []cli.Flag{
+		addressFlag,
+		&cli.StringFlag{
+			Name:    "name",
+			Aliases: []string{"n"},
+			Usage:   "Pause only the jobs whose names match the `REGEX`. If it is omitted, all the jobs are paused.",
+		},
+	},
+}
+
+func pauseAction(ctx *cli.Context) error {
+	c := newClient(ctx)
+
+	state, err := c.Pause(&structs.PauseRequest{
+		Name: ctx.String("name"),
+	})
+	if err != nil {
+		return err
+	}
+
+	return printPauseState(ctx, state)
+}
+
+var ResumeCommand = &cli.Command{
+	Name:   "resume",
+	Usage:  "Resumes dispatching paused jobs",
+	Action: resumeAction,
+	Flags: []cli.Flag{
+		addressFlag,
+		&cli.StringFlag{
+			Name:    "name",
+			Aliases: []string{"n"},
+			Usage:   "Resume the jobs that were paused by the `REGEX`. If it is omitted, the pause for all the jobs is resumed.",
+		},
+	},
+}
+
+func resumeAction(ctx *cli.Context) error {
+	c := newClient(ctx)
+
+	state, err := c.Resume(&structs.PauseRequest{
+		Name: ctx.String("name"),
+	})
+	if err != nil {
+		return err
+	}
+
+	return printPauseState(ctx, state)
+}
+
+func printPauseState(ctx *cli.Context, state *structs.PauseState) error {
+	b, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintln(ctx.App.Writer, string(b))
+	return nil
+}
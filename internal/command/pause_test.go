@@ -0,0 +1,59 @@
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kohkimakimoto/hq/internal/structs"
+)
+
+func TestPauseCommand(t *testing.T) {
+	app := testApp(t)
+	testRegisterTestClient(t, app, func(req *http.Request) *http.Response {
+		assert.Equal(t, "/pause", req.URL.Path)
+
+		pauseReq := &structs.PauseRequest{}
+		err := json.NewDecoder(req.Body).Decode(pauseReq)
+		assert.NoError(t, err)
+		assert.Equal(t, "^send-", pauseReq.Name)
+
+		b, _ := json.Marshal(&structs.PauseState{
+			Names: []string{"^send-"},
+		})
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(bytes.NewBuffer(b)),
+			Header:     make(http.Header),
+		}
+	})
+
+	err := app.Run([]string{"hq", "pause", "--name", "^send-"})
+	assert.NoError(t, err)
+
+	ret := structs.PauseState{}
+	err = json.Unmarshal(app.Writer.(*bytes.Buffer).Bytes(), &ret)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"^send-"}, ret.Names)
+}
+
+func TestResumeCommand(t *testing.T) {
+	app := testApp(t)
+	testRegisterTestClient(t, app, func(req *http.Request) *http.Response {
+		assert.Equal(t, "/resume", req.URL.Path)
+
+		b, _ := json.Marshal(&structs.PauseState{})
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(bytes.NewBuffer(b)),
+			Header:     make(http.Header),
+		}
+	})
+
+	err := app.Run([]string{"hq", "resume"})
+	assert.NoError(t, err)
+}
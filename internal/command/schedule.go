@@ -0,0 +1,242 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/dustin/go-humanize"
+	"github.com/labstack/gommon/color"
+	"github.com/urfave/cli/v2"
+
+	"github.com/kohkimakimoto/hq/internal/structs"
+)
+
+var ScheduleCommand = &cli.Command{
+	Name:  "schedule",
+	Usage: "Manages recurring schedules",
+	Subcommands: []*cli.Command{
+		ScheduleListCommand,
+		ScheduleAddCommand,
+		ScheduleRmCommand,
+		SchedulePauseCommand,
+		ScheduleResumeCommand,
+	},
+}
+
+var ScheduleListCommand = &cli.Command{
+	Name:      "list",
+	Usage:     "Lists schedules",
+	ArgsUsage: `[<name...>]`,
+	Action:    scheduleListAction,
+	Flags: []cli.Flag{
+		addressFlag,
+		&cli.BoolFlag{
+			Name:  "quiet, q",
+			Usage: "Only display names",
+		},
+	},
+}
+
+func scheduleListAction(ctx *cli.Context) error {
+	c := newClient(ctx)
+
+	schedules := []*structs.Schedule{}
+	if ctx.NArg() > 0 {
+		for _, name := range ctx.Args().Slice() {
+			schedule, err := c.GetSchedule(name)
+			if err != nil {
+				return err
+			}
+			schedules = append(schedules, schedule)
+		}
+	} else {
+		list, err := c.ListSchedules()
+		if err != nil {
+			return err
+		}
+		schedules = list.Schedules
+	}
+
+	quiet := ctx.Bool("quiet")
+
+	t := newTabby(ctx.App.Writer)
+	if !quiet {
+		t.AddLine("NAME", "CRON", "TIMEZONE", "URL", "LAST RUN", "NEXT RUN", "STATUS")
+	}
+
+	for _, schedule := range schedules {
+		if quiet {
+			t.AddLine(schedule.Name)
+			continue
+		}
+
+		status := color.Green("active")
+		if schedule.Paused {
+			status = color.Grey("paused")
+		}
+
+		timezone := schedule.Timezone
+		if timezone == "" {
+			timezone = "UTC"
+		}
+
+		url := ""
+		if schedule.Job != nil {
+			url = schedule.Job.URL
+		}
+
+		lastRunAt := ""
+		nextRunAt := ""
+		if schedule.LastRunAt != nil {
+			lastRunAt = humanize.Time(*schedule.LastRunAt)
+		}
+		if schedule.NextRunAt != nil {
+			nextRunAt = humanize.Time(*schedule.NextRunAt)
+		}
+
+		t.AddLine(schedule.Name, schedule.Cron, timezone, url, lastRunAt, nextRunAt, status)
+	}
+
+	t.Print()
+	return nil
+}
+
+var ScheduleAddCommand = &cli.Command{
+	Name:  "add",
+	Usage: "Adds a new schedule",
+	Description: `Adds a new schedule. If the schedule of the same name exists, it is replaced.
+If you specify '-', it reads a Schedule JSON from STDIN.`,
+	ArgsUsage: `<-|json_file...>`,
+	Action:    scheduleAddAction,
+	Flags: []cli.Flag{
+		addressFlag,
+	},
+}
+
+func scheduleAddAction(ctx *cli.Context) error {
+	if ctx.NArg() < 1 {
+		return fmt.Errorf("require one JSON file at least")
+	}
+
+	c := newClient(ctx)
+	args := ctx.Args()
+
+	for _, file := range args.Slice() {
+		var b []byte
+		var err error
+		if file == "-" {
+			b, err = ioutil.ReadAll(os.Stdin)
+		} else {
+			b, err = ioutil.ReadFile(file)
+		}
+		if err != nil {
+			return err
+		}
+
+		payload := &structs.CreateScheduleRequest{}
+		if err := json.Unmarshal(b, payload); err != nil {
+			return err
+		}
+
+		schedule, err := c.CreateSchedule(payload)
+		if err != nil {
+			return err
+		}
+
+		_, _ = fmt.Fprintln(ctx.App.Writer, schedule.Name)
+	}
+
+	return nil
+}
+
+var ScheduleRmCommand = &cli.Command{
+	Name:      "rm",
+	Usage:     "Removes schedules",
+	ArgsUsage: `<name...>`,
+	Action:    scheduleRmAction,
+	Flags: []cli.Flag{
+		addressFlag,
+	},
+}
+
+func scheduleRmAction(ctx *cli.Context) error {
+	c := newClient(ctx)
+
+	if ctx.NArg() < 1 {
+		return fmt.Errorf("require one name at least")
+	}
+
+	t := newTabby(ctx.App.Writer)
+	for _, name := range ctx.Args().Slice() {
+		schedule, err := c.DeleteSchedule(name)
+		if err != nil {
+			return err
+		}
+
+		t.AddLine(schedule.Name)
+	}
+	t.Print()
+	return nil
+}
+
+var SchedulePauseCommand = &cli.Command{
+	Name:      "pause",
+	Usage:     "Pauses schedules",
+	ArgsUsage: `<name...>`,
+	Action:    schedulePauseAction,
+	Flags: []cli.Flag{
+		addressFlag,
+	},
+}
+
+func schedulePauseAction(ctx *cli.Context) error {
+	c := newClient(ctx)
+
+	if ctx.NArg() < 1 {
+		return fmt.Errorf("require one name at least")
+	}
+
+	t := newTabby(ctx.App.Writer)
+	for _, name := range ctx.Args().Slice() {
+		schedule, err := c.PauseSchedule(name)
+		if err != nil {
+			return err
+		}
+
+		t.AddLine(schedule.Name)
+	}
+	t.Print()
+	return nil
+}
+
+var ScheduleResumeCommand = &cli.Command{
+	Name:      "resume",
+	Usage:     "Resumes paused schedules",
+	ArgsUsage: `<name...>`,
+	Action:    scheduleResumeAction,
+	Flags: []cli.Flag{
+		addressFlag,
+	},
+}
+
+func scheduleResumeAction(ctx *cli.Context) error {
+	c := newClient(ctx)
+
+	if ctx.NArg() < 1 {
+		return fmt.Errorf("require one name at least")
+	}
+
+	t := newTabby(ctx.App.Writer)
+	for _, name := range ctx.Args().Slice() {
+		schedule, err := c.ResumeSchedule(name)
+		if err != nil {
+			return err
+		}
+
+		t.AddLine(schedule.Name)
+	}
+	t.Print()
+	return nil
+}
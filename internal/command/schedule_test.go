@@ -0,0 +1,87 @@
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kohkimakimoto/hq/internal/structs"
+)
+
+func TestScheduleListCommand(t *testing.T) {
+	app := testApp(t)
+	testRegisterTestClient(t, app, func(req *http.Request) *http.Response {
+		b, _ := json.Marshal(&structs.ScheduleList{
+			Schedules: []*structs.Schedule{
+				{Name: "test1", Cron: "* * * * *"},
+				{Name: "test2", Cron: "0 3 * * *"},
+			},
+		})
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(bytes.NewBuffer(b)),
+			Header:     make(http.Header),
+		}
+	})
+
+	err := app.Run([]string{"hq", "schedule", "list", "--quiet"})
+	assert.NoError(t, err)
+
+	b, err := ioutil.ReadAll(app.Writer.(*bytes.Buffer))
+	assert.NoError(t, err)
+	assert.Equal(t, "test1\ntest2\n", string(b))
+}
+
+func TestScheduleRmCommand(t *testing.T) {
+	app := testApp(t)
+	testRegisterTestClient(t, app, func(req *http.Request) *http.Response {
+		assert.Equal(t, http.MethodDelete, req.Method)
+
+		b, _ := json.Marshal(&structs.DeletedSchedule{
+			Name: path.Base(req.URL.Path),
+		})
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(bytes.NewBuffer(b)),
+			Header:     make(http.Header),
+		}
+	})
+
+	err := app.Run([]string{"hq", "schedule", "rm", "test1", "test2"})
+	assert.NoError(t, err)
+
+	b, err := ioutil.ReadAll(app.Writer.(*bytes.Buffer))
+	assert.NoError(t, err)
+	assert.Equal(t, "test1\ntest2\n", string(b))
+}
+
+func TestSchedulePauseCommand(t *testing.T) {
+	app := testApp(t)
+	testRegisterTestClient(t, app, func(req *http.Request) *http.Response {
+		assert.Equal(t, "/schedule/test1/pause", req.URL.Path)
+
+		b, _ := json.Marshal(&structs.Schedule{
+			Name:   "test1",
+			Paused: true,
+		})
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(bytes.NewBuffer(b)),
+			Header:     make(http.Header),
+		}
+	})
+
+	err := app.Run([]string{"hq", "schedule", "pause", "test1"})
+	assert.NoError(t, err)
+
+	b, err := ioutil.ReadAll(app.Writer.(*bytes.Buffer))
+	assert.NoError(t, err)
+	assert.Equal(t, "test1\n", string(b))
+}
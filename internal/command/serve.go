@@ -20,11 +20,27 @@ var ServeCommand = &cli.Command{
 }
 
 func serverAction(ctx *cli.Context) error {
+	config, err := loadConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	// The config file is loaded again when the server reloads the config.
+	if getConfigFilePath(ctx) != "" {
+		config.Loader = func() (*server.Config, error) {
+			return loadConfig(ctx)
+		}
+	}
+
+	return server.Run(config)
+}
+
+func loadConfig(ctx *cli.Context) (*server.Config, error) {
 	config := server.NewConfig()
 	// Load config file
 	if path := getConfigFilePath(ctx); path != "" {
 		if _, err := toml.DecodeFile(path, config); err != nil {
-			return err
+			return nil, err
 		}
 	}
 
@@ -33,7 +49,7 @@ func serverAction(ctx *cli.Context) error {
 		config.LogLevelString = v
 	}
 
-	return server.Run(config)
+	return config, nil
 }
 
 func getConfigFilePath(ctx *cli.Context) string {
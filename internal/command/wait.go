@@ -0,0 +1,88 @@
+package command
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/kohkimakimoto/hq/internal/structs"
+)
+
+// waitPollTimeout is the timeout of each long-poll request to wait for a job.
+var waitPollTimeout = 60 * time.Second
+
+var WaitCommand = &cli.Command{
+	Name:      "wait",
+	Usage:     `Waits for jobs to finish`,
+	ArgsUsage: `<job_id...>`,
+	Action:    waitAction,
+	Flags: []cli.Flag{
+		addressFlag,
+		&cli.Int64Flag{
+			Name:    "timeout",
+			Aliases: []string{"t"},
+			Usage:   "Give up waiting after `SECONDS`. 0 means no timeout.",
+		},
+	},
+}
+
+func waitAction(ctx *cli.Context) error {
+	c := newClient(ctx)
+
+	if ctx.NArg() < 1 {
+		return fmt.Errorf("require one id at least")
+	}
+
+	var deadline time.Time
+	if timeout := ctx.Int64("timeout"); timeout > 0 {
+		deadline = time.Now().Add(time.Duration(timeout) * time.Second)
+	}
+
+	jobs := []*structs.Job{}
+	for _, idstr := range ctx.Args().Slice() {
+		id, err := strconv.ParseUint(idstr, 10, 64)
+		if err != nil {
+			return err
+		}
+
+		for {
+			pollTimeout := waitPollTimeout
+			if !deadline.IsZero() {
+				remaining := time.Until(deadline)
+				if remaining <= 0 {
+					return fmt.Errorf("timed out waiting for the job %d to finish", id)
+				}
+				if remaining < pollTimeout {
+					pollTimeout = remaining
+				}
+			}
+
+			job, err := c.WaitJob(id, pollTimeout)
+			if err != nil {
+				return err
+			}
+			if job.FinishedAt != nil {
+				jobs = append(jobs, job)
+				break
+			}
+		}
+	}
+
+	t := newTabby(ctx.App.Writer)
+	failed := 0
+	for _, job := range jobs {
+		status := job.Status()
+		if status != structs.JobStatusSuccess {
+			failed++
+		}
+		t.AddLine(job.ID, status)
+	}
+	t.Print()
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d jobs did not succeed", failed, len(jobs))
+	}
+	return nil
+}
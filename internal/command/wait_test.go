@@ -0,0 +1,54 @@
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kohkimakimoto/hq/internal/structs"
+)
+
+func TestWaitCommand(t *testing.T) {
+	app := testApp(t)
+	polls := 0
+	testRegisterTestClient(t, app, func(req *http.Request) *http.Response {
+		assert.Equal(t, "wait", path.Base(req.URL.Path))
+		id, err := strconv.ParseUint(path.Base(path.Dir(req.URL.Path)), 10, 64)
+		assert.NoError(t, err)
+
+		job := &structs.Job{ID: id}
+		polls++
+		// the first poll times out.
+		if polls > 1 {
+			now := time.Now()
+			job.FinishedAt = &now
+			job.Success = id != 1235
+			job.Failure = id == 1235
+		}
+
+		b, _ := json.Marshal(job)
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(bytes.NewBuffer(b)),
+			Header:     make(http.Header),
+		}
+	})
+
+	err := app.Run([]string{"hq", "wait", "1234"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, polls)
+
+	b, err := ioutil.ReadAll(app.Writer.(*bytes.Buffer))
+	assert.NoError(t, err)
+	assert.Equal(t, "1234  success\n", string(b))
+
+	err = app.Run([]string{"hq", "wait", "1234", "1235"})
+	assert.EqualError(t, err, "1 of 2 jobs did not succeed")
+}
@@ -6,7 +6,9 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sort"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -60,12 +62,53 @@ type App struct {
 	IdGen katsubushi.Generator
 	// QueueManager is a Queue manager.
 	QueueManager *QueueManager
+	// Pauses holds the jobs while dispatching them is paused.
+	Pauses *Pauses
+	// ConcurrencyKeys limits the running jobs that have the same concurrency key.
+	ConcurrencyKeys *ConcurrencyKeys
+	// HostLimiter limits the requests to the worker hosts.
+	HostLimiter *HostLimiter
+	// CircuitBreakers holds the jobs for the worker hosts that are down.
+	CircuitBreakers *CircuitBreakers
+	// Timer moves the scheduled jobs into the queue when they are due.
+	Timer *Timer
+	// Scheduler pushes the jobs of the recurring schedules.
+	Scheduler *Scheduler
 	// Store is a main database representation.
 	Store *Store
+	// JobWaiters notifies the clients that are waiting for the jobs to finish.
+	JobWaiters *JobWaiters
+	// Events publishes the lifecycle events of the jobs to the event streams.
+	Events *Events
 	// BackgroundCleaner is a background task runner to clean the stale jobs.
 	BackgroundCleaner *BackgroundCleaner
 	// Dispatchers
 	Dispatchers []*Dispatcher
+
+	// configMutex protects Config that is replaced when the config is reloaded.
+	configMutex sync.RWMutex
+	// reloadMutex serializes reloading the config.
+	reloadMutex sync.Mutex
+	// dispatchersMutex protects Dispatchers that is scaled when the config is reloaded.
+	dispatchersMutex sync.RWMutex
+	// dispatching is true after the dispatchers started.
+	dispatching bool
+	// retiredWg waits for the dispatchers that were removed by reloading the config.
+	retiredWg sync.WaitGroup
+
+	// httpClientFactory creates the http client for the requests that HQ sends besides the jobs, like the batch callbacks.
+	httpClientFactory func() *http.Client
+
+	// webhookStopCh stops waiting for the retries of the webhook deliveries on shutdown.
+	webhookStopCh chan struct{}
+	// webhookWg waits for the webhook deliveries.
+	webhookWg sync.WaitGroup
+
+	// draining is 1 while the server is in the draining mode.
+	draining int32
+
+	// dependencyMutex serializes blocking the jobs and releasing them when their parents finish.
+	dependencyMutex sync.Mutex
 }
 
 // NewApp creates a new App instance.
@@ -91,6 +134,8 @@ func NewApp(c *Config) (*App, error) {
 		Config:             c,
 		Echo:               e,
 		ShutdownTimeoutSec: c.ShutdownTimeout,
+		httpClientFactory:  defaultHttpClientFactory,
+		webhookStopCh:      make(chan struct{}),
 	}
 
 	// setup log file if it is specified.
@@ -116,6 +161,46 @@ func NewApp(c *Config) (*App, error) {
 		a.AccessLogFileWriter = reopen.Stdout
 	}
 
+	if err := validateStatusPolicy(c.StatusPolicy); err != nil {
+		return nil, errors.Wrap(err, "invalid status_policy")
+	}
+
+	if err := validateRecoverRunningJobs(c.RecoverRunningJobs); err != nil {
+		return nil, err
+	}
+
+	if c.PriorityAging < 0 {
+		return nil, fmt.Errorf("priority_aging must not be negative")
+	}
+
+	if c.CancelGracePeriod < 0 {
+		return nil, fmt.Errorf("cancel_grace_period must not be negative")
+	}
+
+	if c.HeartbeatTimeout < 0 {
+		return nil, fmt.Errorf("async_heartbeat_timeout must not be negative")
+	}
+
+	if c.EventLogSize < 0 {
+		return nil, fmt.Errorf("event_log_size must not be negative")
+	}
+
+	if err := validateNamedQueues(c.NamedQueues); err != nil {
+		return nil, errors.Wrap(err, "invalid queue")
+	}
+
+	if err := validateHostLimits(c.HostLimits); err != nil {
+		return nil, errors.Wrap(err, "invalid host_limits")
+	}
+
+	if err := validateCircuitBreaker(c.CircuitBreaker); err != nil {
+		return nil, errors.Wrap(err, "invalid circuit_breaker")
+	}
+
+	if err := validateWebhook(c.Webhook); err != nil {
+		return nil, errors.Wrap(err, "invalid webhook")
+	}
+
 	// setup ID generator
 	epoch, err := c.IDEpochTime()
 	if err != nil {
@@ -128,8 +213,34 @@ func NewApp(c *Config) (*App, error) {
 	}
 	a.IdGen = gen
 
+	// setup events. The event IDs are generated by the ID generator, so that they increase across restarts.
+	a.Events = NewEvents(int(c.EventLogSize), gen.NextID)
+
 	// setup Queue manager
 	a.QueueManager = NewQueueManager(c.Queues)
+	a.QueueManager.SetPriorityAging(time.Duration(c.PriorityAging) * time.Second)
+	for name := range c.NamedQueues {
+		a.QueueManager.AddQueue(name)
+	}
+
+	// setup pauses. They are restored after the db is opened.
+	a.Pauses = NewPauses()
+	a.QueueManager.AddGate(a.Pauses)
+
+	// setup concurrency keys
+	a.ConcurrencyKeys = NewConcurrencyKeys()
+	a.QueueManager.AddGate(a.ConcurrencyKeys)
+
+	// setup host limiter
+	a.HostLimiter = NewHostLimiter(c.HostLimits)
+	a.QueueManager.AddGate(a.HostLimiter)
+
+	// setup circuit breakers
+	a.CircuitBreakers = NewCircuitBreakers(c.CircuitBreaker.Threshold, time.Duration(c.CircuitBreaker.Cooldown)*time.Second)
+	a.QueueManager.AddGate(a.CircuitBreakers)
+
+	// setup waiters
+	a.JobWaiters = NewJobWaiters()
 
 	// setup db
 	a.Store = NewStore(c.DataDir, e.Logger, a.QueueManager)
@@ -137,19 +248,74 @@ func NewApp(c *Config) (*App, error) {
 		return nil, err
 	}
 
+	// load the persisted pauses
+	if err := a.restorePauses(); err != nil {
+		return nil, errors.Wrap(err, "failed to restore the pauses")
+	}
+
+	// load the persisted queue
+	if err := a.QueueManager.Restore(a.Store); err != nil {
+		return nil, errors.Wrap(err, "failed to restore the queue")
+	}
+
+	// setup timer
+	a.Timer = NewTimer(e.Logger, a.QueueManager, a.Store)
+	if err := a.Timer.Restore(); err != nil {
+		return nil, errors.Wrap(err, "failed to restore the scheduled jobs")
+	}
+
+	// setup scheduler
+	a.Scheduler = NewScheduler(e.Logger, a.Store, a.QueueManager, a.pushScheduledJob, a.cancelScheduledJob)
+	a.Scheduler.draining = a.isDraining
+	if err := a.Scheduler.Restore(); err != nil {
+		return nil, errors.Wrap(err, "failed to restore the schedules")
+	}
+	declared := []string{}
+	for _, sc := range c.Schedules {
+		schedule, err := sc.Schedule()
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid schedule '%s'", sc.Name)
+		}
+		if err := validateSchedule(schedule); err != nil {
+			return nil, errors.Wrapf(err, "invalid schedule '%s'", sc.Name)
+		}
+		if err := a.validateScheduleQueue(schedule); err != nil {
+			return nil, errors.Wrapf(err, "invalid schedule '%s'", sc.Name)
+		}
+		if _, err := a.Scheduler.Declare(schedule); err != nil {
+			return nil, err
+		}
+		declared = append(declared, schedule.Name)
+	}
+	pruned, err := a.Scheduler.Prune(declared)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range pruned {
+		e.Logger.Infof("schedule: %s was deleted, because it is not declared in the config file anymore", name)
+	}
+
 	// setup background
 	a.BackgroundCleaner = NewBackgroundCleaner(e.Logger, a.QueueManager, a.Store, 1*time.Minute, c.JobLifetime)
+	a.BackgroundCleaner.expire = a.expireOverdueJobs
+	a.BackgroundCleaner.failStale = a.failStaleAsyncJobs
 
 	// setup dispatchers
 	for i := int64(0); i < c.Dispatchers; i++ {
-		a.Dispatchers = append(a.Dispatchers, &Dispatcher{
-			queueManager:      a.QueueManager,
-			store:             a.Store,
-			logger:            e.Logger,
-			httpClientFactory: defaultHttpClientFactory,
-			maxWorkers:        c.MaxWorkers,
-			numWorkers:        0,
-		})
+		a.Dispatchers = append(a.Dispatchers, a.newDispatcher("", c.MaxWorkers))
+	}
+
+	// setup dispatchers for the named queues.
+	// Each dispatcher of a named queue runs a job at a time, so the number of the dispatchers is the concurrency of the queue.
+	names := make([]string, 0, len(c.NamedQueues))
+	for name := range c.NamedQueues {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for i := int64(0); i < c.NamedQueues[name].Concurrency; i++ {
+			a.Dispatchers = append(a.Dispatchers, a.newDispatcher(name, 0))
+		}
 	}
 
 	// error handler
@@ -196,9 +362,27 @@ func (a *App) Start() error {
 	// register signal handler
 	go a.signalHandler()
 
+	// recover the jobs that were left by the previous process
+	if err := a.recoverJobs(); err != nil {
+		return errors.Wrap(err, "failed to recover jobs")
+	}
+
+	// resume the webhook deliveries that were left by the previous process
+	if err := a.resumeDeliveries(); err != nil {
+		return errors.Wrap(err, "failed to resume webhook deliveries")
+	}
+
 	// start dispatchers
 	a.startDispatchers()
-	logger.Debugf("Started %d dispatcher(s)", len(a.Dispatchers))
+	logger.Debugf("Started %d dispatcher(s)", len(a.dispatchers()))
+
+	// start timer
+	a.Timer.Start()
+	logger.Debug("Started Timer thread.")
+
+	// start scheduler
+	a.Scheduler.Start()
+	logger.Debug("Started Scheduler thread.")
 
 	// start background
 	a.BackgroundCleaner.Start()
@@ -214,7 +398,7 @@ func (a *App) Start() error {
 
 	// see https://echo.labstack.com/cookbook/graceful-shutdown
 	// Wait for interrupt signal to gracefully shut down the server with a timeout of 'ShutdownTimeoutSec' seconds.
-	quit := make(chan os.Signal)
+	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	sig := <-quit
 	logger.Infof("Received signal: %v", sig)
@@ -227,14 +411,36 @@ func (a *App) Start() error {
 	// It means that the server can't run multiple processes that is needed for graceful restart.
 
 	logger.Info("Shutting down the server")
+	// The event streams do not finish by themselves, so they are closed before the http server.
+	a.Events.Close()
 	if err := e.Shutdown(ctx); err != nil {
 		return errors.Wrap(err, "failed to shut down echo http server")
 	}
 
-	// closing workers
+	// stopping scheduler
+	logger.Debug("Stopping Scheduler")
+	a.Scheduler.Stop()
+	logger.Debug("Stopped Scheduler")
+
+	// stopping timer. The scheduled jobs are persisted, so they are restored on the next startup.
+	logger.Debug("Stopping Timer")
+	a.Timer.Stop()
+	logger.Debug("Stopped Timer")
+
+	// stopping dispatchers. The jobs in the queue are persisted, so they are dispatched on the next startup.
 	logger.Info("Waiting for finishing the running jobs")
-	a.waitDispatchers()
-	logger.Info("Finished the jobs")
+	if a.stopDispatchers(timeout) {
+		logger.Info("Finished the jobs")
+	} else {
+		// The unfinished jobs are recovered on the next startup.
+		logger.Warnf("The running jobs did not finish within %v", timeout)
+	}
+
+	// stopping webhooks. The pending deliveries are resumed on the next startup.
+	logger.Debug("Stopping webhook deliveries")
+	close(a.webhookStopCh)
+	a.webhookWg.Wait()
+	logger.Debug("Stopped webhook deliveries")
 
 	// stopping background
 	logger.Debug("Stopping BackgroundCleaner")
@@ -246,18 +452,91 @@ func (a *App) Start() error {
 	return nil
 }
 
+// newDispatcher creates a dispatcher for the queue of the name. The empty name means the default queue.
+func (a *App) newDispatcher(queue string, maxWorkers int64) *Dispatcher {
+	return &Dispatcher{
+		queue:             queue,
+		queueManager:      a.QueueManager,
+		store:             a.Store,
+		timer:             a.Timer,
+		breakers:          a.CircuitBreakers,
+		started:           a.jobStarted,
+		finished:          a.jobFinished,
+		logger:            a.Echo.Logger,
+		httpClientFactory: defaultHttpClientFactory,
+		statusPolicy:      a.config().StatusPolicy,
+		cancelNotify:      a.config().CancelNotification,
+		cancelGrace:       time.Duration(a.config().CancelGracePeriod) * time.Second,
+		maxWorkers:        maxWorkers,
+		numWorkers:        0,
+		stopCh:            make(chan struct{}),
+		doneCh:            make(chan struct{}),
+	}
+}
+
 func (a *App) startDispatchers() {
+	a.dispatchersMutex.Lock()
+	defer a.dispatchersMutex.Unlock()
+
+	a.dispatching = true
 	for _, d := range a.Dispatchers {
 		go d.EventLoop()
 	}
 }
 
-func (a *App) waitDispatchers() {
-	for _, d := range a.Dispatchers {
-		d.Wait()
+// stopDispatchers stops the dispatchers taking new jobs and waits for the running workers up to the timeout.
+// It returns false if the workers did not finish within the timeout.
+func (a *App) stopDispatchers(timeout time.Duration) bool {
+	a.dispatchersMutex.Lock()
+	dispatching := a.dispatching
+	a.dispatching = false
+	dispatchers := make([]*Dispatcher, len(a.Dispatchers))
+	copy(dispatchers, a.Dispatchers)
+	a.dispatchersMutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		if dispatching {
+			var wg sync.WaitGroup
+			for _, d := range dispatchers {
+				wg.Add(1)
+				go func(d *Dispatcher) {
+					defer wg.Done()
+					d.Stop()
+				}(d)
+			}
+			wg.Wait()
+		}
+		a.retiredWg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
 	}
 }
 
+// dispatchers returns the current dispatchers.
+func (a *App) dispatchers() []*Dispatcher {
+	a.dispatchersMutex.RLock()
+	defer a.dispatchersMutex.RUnlock()
+
+	ret := make([]*Dispatcher, len(a.Dispatchers))
+	copy(ret, a.Dispatchers)
+	return ret
+}
+
+// config returns the current config.
+func (a *App) config() *Config {
+	a.configMutex.RLock()
+	defer a.configMutex.RUnlock()
+
+	return a.Config
+}
+
 func (a *App) signalHandler() {
 	logger := a.Echo.Logger
 	reopenSig := make(chan os.Signal, 1)
@@ -265,6 +544,9 @@ func (a *App) signalHandler() {
 	// It is the same behavior as nginx.
 	// see https://www.nginx.com/nginx-wiki/build/dirhtml/start/topics/examples/logrotation/
 	signal.Notify(reopenSig, syscall.SIGUSR1)
+	// HUP signal reloads the config file
+	reloadSig := make(chan os.Signal, 1)
+	signal.Notify(reloadSig, syscall.SIGHUP)
 
 	for {
 		select {
@@ -278,6 +560,12 @@ func (a *App) signalHandler() {
 			if err := a.AccessLogFileWriter.Reopen(); err != nil {
 				logger.Error(fmt.Sprintf("failed to reopen access log: %v", err))
 			}
+		case sig := <-reloadSig:
+			logger.Infof("Received signal to reload the config: %v", sig)
+
+			if _, err := a.reloadConfig(); err != nil {
+				logger.Error(fmt.Sprintf("failed to reload config: %v", err))
+			}
 		}
 	}
 }
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/kohkimakimoto/hq/internal/structs"
+)
+
+// CallbackTokenHeader is the header to send the callback token of the attempt.
+// The worker must send it back to report the progress and the result of the job.
+const CallbackTokenHeader = "X-Hq-Callback-Token"
+
+// errJobAccepted means the worker accepted the async job and reports the result later.
+var errJobAccepted = errors.New("the job was accepted to run asynchronously")
+
+// newCallbackToken generates a random token to authenticate the callback from the worker.
+func newCallbackToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// validCallbackToken reports whether the token is the callback token of the job.
+func validCallbackToken(job *structs.Job, token string) bool {
+	if job.CallbackToken == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(job.CallbackToken), []byte(token)) == 1
+}
+
+// completeAsyncJob finishes the async job with the result that is reported by the worker.
+// A failure is retried like the failure of the synchronous job.
+// It returns false if the job is not running asynchronously.
+func (a *App) completeAsyncJob(id uint64, req *structs.CompleteJobRequest) (bool, error) {
+	job := a.QueueManager.RemoveAsyncJob(id)
+	if job == nil {
+		return false, nil
+	}
+
+	job.Output = req.Output
+	if req.StatusCode != 0 {
+		statusCode := req.StatusCode
+		job.StatusCode = &statusCode
+	}
+
+	if err := asyncJobError(a.config().StatusPolicy, job, req); err != nil {
+		return true, a.failAsyncJob(job, err)
+	}
+
+	job.Success = true
+	job.Failure = false
+	job.Err = req.Err
+	return true, a.finishAsyncJob(job)
+}
+
+// asyncJobError returns the error of the attempt that is reported by the worker.
+// If the worker reports the status code, it is classified by the status policy like the response of the synchronous job.
+func asyncJobError(policy *structs.StatusPolicy, job *structs.Job, req *structs.CompleteJobRequest) error {
+	if req.StatusCode != 0 {
+		switch classifyStatusCode(mergeStatusPolicy(policy, job.StatusPolicy), req.StatusCode) {
+		case statusClassSuccess:
+			return nil
+		case statusClassRetryable:
+			return &StatusCodeError{
+				StatusCode: req.StatusCode,
+				Retryable:  true,
+			}
+		default:
+			return &StatusCodeError{
+				StatusCode: req.StatusCode,
+				Retryable:  false,
+			}
+		}
+	}
+
+	if req.Success {
+		return nil
+	}
+	if req.Err != "" {
+		return errors.New(req.Err)
+	}
+	return errors.New("the worker reported the failure")
+}
+
+// failAsyncJob retries the failed async job, or finishes it as a failure if it can not be retried.
+func (a *App) failAsyncJob(job *structs.Job, err error) error {
+	job.Err = err.Error()
+
+	if !shouldRetry(job, err) {
+		job.Success = false
+		job.Failure = true
+		return a.finishAsyncJob(job)
+	}
+
+	// The job is not finished yet. It releases the gates while waiting for the next attempt.
+	a.QueueManager.ReleaseJob(job)
+	job.StartedAt = nil
+
+	delay := retryDelay(job, err)
+	a.Echo.Logger.Infof("job: %d failed on attempt %d. retrying in %v", job.ID, job.Attempt, delay)
+	return retryJob(a.Store, a.QueueManager, a.Timer, job, delay)
+}
+
+// cancelAsyncJob cancels the async job immediately, and notifies the worker of the cancellation.
+func (a *App) cancelAsyncJob(id uint64) error {
+	job := a.QueueManager.RemoveAsyncJob(id)
+	if job == nil {
+		return nil
+	}
+
+	if job.CancelURL != "" || a.config().CancelNotification {
+		go notifyCancel(a.Echo.Logger, a.httpClientFactory(), job)
+	}
+
+	job.Canceled = true
+	return a.finishAsyncJob(job)
+}
+
+// failStaleAsyncJobs fails the async jobs whose workers have not reported back within the heartbeat timeout.
+func (a *App) failStaleAsyncJobs(now time.Time) {
+	timeout := a.config().HeartbeatTimeout
+	if timeout <= 0 {
+		return
+	}
+
+	for _, job := range a.QueueManager.RemoveStaleAsyncJobs(now.Add(-time.Duration(timeout) * time.Second)) {
+		a.Echo.Logger.Infof("job: %d timed out waiting for the callback", job.ID)
+		if err := a.failAsyncJob(job, fmt.Errorf("the worker did not report back within %d seconds", timeout)); err != nil {
+			a.Echo.Logger.Error(err)
+		}
+	}
+}
+
+// finishAsyncJob finishes the job that was removed from the async jobs.
+func (a *App) finishAsyncJob(job *structs.Job) error {
+	a.QueueManager.ReleaseJob(job)
+
+	// Truncate millisecond. It is compatible time for katsubushi ID generator timestamp.
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	job.FinishedAt = &now
+	if err := a.Store.UpdateJob(job); err != nil {
+		return err
+	}
+
+	a.jobFinished(job)
+	return nil
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kohkimakimoto/hq/internal/structs"
+)
+
+// testAsyncJob stores the job that the worker accepted to run asynchronously.
+func testAsyncJob(t *testing.T, id uint64) *structs.Job {
+	t.Helper()
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	job := &structs.Job{
+		ID:            id,
+		URL:           "http://example.com",
+		Async:         true,
+		CallbackToken: "token",
+		Attempt:       1,
+		StartedAt:     &now,
+		AcceptedAt:    &now,
+		HeartbeatAt:   &now,
+	}
+	assert.NoError(t, g.Store.CreateJob(job))
+	g.QueueManager.RegisterAsyncJob(job)
+	return job
+}
+
+func TestApp_CompleteAsyncJob(t *testing.T) {
+	testInitApp(t)
+
+	testAsyncJob(t, 1)
+
+	job, err := g.Store.GetJob(1)
+	assert.NoError(t, err)
+	assert.Equal(t, structs.JobStatusRunningAsync, job.Status())
+
+	completed, err := g.completeAsyncJob(1, &structs.CompleteJobRequest{Success: true, Output: "done"})
+	assert.NoError(t, err)
+	assert.True(t, completed)
+
+	job, err = g.Store.GetJob(1)
+	assert.NoError(t, err)
+	assert.Equal(t, structs.JobStatusSuccess, job.Status())
+	assert.Equal(t, "done", job.Output)
+	assert.NotNil(t, job.FinishedAt)
+
+	// the job can be completed only once.
+	completed, err = g.completeAsyncJob(1, &structs.CompleteJobRequest{Success: false})
+	assert.NoError(t, err)
+	assert.False(t, completed)
+}
+
+func TestApp_FailStaleAsyncJobs(t *testing.T) {
+	testInitApp(t)
+
+	testAsyncJob(t, 1)
+	testAsyncJob(t, 2)
+
+	now := time.Now().Add(time.Duration(g.config().HeartbeatTimeout) * time.Second)
+	assert.True(t, g.QueueManager.HeartbeatAsyncJob(2, now))
+
+	g.failStaleAsyncJobs(now.Add(1 * time.Second))
+
+	job, err := g.Store.GetJob(1)
+	assert.NoError(t, err)
+	assert.Equal(t, structs.JobStatusFailure, job.Status())
+	assert.Contains(t, job.Err, "did not report back")
+
+	// the job that sent the heartbeat is still running.
+	job, err = g.Store.GetJob(2)
+	assert.NoError(t, err)
+	assert.Equal(t, structs.JobStatusRunningAsync, job.Status())
+}
+
+func TestApp_CancelAsyncJob(t *testing.T) {
+	testInitApp(t)
+
+	testAsyncJob(t, 1)
+
+	job, err := g.Store.GetJob(1)
+	assert.NoError(t, err)
+	assert.NoError(t, g.cancelJob(job))
+
+	job, err = g.Store.GetJob(1)
+	assert.NoError(t, err)
+	assert.Equal(t, structs.JobStatusCanceled, job.Status())
+}
+
+func TestApp_CompleteAsyncJobRetry(t *testing.T) {
+	testInitApp(t)
+
+	job := testAsyncJob(t, 1)
+	job.MaxRetries = 1
+	job.RetryDelay = 60
+	assert.NoError(t, g.Store.UpdateJob(job))
+
+	completed, err := g.completeAsyncJob(1, &structs.CompleteJobRequest{Success: false, Err: "error"})
+	assert.NoError(t, err)
+	assert.True(t, completed)
+
+	// the failed job is scheduled for the next attempt.
+	job, err = g.Store.GetJob(1)
+	assert.NoError(t, err)
+	job = g.QueueManager.LoadJobStatus(job)
+	assert.Equal(t, structs.JobStatusScheduled, job.Status())
+	assert.Equal(t, "error", job.Err)
+	assert.Nil(t, job.StartedAt)
+	assert.Nil(t, job.FinishedAt)
+	assert.NotNil(t, job.RunAt)
+
+	// the status code is classified by the status policy. 404 is not retried.
+	job = testAsyncJob(t, 2)
+	job.MaxRetries = 1
+	assert.NoError(t, g.Store.UpdateJob(job))
+
+	completed, err = g.completeAsyncJob(2, &structs.CompleteJobRequest{StatusCode: 404})
+	assert.NoError(t, err)
+	assert.True(t, completed)
+
+	job, err = g.Store.GetJob(2)
+	assert.NoError(t, err)
+	assert.Equal(t, structs.JobStatusFailure, job.Status())
+	if assert.NotNil(t, job.StatusCode) {
+		assert.Equal(t, 404, *job.StatusCode)
+	}
+
+	// the job that used up the retries fails.
+	job = testAsyncJob(t, 3)
+	job.MaxRetries = 1
+	job.Attempt = 2
+	assert.NoError(t, g.Store.UpdateJob(job))
+
+	completed, err = g.completeAsyncJob(3, &structs.CompleteJobRequest{StatusCode: 503})
+	assert.NoError(t, err)
+	assert.True(t, completed)
+
+	job, err = g.Store.GetJob(3)
+	assert.NoError(t, err)
+	assert.Equal(t, structs.JobStatusFailure, job.Status())
+}
+
+func TestApp_CancelScheduledAsyncJob(t *testing.T) {
+	testInitApp(t)
+
+	testAsyncJob(t, 1)
+
+	// the overlapping run of the schedule cancels the previous job running asynchronously.
+	assert.NoError(t, g.cancelScheduledJob(1))
+
+	job, err := g.Store.GetJob(1)
+	assert.NoError(t, err)
+	assert.Equal(t, structs.JobStatusCanceled, job.Status())
+}
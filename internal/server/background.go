@@ -2,6 +2,7 @@ package server
 
 import (
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/kayac/go-katsubushi"
@@ -13,11 +14,15 @@ type BackgroundCleaner struct {
 	queueManager *QueueManager
 	store        *Store
 	jobLifetime  int64
-	ticker       *time.Ticker
-	stopCh       chan bool
-	wg           *sync.WaitGroup
-	running      bool
-	mutex        *sync.Mutex
+	// expire expires the jobs that passed their deadlines.
+	expire func(now time.Time)
+	// failStale fails the async jobs whose workers have not reported back.
+	failStale func(now time.Time)
+	ticker    *time.Ticker
+	stopCh    chan bool
+	wg        *sync.WaitGroup
+	running   bool
+	mutex     *sync.Mutex
 }
 
 func NewBackgroundCleaner(logger echo.Logger, queueManager *QueueManager, store *Store, tickerDuration time.Duration, jobLifetime int64) *BackgroundCleaner {
@@ -34,6 +39,11 @@ func NewBackgroundCleaner(logger echo.Logger, queueManager *QueueManager, store
 	}
 }
 
+// SetJobLifetime changes the seconds to keep the finished jobs.
+func (bg *BackgroundCleaner) SetJobLifetime(jobLifetime int64) {
+	atomic.StoreInt64(&bg.jobLifetime, jobLifetime)
+}
+
 func (bg *BackgroundCleaner) Start() {
 	bg.wg.Add(1)
 	go func() {
@@ -70,14 +80,23 @@ func (bg *BackgroundCleaner) run() {
 	}
 	defer bg.done()
 
-	tt := time.Now().Add(time.Duration(-1*bg.jobLifetime) * time.Second)
+	if bg.expire != nil {
+		bg.expire(time.Now())
+	}
+
+	if bg.failStale != nil {
+		bg.failStale(time.Now())
+	}
+
+	jobLifetime := atomic.LoadInt64(&bg.jobLifetime)
+	tt := time.Now().Add(time.Duration(-1*jobLifetime) * time.Second)
 	begin := katsubushi.ToID(tt)
 	query := &ListJobsQuery{
 		Reverse: true,
 		Begin:   &begin,
 	}
 
-	bg.logger.Debugf("Try to get before %v (%d) jobs to delete (keep %d sec)", tt, begin, bg.jobLifetime)
+	bg.logger.Debugf("Try to get before %v (%d) jobs to delete (keep %d sec)", tt, begin, jobLifetime)
 	list, err := bg.store.ListJobs(query)
 	if err != nil {
 		bg.logger.Error(err)
@@ -106,6 +125,18 @@ func (bg *BackgroundCleaner) run() {
 		}
 		bg.logger.Debugf("deleted job: %d", job.ID)
 	}
+
+	n, err := bg.store.DeleteFinishedBatches(tt)
+	if err != nil {
+		bg.logger.Error(err)
+	}
+	bg.logger.Debugf("deleted %d finished batches", n)
+
+	n, err = bg.store.DeleteExpiredUniqueKeys(time.Now())
+	if err != nil {
+		bg.logger.Error(err)
+	}
+	bg.logger.Debugf("deleted %d expired unique keys", n)
 }
 
 func (bg *BackgroundCleaner) shouldRun() bool {
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/kayac/go-katsubushi"
+	"github.com/pkg/errors"
+
+	"github.com/kohkimakimoto/hq/internal/structs"
+)
+
+// BatchCallbackTimeout is the timeout in seconds of the request to the onComplete url of a batch.
+var BatchCallbackTimeout int64 = 30
+
+// submitBatch stores the batch and its jobs atomically, and puts the jobs into the queue.
+func (a *App) submitBatch(jobs []*structs.Job, onComplete string) (*structs.Batch, error) {
+	id, err := a.IdGen.NextID()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate uniq id")
+	}
+
+	batch := &structs.Batch{}
+	batch.ID = id
+	batch.CreatedAt = katsubushi.ToTime(id)
+	batch.OnComplete = onComplete
+	for _, job := range jobs {
+		job.BatchID = id
+		batch.Jobs = append(batch.Jobs, job.ID)
+	}
+	batch.NumJobs = len(batch.Jobs)
+
+	if err := a.Store.CreateBatch(batch, jobs); err != nil {
+		return nil, err
+	}
+
+	// The jobs that run immediately are enqueued at once, so that the batch is rejected as a whole if the queue is full.
+	now := time.Now()
+	due := []*structs.Job{}
+	enqueued := map[uint64]bool{}
+	for _, job := range jobs {
+		if len(job.DependsOn) == 0 && isDue(job, now) {
+			due = append(due, job)
+			enqueued[job.ID] = true
+		}
+	}
+	if err := a.QueueManager.EnqueueAll(due); err != nil {
+		if err == ErrQueueFull {
+			a.rejectBatch(batch)
+		}
+		return nil, err
+	}
+
+	for _, job := range jobs {
+		if !enqueued[job.ID] {
+			if err := a.startJob(job); err != nil {
+				return nil, err
+			}
+		}
+		a.publishEvent(structs.EventTypePushed, job)
+	}
+
+	return a.Store.GetBatch(id)
+}
+
+// rejectBatch deletes the stored batch and its jobs that were not accepted by the full queue.
+func (a *App) rejectBatch(batch *structs.Batch) {
+	for _, id := range batch.Jobs {
+		if err := a.Store.DeleteJob(id); err != nil {
+			a.Echo.Logger.Error(err)
+		}
+	}
+	if err := a.Store.DeleteBatch(batch.ID); err != nil {
+		a.Echo.Logger.Error(err)
+	}
+}
+
+// finishBatchJob records that the job of a batch finished.
+// When all the jobs of the batch finished, the summary of the batch is sent to its onComplete url.
+func (a *App) finishBatchJob(job *structs.Job) {
+	logger := a.Echo.Logger
+
+	done, err := a.Store.FinishBatchJob(job.BatchID, job.ID)
+	if err != nil {
+		logger.Error(errors.Wrapf(err, "failed to finish the job %d of the batch %d", job.ID, job.BatchID))
+		return
+	}
+	if !done {
+		return
+	}
+
+	batch, err := a.Store.GetBatch(job.BatchID)
+	if err != nil {
+		logger.Error(errors.Wrapf(err, "failed to get the batch %d", job.BatchID))
+		return
+	}
+
+	logger.Infof("batch %d finished", batch.ID)
+
+	if batch.OnComplete == "" {
+		return
+	}
+
+	go func() {
+		if err := a.sendBatchCallback(batch); err != nil {
+			logger.Error(errors.Wrapf(err, "failed to send the callback of the batch %d", batch.ID))
+		}
+	}()
+}
+
+// sendBatchCallback posts the summary of the batch to its onComplete url.
+func (a *App) sendBatchCallback(batch *structs.Batch) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode the batch")
+	}
+
+	req, err := http.NewRequest("POST", batch.OnComplete, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "failed to create new request")
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("User-Agent", WorkerDefaultUserAgent)
+	req.Header.Add("X-Hq-Batch-Id", fmt.Sprintf("%d", batch.ID))
+
+	client := a.httpClientFactory()
+	client.Timeout = time.Duration(BatchCallbackTimeout) * time.Second
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "failed to do http request")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	return nil
+}
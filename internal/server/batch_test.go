@@ -0,0 +1,60 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kohkimakimoto/hq/internal/structs"
+)
+
+func TestApp_SubmitBatch(t *testing.T) {
+	testInitApp(t)
+
+	callback := make(chan *http.Request, 1)
+	received := &structs.Batch{}
+	g.httpClientFactory = func() *http.Client {
+		return testHttpClient(t, func(req *http.Request) *http.Response {
+			body, _ := ioutil.ReadAll(req.Body)
+			_ = json.Unmarshal(body, received)
+			callback <- req
+			return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBufferString(""))}
+		})
+	}
+
+	jobs := []*structs.Job{
+		{ID: 1, URL: "http://example.com"},
+		{ID: 2, URL: "http://example.com"},
+	}
+	batch, err := g.submitBatch(jobs, "http://example.com/callback")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, batch.NumJobs)
+	assert.Equal(t, 2, batch.NumPending)
+	assert.Equal(t, batch.ID, jobs[0].BatchID)
+
+	testFinishJob(t, jobs[0], true)
+	batch, err = g.Store.GetBatch(batch.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, batch.NumSuccess)
+	assert.Equal(t, 1, batch.NumPending)
+	assert.False(t, batch.Finished)
+
+	testFinishJob(t, jobs[1], false)
+
+	select {
+	case req := <-callback:
+		assert.Equal(t, "http://example.com/callback", req.URL.String())
+		assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+		assert.Equal(t, batch.ID, received.ID)
+		assert.Equal(t, 1, received.NumSuccess)
+		assert.Equal(t, 1, received.NumFailure)
+		assert.True(t, received.Finished)
+	case <-time.After(3 * time.Second):
+		t.Fatal("the callback was not sent")
+	}
+}
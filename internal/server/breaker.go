@@ -0,0 +1,180 @@
+package server
+
+import (
+	"net"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kohkimakimoto/hq/internal/structs"
+)
+
+// CircuitBreakers is a DispatchGate that has a circuit breaker per worker host.
+// A breaker opens after the threshold of consecutive connection errors or 5xx responses.
+// While it is open, the jobs for the host are held in the queue. After the cooldown, it becomes half-open
+// and sends a job as a probe. If the probe succeeds the breaker is closed, otherwise it opens again.
+// If the threshold is 0, the breakers are disabled.
+type CircuitBreakers struct {
+	mutex     sync.Mutex
+	threshold int64
+	cooldown  time.Duration
+	breakers  map[string]*circuitBreaker
+}
+
+type circuitBreaker struct {
+	state    string
+	failures int64
+	openedAt time.Time
+	// probe is the ID of the job that is sent in the half-open state.
+	probe   uint64
+	probing bool
+}
+
+func NewCircuitBreakers(threshold int64, cooldown time.Duration) *CircuitBreakers {
+	return &CircuitBreakers{
+		threshold: threshold,
+		cooldown:  cooldown,
+		breakers:  map[string]*circuitBreaker{},
+	}
+}
+
+func (c *CircuitBreakers) Acquire(job *structs.Job, now time.Time) (bool, time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	b, ok := c.breakers[breakerHost(job.URL)]
+	if !ok {
+		return true, 0
+	}
+
+	switch b.state {
+	case structs.BreakerStateOpen:
+		if retryAt := b.openedAt.Add(c.cooldown); now.Before(retryAt) {
+			return false, retryAt.Sub(now)
+		}
+		b.state = structs.BreakerStateHalfOpen
+		fallthrough
+	case structs.BreakerStateHalfOpen:
+		if b.probing {
+			// wait for the result of the probe.
+			return false, 0
+		}
+		b.probe = job.ID
+		b.probing = true
+	}
+
+	return true, 0
+}
+
+func (c *CircuitBreakers) Release(job *structs.Job) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// If the probe finished without the result (e.g. it was canceled), the next job is sent as a probe.
+	if b, ok := c.breakers[breakerHost(job.URL)]; ok && b.probing && b.probe == job.ID {
+		b.probing = false
+	}
+}
+
+// Report records the result of the request of the job.
+func (c *CircuitBreakers) Report(job *structs.Job, err error, now time.Time) {
+	if c.threshold <= 0 {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	host := breakerHost(job.URL)
+	b, ok := c.breakers[host]
+
+	if !isBreakerFailure(err) {
+		if ok {
+			// The host works. So the breaker is closed.
+			delete(c.breakers, host)
+		}
+		return
+	}
+
+	if !ok {
+		b = &circuitBreaker{
+			state: structs.BreakerStateClosed,
+		}
+		c.breakers[host] = b
+	}
+
+	b.failures++
+	if b.state == structs.BreakerStateHalfOpen || b.failures >= c.threshold {
+		b.state = structs.BreakerStateOpen
+		b.openedAt = now
+		b.probing = false
+	}
+}
+
+// List returns the states of the breakers sorted by the host.
+func (c *CircuitBreakers) List() []*structs.Breaker {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	ret := []*structs.Breaker{}
+	for host, b := range c.breakers {
+		breaker := &structs.Breaker{
+			Host:     host,
+			State:    b.state,
+			Failures: b.failures,
+		}
+		if b.state != structs.BreakerStateClosed {
+			openedAt := b.openedAt.UTC()
+			retryAt := b.openedAt.Add(c.cooldown).UTC()
+			breaker.OpenedAt = &openedAt
+			breaker.RetryAt = &retryAt
+		}
+		ret = append(ret, breaker)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Host < ret[j].Host
+	})
+	return ret
+}
+
+// NumOpen returns the number of the breakers that are not closed.
+func (c *CircuitBreakers) NumOpen() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	n := 0
+	for _, b := range c.breakers {
+		if b.state != structs.BreakerStateClosed {
+			n++
+		}
+	}
+	return n
+}
+
+func breakerHost(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// isBreakerFailure reports whether the error of the request means the host is down.
+// The connection errors and 5xx responses are the failures. The timeouts of the jobs are not.
+// The error of the canceled job must not be passed.
+func isBreakerFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	switch e := errors.Cause(err).(type) {
+	case *StatusCodeError:
+		return e.StatusCode >= 500
+	case net.Error:
+		return !e.Timeout()
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kohkimakimoto/hq/internal/structs"
+)
+
+func TestCircuitBreakers(t *testing.T) {
+	c := NewCircuitBreakers(2, 30*time.Second)
+	now := time.Now()
+	connErr := errors.Wrap(&net.OpError{Op: "dial", Err: fmt.Errorf("connection refused")}, "failed to do http request")
+
+	job := func(id uint64) *structs.Job {
+		return &structs.Job{ID: id, URL: "http://worker.example.com/job"}
+	}
+
+	// a 4xx response is not a failure of the host.
+	c.Report(job(1), &StatusCodeError{StatusCode: http.StatusBadRequest}, now)
+	assert.Equal(t, 0, len(c.List()))
+
+	c.Report(job(2), connErr, now)
+	assert.Equal(t, 0, c.NumOpen())
+	ok, _ := c.Acquire(job(3), now)
+	assert.True(t, ok)
+
+	// it opens after 2 consecutive failures.
+	c.Report(job(3), &StatusCodeError{StatusCode: http.StatusServiceUnavailable}, now)
+	assert.Equal(t, 1, c.NumOpen())
+	breakers := c.List()
+	assert.Equal(t, "worker.example.com", breakers[0].Host)
+	assert.Equal(t, structs.BreakerStateOpen, breakers[0].State)
+	assert.Equal(t, int64(2), breakers[0].Failures)
+
+	ok, wait := c.Acquire(job(4), now.Add(10*time.Second))
+	assert.False(t, ok)
+	assert.Equal(t, 20*time.Second, wait)
+
+	// the other host is not affected.
+	ok, _ = c.Acquire(&structs.Job{ID: 5, URL: "http://other.example.com/job"}, now)
+	assert.True(t, ok)
+
+	// after the cooldown, only one job is sent as a probe.
+	now = now.Add(30 * time.Second)
+	ok, _ = c.Acquire(job(4), now)
+	assert.True(t, ok)
+	ok, wait = c.Acquire(job(6), now)
+	assert.False(t, ok)
+	assert.Equal(t, time.Duration(0), wait)
+	assert.Equal(t, structs.BreakerStateHalfOpen, c.List()[0].State)
+
+	// the failed probe opens the breaker again.
+	c.Report(job(4), connErr, now)
+	c.Release(job(4))
+	assert.Equal(t, structs.BreakerStateOpen, c.List()[0].State)
+
+	// the succeeded probe closes the breaker.
+	now = now.Add(30 * time.Second)
+	ok, _ = c.Acquire(job(6), now)
+	assert.True(t, ok)
+	c.Report(job(6), nil, now)
+	c.Release(job(6))
+	assert.Equal(t, 0, c.NumOpen())
+	assert.Equal(t, 0, len(c.List()))
+}
+
+func TestCircuitBreakers_Disabled(t *testing.T) {
+	c := NewCircuitBreakers(0, 30*time.Second)
+	now := time.Now()
+
+	for i := uint64(1); i <= 10; i++ {
+		c.Report(&structs.Job{ID: i, URL: "http://worker.example.com/job"}, &StatusCodeError{StatusCode: http.StatusInternalServerError}, now)
+	}
+	assert.Equal(t, 0, c.NumOpen())
+	ok, _ := c.Acquire(&structs.Job{ID: 11, URL: "http://worker.example.com/job"}, now)
+	assert.True(t, ok)
+}
+
+func TestCircuitBreakers_DequeueRateLimitedHost(t *testing.T) {
+	limits := []*HostLimitConfig{
+		{Match: "worker.example.com", Rate: 1},
+	}
+	assert.NoError(t, validateHostLimits(limits))
+	l := NewHostLimiter(limits)
+	c := NewCircuitBreakers(1, 30*time.Second)
+	m := NewQueueManager(10)
+	m.AddGate(l)
+	m.AddGate(c)
+	now := time.Now()
+
+	job := &structs.Job{ID: 1, URL: "http://worker.example.com/job"}
+	c.Report(job, &StatusCodeError{StatusCode: http.StatusServiceUnavailable}, now)
+	assert.Equal(t, 1, c.NumOpen())
+	assert.NoError(t, m.Enqueue(&structs.Job{ID: 2, URL: "http://worker.example.com/job"}))
+
+	// the job held by the open breaker does not use up the tokens of the host.
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for i := 0; i < 3; i++ {
+		ret, wait := m.dequeue("", now)
+		assert.Nil(t, ret)
+		assert.Equal(t, 30*time.Second, wait)
+	}
+	assert.Equal(t, float64(1), l.limits[0].tokens)
+	assert.Equal(t, int64(0), l.limits[0].running)
+}
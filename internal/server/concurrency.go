@@ -0,0 +1,73 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/kohkimakimoto/hq/internal/structs"
+)
+
+// DefaultConcurrencyLimit is the number of the jobs of the same concurrency key that can run at once by default.
+const DefaultConcurrencyLimit = 1
+
+// ConcurrencyKeys is a DispatchGate that limits the running jobs that have the same concurrency key.
+// The limit is taken from the job that is dispatched, so the jobs of a key should have the same limit.
+type ConcurrencyKeys struct {
+	mutex   sync.Mutex
+	running map[string]int
+	// held is the concurrency keys that are taken by the running jobs.
+	held map[uint64]string
+}
+
+func NewConcurrencyKeys() *ConcurrencyKeys {
+	return &ConcurrencyKeys{
+		running: map[string]int{},
+		held:    map[uint64]string{},
+	}
+}
+
+func (c *ConcurrencyKeys) Acquire(job *structs.Job, now time.Time) (bool, time.Duration) {
+	if job.ConcurrencyKey == "" {
+		return true, 0
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	limit := job.ConcurrencyLimit
+	if limit <= 0 {
+		limit = DefaultConcurrencyLimit
+	}
+	if c.running[job.ConcurrencyKey] >= limit {
+		// wait for a running job of the key to finish.
+		return false, 0
+	}
+
+	c.running[job.ConcurrencyKey]++
+	c.held[job.ID] = job.ConcurrencyKey
+
+	return true, 0
+}
+
+func (c *ConcurrencyKeys) Release(job *structs.Job) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	key, ok := c.held[job.ID]
+	if !ok {
+		return
+	}
+	c.running[key]--
+	if c.running[key] <= 0 {
+		delete(c.running, key)
+	}
+	delete(c.held, job.ID)
+}
+
+// NumRunning returns the number of the running jobs of the concurrency key.
+func (c *ConcurrencyKeys) NumRunning(key string) int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.running[key]
+}
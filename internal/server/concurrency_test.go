@@ -0,0 +1,54 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kohkimakimoto/hq/internal/structs"
+)
+
+func TestConcurrencyKeys(t *testing.T) {
+	c := NewConcurrencyKeys()
+	now := time.Now()
+
+	job1 := &structs.Job{ID: 1, ConcurrencyKey: "account-1"}
+	job2 := &structs.Job{ID: 2, ConcurrencyKey: "account-1"}
+	job3 := &structs.Job{ID: 3, ConcurrencyKey: "account-2"}
+	job4 := &structs.Job{ID: 4}
+
+	ok, _ := c.Acquire(job1, now)
+	assert.True(t, ok)
+
+	// the default limit is 1.
+	ok, wait := c.Acquire(job2, now)
+	assert.False(t, ok)
+	assert.Equal(t, time.Duration(0), wait)
+
+	ok, _ = c.Acquire(job3, now)
+	assert.True(t, ok)
+	ok, _ = c.Acquire(job4, now)
+	assert.True(t, ok)
+
+	c.Release(job1)
+	assert.Equal(t, 0, c.NumRunning("account-1"))
+	ok, _ = c.Acquire(job2, now)
+	assert.True(t, ok)
+
+	// the job without the slot is ignored.
+	c.Release(job4)
+	assert.Equal(t, 1, c.NumRunning("account-1"))
+}
+
+func TestConcurrencyKeys_Limit(t *testing.T) {
+	c := NewConcurrencyKeys()
+	now := time.Now()
+
+	for i := uint64(1); i <= 2; i++ {
+		ok, _ := c.Acquire(&structs.Job{ID: i, ConcurrencyKey: "key", ConcurrencyLimit: 2}, now)
+		assert.True(t, ok)
+	}
+	ok, _ := c.Acquire(&structs.Job{ID: 3, ConcurrencyKey: "key", ConcurrencyLimit: 2}, now)
+	assert.False(t, ok)
+}
@@ -1,30 +1,50 @@
 package server
 
 import (
+	"encoding/json"
 	"fmt"
+	"math"
+	"net/url"
+	"regexp"
 	"runtime"
 	"strings"
 	"time"
 
 	"github.com/labstack/gommon/log"
+
+	"github.com/kohkimakimoto/hq/internal/structs"
 )
 
 type Config struct {
-	ServerId            uint   `toml:"server_id"`
-	LogLevelString      string `toml:"log_level"`
-	Addr                string `toml:"addr"`
-	Logfile             string `toml:"log_file"`
-	DataDir             string `toml:"data_dir"`
-	AccessLogfile       string `toml:"access_log_file"`
-	Queues              int64  `toml:"queues"`
-	Dispatchers         int64  `toml:"dispatchers"`
-	MaxWorkers          int64  `toml:"max_workers"`
-	ShutdownTimeout     int64  `toml:"shutdown_timeout"`
-	JobLifetime         int64  `toml:"job_lifetime"`
-	JobListDefaultLimit int    `toml:"job_list_default_limit"`
-	UI                  bool   `toml:"ui"`
-	UIBasename          string `toml:"ui_basename"`
-	IDEpoch             []int  `toml:"id_epoch"`
+	ServerId            uint                         `toml:"server_id"`
+	LogLevelString      string                       `toml:"log_level"`
+	Addr                string                       `toml:"addr"`
+	Logfile             string                       `toml:"log_file"`
+	DataDir             string                       `toml:"data_dir"`
+	AccessLogfile       string                       `toml:"access_log_file"`
+	Queues              int64                        `toml:"queues"`
+	Dispatchers         int64                        `toml:"dispatchers"`
+	MaxWorkers          int64                        `toml:"max_workers"`
+	ShutdownTimeout     int64                        `toml:"shutdown_timeout"`
+	JobLifetime         int64                        `toml:"job_lifetime"`
+	JobListDefaultLimit int                          `toml:"job_list_default_limit"`
+	UI                  bool                         `toml:"ui"`
+	UIBasename          string                       `toml:"ui_basename"`
+	IDEpoch             []int                        `toml:"id_epoch"`
+	StatusPolicy        *structs.StatusPolicy        `toml:"status_policy"`
+	RecoverRunningJobs  string                       `toml:"recover_running_jobs"`
+	PriorityAging       int64                        `toml:"priority_aging"`
+	CancelNotification  bool                         `toml:"cancel_notification"`
+	CancelGracePeriod   int64                        `toml:"cancel_grace_period"`
+	HeartbeatTimeout    int64                        `toml:"async_heartbeat_timeout"`
+	EventLogSize        int64                        `toml:"event_log_size"`
+	Schedules           []*ScheduleConfig            `toml:"schedules"`
+	NamedQueues         map[string]*NamedQueueConfig `toml:"queue"`
+	HostLimits          []*HostLimitConfig           `toml:"host_limits"`
+	CircuitBreaker      *CircuitBreakerConfig        `toml:"circuit_breaker"`
+	Webhook             *WebhookConfig               `toml:"webhook"`
+	// Loader loads the config again when the server reloads it. If it is nil, the config can not be reloaded.
+	Loader func() (*Config, error) `toml:"-"`
 }
 
 func NewConfig() *Config {
@@ -44,6 +64,21 @@ func NewConfig() *Config {
 		UI:                  true,
 		UIBasename:          "/ui",
 		IDEpoch:             []int{2019, 1, 1},
+		StatusPolicy:        DefaultStatusPolicy(),
+		RecoverRunningJobs:  RecoverRunningJobsFail,
+		PriorityAging:       0,
+		CancelNotification:  false,
+		CancelGracePeriod:   0,
+		HeartbeatTimeout:    300,
+		EventLogSize:        1000,
+		CircuitBreaker: &CircuitBreakerConfig{
+			Threshold: 0,
+			Cooldown:  30,
+		},
+		Webhook: &WebhookConfig{
+			MaxRetries: 5,
+			RetryDelay: 10,
+		},
 	}
 
 	return c
@@ -78,3 +113,206 @@ func (c *Config) IDEpochTime() (time.Time, error) {
 
 	return time.Date(c.IDEpoch[0], time.Month(c.IDEpoch[1]), c.IDEpoch[2], 0, 0, 0, 0, time.UTC), nil
 }
+
+// DefaultQueueName is the name that refers to the default queue.
+const DefaultQueueName = "default"
+
+var queueNameRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// NamedQueueConfig is a named queue that is declared in the config file.
+// The jobs in a named queue are dispatched by its own dispatchers, so the queue has an independent concurrency limit.
+type NamedQueueConfig struct {
+	Concurrency int64             `toml:"concurrency"`
+	URL         string            `toml:"url"`
+	Timeout     int64             `toml:"timeout"`
+	Headers     map[string]string `toml:"headers"`
+}
+
+func validateNamedQueues(queues map[string]*NamedQueueConfig) error {
+	for name, q := range queues {
+		if name == DefaultQueueName {
+			return fmt.Errorf("the queue name '%s' is reserved", DefaultQueueName)
+		}
+		if !queueNameRegexp.MatchString(name) {
+			return fmt.Errorf("the queue name must consist of alphanumeric characters, '-', '_' or '.' but '%s'", name)
+		}
+		if q.Concurrency == 0 {
+			q.Concurrency = 1
+		} else if q.Concurrency < 0 {
+			return fmt.Errorf("the concurrency of the queue '%s' must be greater than 0", name)
+		}
+		if q.Timeout < 0 {
+			return fmt.Errorf("the timeout of the queue '%s' must not be negative", name)
+		}
+	}
+	return nil
+}
+
+// NamedQueue returns the config of the named queue.
+// It returns nil for the default queue that is referred by the empty name or 'default'.
+func (c *Config) NamedQueue(name string) (*NamedQueueConfig, error) {
+	if name == "" || name == DefaultQueueName {
+		return nil, nil
+	}
+
+	q, ok := c.NamedQueues[name]
+	if !ok {
+		return nil, fmt.Errorf("the queue '%s' is not defined", name)
+	}
+	return q, nil
+}
+
+// HostLimitConfig is a limit of the requests to a worker host.
+// The match is a host (e.g. 'api.example.com' or 'api.example.com:8080') or a URL prefix (e.g. 'http://api.example.com/v1/').
+type HostLimitConfig struct {
+	Match       string  `toml:"match"`
+	Concurrency int64   `toml:"concurrency"`
+	Rate        float64 `toml:"rate"`
+	Burst       int64   `toml:"burst"`
+}
+
+func validateHostLimits(limits []*HostLimitConfig) error {
+	for _, l := range limits {
+		if l.Match == "" {
+			return fmt.Errorf("'match' is required")
+		}
+		if l.Concurrency < 0 {
+			return fmt.Errorf("the concurrency of '%s' must not be negative", l.Match)
+		}
+		if l.Rate < 0 {
+			return fmt.Errorf("the rate of '%s' must not be negative", l.Match)
+		}
+		if l.Burst < 0 {
+			return fmt.Errorf("the burst of '%s' must not be negative", l.Match)
+		}
+		if l.Concurrency == 0 && l.Rate == 0 {
+			return fmt.Errorf("'%s' must have the concurrency or the rate", l.Match)
+		}
+		if l.Rate > 0 && l.Burst == 0 {
+			l.Burst = int64(math.Max(1, math.Ceil(l.Rate)))
+		}
+	}
+	return nil
+}
+
+// CircuitBreakerConfig is the config of the circuit breakers per worker host.
+// The breaker opens after the threshold of consecutive failures, and sends a probe after the cooldown seconds.
+type CircuitBreakerConfig struct {
+	Threshold int64 `toml:"threshold"`
+	Cooldown  int64 `toml:"cooldown"`
+}
+
+func validateCircuitBreaker(c *CircuitBreakerConfig) error {
+	if c == nil {
+		return fmt.Errorf("circuit_breaker is required")
+	}
+	if c.Threshold < 0 {
+		return fmt.Errorf("threshold must not be negative")
+	}
+	if c.Threshold > 0 && c.Cooldown <= 0 {
+		return fmt.Errorf("cooldown must be greater than 0")
+	}
+	return nil
+}
+
+// WebhookConfig is the config of the webhooks that notify the finished jobs.
+// The URLs are the subscribers of all the jobs. The deliveries are signed by the secret.
+type WebhookConfig struct {
+	URLs       []string `toml:"urls"`
+	Secret     string   `toml:"secret"`
+	MaxRetries int      `toml:"max_retries"`
+	RetryDelay int64    `toml:"retry_delay"`
+}
+
+func validateWebhook(c *WebhookConfig) error {
+	if c == nil {
+		return fmt.Errorf("webhook is required")
+	}
+	for _, rawurl := range c.URLs {
+		if u, err := url.Parse(rawurl); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("the url must be a http or https url but '%s'", rawurl)
+		}
+	}
+	if c.MaxRetries < 0 {
+		return fmt.Errorf("max_retries must not be negative")
+	}
+	if c.RetryDelay < 0 {
+		return fmt.Errorf("retry_delay must not be negative")
+	}
+	return nil
+}
+
+// ScheduleConfig is a schedule that is declared in the config file.
+type ScheduleConfig struct {
+	Name     string             `toml:"name"`
+	Cron     string             `toml:"cron"`
+	Timezone string             `toml:"timezone"`
+	Overlap  string             `toml:"overlap"`
+	CatchUp  string             `toml:"catch_up"`
+	Paused   bool               `toml:"paused"`
+	Job      *ScheduleJobConfig `toml:"job"`
+}
+
+type ScheduleJobConfig struct {
+	Name             string                `toml:"name"`
+	Comment          string                `toml:"comment"`
+	URL              string                `toml:"url"`
+	Payload          interface{}           `toml:"payload"`
+	Headers          map[string]string     `toml:"headers"`
+	Timeout          int64                 `toml:"timeout"`
+	CancelURL        string                `toml:"cancel_url"`
+	NotifyURL        string                `toml:"notify_url"`
+	Async            bool                  `toml:"async"`
+	Queue            string                `toml:"queue"`
+	Priority         int                   `toml:"priority"`
+	ConcurrencyKey   string                `toml:"concurrency_key"`
+	ConcurrencyLimit int                   `toml:"concurrency_limit"`
+	MaxRetries       int                   `toml:"max_retries"`
+	RetryDelay       int64                 `toml:"retry_delay"`
+	RetryBackoff     float64               `toml:"retry_backoff"`
+	StatusPolicy     *structs.StatusPolicy `toml:"status_policy"`
+	TTL              int64                 `toml:"ttl"`
+}
+
+func (c *ScheduleConfig) Schedule() (*structs.Schedule, error) {
+	schedule := &structs.Schedule{
+		Name:     c.Name,
+		Cron:     c.Cron,
+		Timezone: c.Timezone,
+		Overlap:  c.Overlap,
+		CatchUp:  c.CatchUp,
+		Paused:   c.Paused,
+	}
+
+	if c.Job != nil {
+		schedule.Job = &structs.JobTemplate{
+			Name:             c.Job.Name,
+			Comment:          c.Job.Comment,
+			URL:              c.Job.URL,
+			Headers:          c.Job.Headers,
+			Timeout:          c.Job.Timeout,
+			CancelURL:        c.Job.CancelURL,
+			NotifyURL:        c.Job.NotifyURL,
+			Async:            c.Job.Async,
+			Queue:            c.Job.Queue,
+			Priority:         c.Job.Priority,
+			ConcurrencyKey:   c.Job.ConcurrencyKey,
+			ConcurrencyLimit: c.Job.ConcurrencyLimit,
+			MaxRetries:       c.Job.MaxRetries,
+			RetryDelay:       c.Job.RetryDelay,
+			RetryBackoff:     c.Job.RetryBackoff,
+			StatusPolicy:     c.Job.StatusPolicy,
+			TTL:              c.Job.TTL,
+		}
+
+		if c.Job.Payload != nil {
+			b, err := json.Marshal(c.Job.Payload)
+			if err != nil {
+				return nil, fmt.Errorf("invalid 'payload': %v", err)
+			}
+			schedule.Job.Payload = b
+		}
+	}
+
+	return schedule, nil
+}
@@ -32,3 +32,60 @@ func TestConfig_LogLevel(t *testing.T) {
 		assert.Equal(t, test.Expected, l)
 	}
 }
+
+func TestValidateNamedQueues(t *testing.T) {
+	queues := map[string]*NamedQueueConfig{
+		"slow": {},
+	}
+	assert.NoError(t, validateNamedQueues(queues))
+	assert.Equal(t, int64(1), queues["slow"].Concurrency, "default concurrency should be 1")
+
+	invalids := []map[string]*NamedQueueConfig{
+		{"default": {}},
+		{"foo/bar": {}},
+		{"slow": {Concurrency: -1}},
+		{"slow": {Timeout: -1}},
+	}
+	for _, queues := range invalids {
+		assert.Error(t, validateNamedQueues(queues))
+	}
+}
+
+func TestValidateHostLimits(t *testing.T) {
+	limits := []*HostLimitConfig{
+		{Match: "api.example.com", Rate: 2.5},
+	}
+	assert.NoError(t, validateHostLimits(limits))
+	assert.Equal(t, int64(3), limits[0].Burst, "default burst should be the ceil of the rate")
+
+	invalids := []*HostLimitConfig{
+		{Concurrency: 1},
+		{Match: "api.example.com"},
+		{Match: "api.example.com", Concurrency: -1},
+		{Match: "api.example.com", Rate: -1},
+		{Match: "api.example.com", Rate: 1, Burst: -1},
+	}
+	for _, l := range invalids {
+		assert.Error(t, validateHostLimits([]*HostLimitConfig{l}))
+	}
+}
+
+func TestScheduleConfig_Schedule(t *testing.T) {
+	c := &ScheduleConfig{
+		Name: "nightly",
+		Cron: "0 3 * * *",
+		Job: &ScheduleJobConfig{
+			URL: "http://example.com",
+			Payload: map[string]interface{}{
+				"message": "Hello world!",
+			},
+		},
+	}
+
+	s, err := c.Schedule()
+	assert.NoError(t, err)
+	assert.Equal(t, "nightly", s.Name)
+	assert.Equal(t, "http://example.com", s.Job.URL)
+	assert.Equal(t, `{"message":"Hello world!"}`, string(s.Job.Payload))
+	assert.NoError(t, validateSchedule(s))
+}
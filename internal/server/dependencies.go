@@ -0,0 +1,133 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/kohkimakimoto/hq/internal/structs"
+)
+
+const (
+	dependencyPending = iota
+	dependencyReady
+	dependencyFailed
+)
+
+// blockJob blocks the job until its parent jobs finish.
+// If the parents have already finished, the job is queued or canceled immediately.
+func (a *App) blockJob(job *structs.Job) error {
+	a.dependencyMutex.Lock()
+	state, reason, err := a.checkDependencies(job)
+	if err != nil {
+		a.dependencyMutex.Unlock()
+		return err
+	}
+	if state == dependencyPending {
+		job.Blocked = true
+		a.QueueManager.RegisterBlockedJob(job)
+	}
+	a.dependencyMutex.Unlock()
+
+	switch state {
+	case dependencyReady:
+		return a.queueJob(job)
+	case dependencyFailed:
+		return a.finishCanceledJob(job, reason)
+	}
+	return nil
+}
+
+// checkDependencies checks the parents of the job.
+// The job is ready when all the parents succeeded. If a parent failed, expired or was canceled, the job fails
+// unless it has runOnParentFailure. In that case, the job is ready when all the parents finished.
+// If the job fails, it also returns the reason.
+func (a *App) checkDependencies(job *structs.Job) (int, string, error) {
+	pending := false
+	reason := ""
+	for _, id := range job.DependsOn {
+		parent, err := a.Store.GetJob(id)
+		if err != nil {
+			if _, ok := err.(*ErrJobNotFound); ok {
+				if reason == "" {
+					reason = fmt.Sprintf("the parent job %d was not found", id)
+				}
+				continue
+			}
+			return dependencyPending, "", err
+		}
+
+		if parent.Running || parent.Waiting || parent.Scheduled || parent.Blocked || parent.FinishedAt == nil {
+			pending = true
+		} else if (!parent.Success || parent.Canceled || parent.Expired) && reason == "" {
+			reason = fmt.Sprintf("the parent job %d did not succeed (%s)", id, parent.Status())
+		}
+	}
+
+	if reason != "" && !job.RunOnParentFailure {
+		return dependencyFailed, reason, nil
+	}
+	if pending {
+		return dependencyPending, "", nil
+	}
+	return dependencyReady, "", nil
+}
+
+// releaseDependents checks the blocked jobs that depend on the finished job, and queues or cancels them.
+// The canceled jobs also release their dependents.
+func (a *App) releaseDependents(parent *structs.Job) {
+	logger := a.Echo.Logger
+
+	children, err := a.Store.ListDependents(parent.ID)
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+
+	var ready []*structs.Job
+	var failed []*structs.Job
+	reasons := map[uint64]string{}
+
+	a.dependencyMutex.Lock()
+	for _, id := range children {
+		job := a.QueueManager.BlockedJob(id)
+		if job == nil {
+			continue
+		}
+
+		state, reason, err := a.checkDependencies(job)
+		if err != nil {
+			logger.Error(err)
+			continue
+		}
+		if state == dependencyPending {
+			continue
+		}
+
+		if a.QueueManager.RemoveBlockedJob(id) == nil {
+			// It has been canceled.
+			continue
+		}
+		job.Blocked = false
+
+		if state == dependencyReady {
+			ready = append(ready, job)
+		} else {
+			failed = append(failed, job)
+			reasons[job.ID] = reason
+		}
+	}
+	a.dependencyMutex.Unlock()
+
+	for _, job := range ready {
+		logger.Infof("job: %d is released by the parent job %d", job.ID, parent.ID)
+		if err := a.queueJob(job); err != nil {
+			logger.Error(err)
+		}
+	}
+
+	for _, job := range failed {
+		logger.Infof("job: %d is canceled: %s", job.ID, reasons[job.ID])
+		if err := a.finishCanceledJob(job, reasons[job.ID]); err != nil {
+			logger.Error(err)
+		}
+	}
+}
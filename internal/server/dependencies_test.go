@@ -0,0 +1,171 @@
+package server
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kohkimakimoto/hq/internal/structs"
+)
+
+// testFinishJob finishes the queued job as the dispatcher does.
+func testFinishJob(t *testing.T, job *structs.Job, success bool) {
+	t.Helper()
+
+	assert.Equal(t, job.ID, g.QueueManager.Dequeue("").ID)
+	g.QueueManager.RegisterRunningJob(job, func() {})
+	g.QueueManager.RemoveRunningJob(job)
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	job.Success = success
+	job.Failure = !success
+	job.FinishedAt = &now
+	assert.NoError(t, g.Store.UpdateJob(job))
+	g.jobFinished(job)
+}
+
+func TestApp_DependsOn(t *testing.T) {
+	testInitApp(t)
+
+	parent1 := &structs.Job{ID: 1, URL: "http://example.com"}
+	parent2 := &structs.Job{ID: 2, URL: "http://example.com"}
+	child := &structs.Job{ID: 3, URL: "http://example.com", DependsOn: structs.JobIDs{1, 2}}
+	for _, job := range []*structs.Job{parent1, parent2, child} {
+		assert.NoError(t, g.submitJob(job))
+	}
+
+	job, err := g.Store.GetJob(3)
+	assert.NoError(t, err)
+	assert.Equal(t, structs.JobStatusBlocked, job.Status())
+	assert.Equal(t, structs.JobIDs{1, 2}, job.DependsOn)
+
+	job, err = g.Store.GetJob(1)
+	assert.NoError(t, err)
+	assert.Equal(t, structs.JobIDs{3}, job.Children)
+
+	// the child is blocked until all the parents succeed.
+	testFinishJob(t, parent1, true)
+	job, err = g.Store.GetJob(3)
+	assert.NoError(t, err)
+	assert.Equal(t, structs.JobStatusBlocked, job.Status())
+
+	testFinishJob(t, parent2, true)
+	job, err = g.Store.GetJob(3)
+	assert.NoError(t, err)
+	assert.Equal(t, structs.JobStatusWaiting, job.Status())
+	assert.Equal(t, 0, g.QueueManager.NumJobsBlocked())
+}
+
+func TestApp_DependsOnParentFailure(t *testing.T) {
+	testInitApp(t)
+
+	parent := &structs.Job{ID: 1, URL: "http://example.com"}
+	child := &structs.Job{ID: 2, URL: "http://example.com", DependsOn: structs.JobIDs{1}}
+	grandchild := &structs.Job{ID: 3, URL: "http://example.com", DependsOn: structs.JobIDs{2}}
+	runAnyway := &structs.Job{ID: 4, URL: "http://example.com", DependsOn: structs.JobIDs{1}, RunOnParentFailure: true}
+	for _, job := range []*structs.Job{parent, child, grandchild, runAnyway} {
+		assert.NoError(t, g.submitJob(job))
+	}
+	assert.Equal(t, 3, g.QueueManager.NumJobsBlocked())
+
+	testFinishJob(t, parent, false)
+
+	// the child and the grandchild are canceled.
+	for _, id := range []uint64{2, 3} {
+		job, err := g.Store.GetJob(id)
+		assert.NoError(t, err)
+		assert.Equal(t, structs.JobStatusCanceled, job.Status())
+		assert.NotEmpty(t, job.Err)
+	}
+
+	job, err := g.Store.GetJob(4)
+	assert.NoError(t, err)
+	assert.Equal(t, structs.JobStatusWaiting, job.Status())
+	assert.Equal(t, 0, g.QueueManager.NumJobsBlocked())
+}
+
+func TestApp_DependsOnFinishedParent(t *testing.T) {
+	testInitApp(t)
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	assert.NoError(t, g.Store.CreateJob(&structs.Job{ID: 1, URL: "http://example.com", FinishedAt: &now, Success: true}))
+	assert.NoError(t, g.Store.CreateJob(&structs.Job{ID: 2, URL: "http://example.com", FinishedAt: &now, Canceled: true}))
+
+	// the parent has already succeeded.
+	job := &structs.Job{ID: 3, URL: "http://example.com", DependsOn: structs.JobIDs{1}}
+	assert.NoError(t, g.submitJob(job))
+	assert.Equal(t, structs.JobStatusWaiting, g.QueueManager.LoadJobStatus(job).Status())
+
+	// the parent has already been canceled.
+	job = &structs.Job{ID: 4, URL: "http://example.com", DependsOn: structs.JobIDs{1, 2}}
+	assert.NoError(t, g.submitJob(job))
+	assert.Equal(t, structs.JobStatusCanceled, job.Status())
+}
+
+func TestApp_CancelBlockedJob(t *testing.T) {
+	testInitApp(t)
+
+	parent := &structs.Job{ID: 1, URL: "http://example.com"}
+	child := &structs.Job{ID: 2, URL: "http://example.com", DependsOn: structs.JobIDs{1}}
+	grandchild := &structs.Job{ID: 3, URL: "http://example.com", DependsOn: structs.JobIDs{2}}
+	for _, job := range []*structs.Job{parent, child, grandchild} {
+		assert.NoError(t, g.submitJob(job))
+	}
+
+	job, err := g.Store.GetJob(2)
+	assert.NoError(t, err)
+	assert.NoError(t, g.cancelJob(job))
+
+	for _, id := range []uint64{2, 3} {
+		job, err := g.Store.GetJob(id)
+		assert.NoError(t, err)
+		assert.Equal(t, structs.JobStatusCanceled, job.Status())
+	}
+	assert.Equal(t, 0, g.QueueManager.NumJobsBlocked())
+}
+
+func TestApp_CancelWaitingParent(t *testing.T) {
+	testInitApp(t)
+
+	parent := &structs.Job{ID: 1, URL: "http://example.com"}
+	child := &structs.Job{ID: 2, URL: "http://example.com", DependsOn: structs.JobIDs{1}}
+	for _, job := range []*structs.Job{parent, child} {
+		assert.NoError(t, g.submitJob(job))
+	}
+
+	// the parent is canceled while it is waiting.
+	g.QueueManager.CancelJob(1)
+
+	d := g.newDispatcher("", 0)
+	d.httpClientFactory = func() *http.Client {
+		return testHttpClient(t, func(req *http.Request) *http.Response {
+			t.Error("the canceled job must not be sent")
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewBuffer(nil)),
+				Header:     make(http.Header),
+			}
+		})
+	}
+	go d.EventLoop()
+	d.Wait()
+	d.Stop()
+
+	job, err := g.Store.GetJob(1)
+	assert.NoError(t, err)
+	assert.True(t, job.Canceled)
+	assert.False(t, job.Success)
+	assert.Equal(t, structs.JobStatusCanceled, job.Status())
+
+	// the child does not run after the canceled parent.
+	job, err = g.Store.GetJob(2)
+	assert.NoError(t, err)
+	assert.Equal(t, structs.JobStatusCanceled, job.Status())
+	assert.NotEmpty(t, job.Err)
+	assert.Equal(t, 0, g.QueueManager.NumJobsBlocked())
+	assert.Equal(t, 0, g.QueueManager.NumJobsInQueue())
+}
@@ -22,15 +22,32 @@ var (
 	WorkerDefaultUserAgent = fmt.Sprintf("HQ/%s", version.Version)
 )
 
+// CancelNotificationTimeout is the timeout in seconds of the cancel notification to the worker.
+var CancelNotificationTimeout int64 = 30
+
 // Dispatcher contains multiple workers and dispatches jobs from the queue to the workers.
+// The queue is the name of the queue that the dispatcher dispatches. The empty name means the default queue.
 type Dispatcher struct {
+	queue             string
 	queueManager      *QueueManager
 	store             *Store
+	timer             *Timer
+	breakers          *CircuitBreakers
+	started           func(job *structs.Job)
+	finished          func(job *structs.Job)
 	logger            echo.Logger
 	httpClientFactory func() *http.Client
-	workerWg          sync.WaitGroup
-	maxWorkers        int64
-	numWorkers        int64
+	statusPolicy      *structs.StatusPolicy
+	// cancelNotify sends DELETE to the job's url when the running job is canceled and it does not have the cancelUrl.
+	cancelNotify bool
+	// cancelGrace is the time to wait for the worker to finish after the notification before the request is canceled.
+	cancelGrace time.Duration
+	workerWg    sync.WaitGroup
+	maxWorkers  int64
+	numWorkers  int64
+	// stopCh stops the event loop when it is closed. doneCh is closed when the event loop stopped.
+	stopCh chan struct{}
+	doneCh chan struct{}
 }
 
 func defaultHttpClientFactory() *http.Client {
@@ -38,8 +55,14 @@ func defaultHttpClientFactory() *http.Client {
 }
 
 func (d *Dispatcher) EventLoop() {
+	defer close(d.doneCh)
+
 	for {
-		job := d.queueManager.Dequeue()
+		job := d.queueManager.DequeueOrStop(d.queue, d.stopCh)
+		if job == nil {
+			d.logger.Debug("dispatcher stopped")
+			return
+		}
 		d.logger.Debugf("dequeue job: %d", job.ID)
 
 		if atomic.LoadInt64(&d.maxWorkers) <= 0 {
@@ -82,16 +105,53 @@ func (d *Dispatcher) work(job *structs.Job) {
 
 	// worker error
 	var err error
+	// expired is true if the job passed its deadline before it started.
+	expired := false
+	// canceled is true if the job was canceled while it was waiting.
+	canceled := false
+	// accepted is true if the worker accepted the job to run it asynchronously.
+	accepted := false
 
 	// the terminating logic
 	defer func() {
+		if accepted {
+			// The job is finished by the callback from the worker.
+			d.logger.Infof("job: %d accepted to run asynchronously", job.ID)
+			return
+		}
+
 		d.logger.Infof("job: %d finished working", job.ID)
 		d.logger.Debugf("job: %d closing", job.ID)
 
-		// Update result status (success, failure or canceled).
+		// Update result status (success, failure, canceled or expired).
 		// If the evaluator has an error, write it to the output buf.
-		if err != nil {
+		if expired {
+			d.logger.Infof("job: %d expired", job.ID)
+			job.Success = false
+			job.Failure = false
+			job.Expired = true
+			job.Err = expiredJobErr(job)
+		} else if canceled {
+			d.logger.Infof("job: %d canceled before it started", job.ID)
+			job.Success = false
+			job.Failure = false
+		} else if err != nil {
 			d.logger.Errorf("worker error: %v", err)
+
+			if shouldRetry(job, err) {
+				// The job is not finished yet. It is enqueued again after the delay.
+				// startedAt is cleared, because the job is not running while waiting for the next attempt.
+				job.StartedAt = nil
+				job.Err = err.Error()
+
+				delay := retryDelay(job, err)
+				d.logger.Infof("job: %d failed on attempt %d. retrying in %v", job.ID, job.Attempt, delay)
+				if e := d.retry(job, delay); e != nil {
+					d.logger.Error(e)
+				}
+				return
+			}
+
 			job.Success = false
 			job.Failure = true
 			job.Err = err.Error()
@@ -108,6 +168,10 @@ func (d *Dispatcher) work(job *structs.Job) {
 			d.logger.Error(e)
 		}
 
+		if d.finished != nil {
+			d.finished(job)
+		}
+
 		d.logger.Debugf("job: %d closed", job.ID)
 	}()
 
@@ -116,10 +180,21 @@ func (d *Dispatcher) work(job *structs.Job) {
 	defer cancel()
 
 	// make job status running.
-	d.queueManager.RegisterRunningJob(job, cancel)
-	defer d.queueManager.RemoveRunningJob(job)
+	d.queueManager.RegisterRunningJob(job, d.cancelFunc(ctx, job, cancel))
+	defer func() {
+		// The accepted job is still running on the worker. It was moved to the async jobs.
+		if !accepted {
+			d.queueManager.RemoveRunningJob(job)
+		}
+	}()
 
 	if job.Canceled {
+		canceled = true
+		return
+	}
+
+	if isJobExpired(job, time.Now()) {
+		expired = true
 		return
 	}
 
@@ -127,12 +202,121 @@ func (d *Dispatcher) work(job *structs.Job) {
 	now := time.Now().UTC().Truncate(time.Millisecond)
 	// update startedAt
 	job.StartedAt = &now
+	job.Attempt++
+	// clear the result of the previous attempt
+	job.StatusCode = nil
+	job.Err = ""
+	job.Output = ""
+	job.AcceptedAt = nil
+	job.HeartbeatAt = nil
+	// The worker reports the progress and the result of the async job with the token of the attempt.
+	token, e := newCallbackToken()
+	if e != nil {
+		err = e
+		return
+	}
+	job.CallbackToken = token
 	if e := d.store.UpdateJob(job); e != nil {
 		d.logger.Error(e)
 	}
 
+	if d.started != nil {
+		d.started(job)
+	}
+
 	// run worker
 	err = d.runHttpWorker(ctx, job)
+	if err == errJobAccepted {
+		err = nil
+		accepted = !job.Canceled
+	}
+
+	// report the result to the circuit breaker. The canceled job does not tell anything about the host.
+	if d.breakers != nil && ctx.Err() == nil {
+		d.breakers.Report(job, err, time.Now())
+	}
+
+	if accepted {
+		d.accept(job)
+	}
+}
+
+// accept makes the job wait for the callback from the worker.
+// The job does not hold the dispatcher while the worker runs it, but it keeps the slots of the gates
+// like the concurrency key and the host limits until it finishes.
+func (d *Dispatcher) accept(job *structs.Job) {
+	// Truncate millisecond. It is compatible time for katsubushi ID generator timestamp.
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	job.AcceptedAt = &now
+	job.HeartbeatAt = &now
+	if e := d.store.UpdateJob(job); e != nil {
+		d.logger.Error(e)
+	}
+	d.queueManager.AcceptRunningJob(job)
+}
+
+// cancelFunc returns the function that cancels the running job.
+// It notifies the worker of the cancellation, and cancels the request after the grace period.
+// The worker can finish the job by itself within the grace period.
+func (d *Dispatcher) cancelFunc(ctx context.Context, job *structs.Job, cancel context.CancelFunc) context.CancelFunc {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			if job.CancelURL != "" || d.cancelNotify {
+				go notifyCancel(d.logger, d.httpClientFactory(), job)
+			}
+
+			if d.cancelGrace <= 0 {
+				cancel()
+				return
+			}
+
+			t := time.AfterFunc(d.cancelGrace, cancel)
+			go func() {
+				<-ctx.Done()
+				t.Stop()
+			}()
+		})
+	}
+}
+
+// notifyCancel tells the worker that the job is canceled.
+// It sends POST to the job's cancelUrl, or DELETE to the job's url if the job does not have the cancelUrl.
+func notifyCancel(logger echo.Logger, client *http.Client, job *structs.Job) {
+	method, u := http.MethodDelete, job.URL
+	if job.CancelURL != "" {
+		method, u = http.MethodPost, job.CancelURL
+	}
+
+	req, err := http.NewRequest(method, u, nil)
+	if err != nil {
+		logger.Error(errors.Wrapf(err, "job: %d failed to create the cancel notification", job.ID))
+		return
+	}
+
+	req.Header.Add("User-Agent", WorkerDefaultUserAgent)
+	req.Header.Add("X-Hq-Job-Id", fmt.Sprintf("%d", job.ID))
+	req.Header.Add("X-Hq-Job-Attempt", fmt.Sprintf("%d", job.Attempt))
+	req.Header.Add("X-Hq-Job-Canceled", "true")
+	for k, v := range job.Headers {
+		req.Header.Add(k, v)
+	}
+
+	client.Timeout = time.Duration(CancelNotificationTimeout) * time.Second
+
+	resp, err := client.Do(req)
+	if err != nil {
+		logger.Error(errors.Wrapf(err, "job: %d failed to send the cancel notification", job.ID))
+		return
+	}
+	defer resp.Body.Close()
+
+	logger.Infof("job: %d sent the cancel notification (%s %s): %d", job.ID, method, u, resp.StatusCode)
+}
+
+// retry enqueues the job again. If the delay is specified, the job is scheduled by the timer.
+func (d *Dispatcher) retry(job *structs.Job, delay time.Duration) error {
+	return retryJob(d.store, d.queueManager, d.timer, job, delay)
 }
 
 func (d *Dispatcher) runHttpWorker(ctx context.Context, job *structs.Job) error {
@@ -158,6 +342,8 @@ func (d *Dispatcher) runHttpWorker(ctx context.Context, job *structs.Job) error
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Add("User-Agent", WorkerDefaultUserAgent)
 	req.Header.Add("X-Hq-Job-Id", fmt.Sprintf("%d", job.ID))
+	req.Header.Add("X-Hq-Job-Attempt", fmt.Sprintf("%d", job.Attempt))
+	req.Header.Add(CallbackTokenHeader, job.CallbackToken)
 
 	// job specific headers
 	for k, v := range job.Headers {
@@ -183,11 +369,30 @@ func (d *Dispatcher) runHttpWorker(ctx context.Context, job *structs.Job) error
 	}
 	job.Output = string(body)
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf(http.StatusText(resp.StatusCode))
+	if job.Async && statusCode == http.StatusAccepted {
+		return errJobAccepted
 	}
 
-	return nil
+	switch classifyStatusCode(mergeStatusPolicy(d.statusPolicy, job.StatusPolicy), statusCode) {
+	case statusClassSuccess:
+		return nil
+	case statusClassRetryable:
+		return &StatusCodeError{
+			StatusCode: statusCode,
+			Retryable:  true,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
+	default:
+		return &StatusCodeError{
+			StatusCode: statusCode,
+			Retryable:  false,
+		}
+	}
+}
+
+// SetMaxWorkers changes the max number of the workers that run the jobs asynchronously.
+func (d *Dispatcher) SetMaxWorkers(maxWorkers int64) {
+	atomic.StoreInt64(&d.maxWorkers, maxWorkers)
 }
 
 // NumWorkers returns the number of workers that are working now.
@@ -195,6 +400,14 @@ func (d *Dispatcher) NumWorkers() int64 {
 	return atomic.LoadInt64(&d.numWorkers)
 }
 
+// Stop stops the event loop and waits for the running workers to finish.
+// The jobs in the queue are left to the other dispatchers.
+func (d *Dispatcher) Stop() {
+	close(d.stopCh)
+	<-d.doneCh
+	d.workerWg.Wait()
+}
+
 func (d *Dispatcher) Wait() {
 	for {
 		// wait for all queued jobs to finish
@@ -1,10 +1,12 @@
 package server
 
 import (
+	"bytes"
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -44,11 +46,12 @@ func TestDispatcher_EventLoop(t *testing.T) {
 
 		go d.EventLoop()
 
-		queueManager.EnqueueAsync(&structs.Job{
+		err := queueManager.Enqueue(&structs.Job{
 			ID:      1,
 			URL:     "http://example.com",
 			Payload: []byte(`{"message": "Hello World"}`),
 		})
+		assert.NoError(t, err)
 
 		d.Wait()
 	})
@@ -71,11 +74,12 @@ func TestDispatcher_EventLoop(t *testing.T) {
 		go d.EventLoop()
 
 		for i := 0; i < 5; i++ {
-			queueManager.EnqueueAsync(&structs.Job{
+			err := queueManager.Enqueue(&structs.Job{
 				ID:      uint64(i),
 				URL:     "http://example.com",
 				Payload: []byte(`{"message": "Hello World"}`),
 			})
+			assert.NoError(t, err)
 		}
 
 		d.Wait()
@@ -99,13 +103,192 @@ func TestDispatcher_EventLoop(t *testing.T) {
 		go d.EventLoop()
 
 		for i := 0; i < 10; i++ {
-			queueManager.EnqueueAsync(&structs.Job{
+			err := queueManager.Enqueue(&structs.Job{
 				ID:      uint64(i),
 				URL:     "http://example.com",
 				Payload: []byte(`{"message": "Hello World"}`),
 			})
+			assert.NoError(t, err)
 		}
 
 		d.Wait()
 	})
+
+	t.Run("retry a failed job", func(t *testing.T) {
+		queueManager := NewQueueManager(10)
+		d := testDispatcher(t, queueManager)
+		d.maxWorkers = 0
+
+		attempts := []string{}
+		d.httpClientFactory = func() *http.Client {
+			return testHttpClient(t, func(req *http.Request) *http.Response {
+				attempts = append(attempts, req.Header.Get("X-Hq-Job-Attempt"))
+
+				statusCode := http.StatusOK
+				if len(attempts) < 3 {
+					statusCode = http.StatusInternalServerError
+				}
+				return &http.Response{
+					StatusCode: statusCode,
+					Body:       ioutil.NopCloser(bytes.NewBuffer(nil)),
+					Header:     make(http.Header),
+				}
+			})
+		}
+
+		job := &structs.Job{
+			ID:         1,
+			URL:        "http://example.com",
+			MaxRetries: 3,
+		}
+		err := d.store.CreateJob(job)
+		assert.NoError(t, err)
+
+		go d.EventLoop()
+
+		err = queueManager.Enqueue(job)
+		assert.NoError(t, err)
+
+		d.Wait()
+
+		assert.Equal(t, []string{"1", "2", "3"}, attempts)
+
+		ret, err := d.store.GetJob(1)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, ret.Attempt)
+		assert.Equal(t, structs.JobStatusSuccess, ret.Status())
+	})
+
+	t.Run("skip an expired job", func(t *testing.T) {
+		queueManager := NewQueueManager(10)
+		d := testDispatcher(t, queueManager)
+		d.maxWorkers = 0
+		d.httpClientFactory = func() *http.Client {
+			return testHttpClient(t, func(req *http.Request) *http.Response {
+				t.Error("the expired job must not be sent")
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       ioutil.NopCloser(bytes.NewBuffer(nil)),
+					Header:     make(http.Header),
+				}
+			})
+		}
+
+		expiresAt := time.Now().Add(-1 * time.Second)
+		job := &structs.Job{
+			ID:        1,
+			URL:       "http://example.com",
+			ExpiresAt: &expiresAt,
+		}
+		err := d.store.CreateJob(job)
+		assert.NoError(t, err)
+
+		go d.EventLoop()
+
+		err = queueManager.Enqueue(job)
+		assert.NoError(t, err)
+
+		d.Wait()
+
+		ret, err := d.store.GetJob(1)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, ret.Attempt)
+		assert.Equal(t, structs.JobStatusExpired, ret.Status())
+	})
+
+	t.Run("notify the worker of the cancellation", func(t *testing.T) {
+		queueManager := NewQueueManager(10)
+		d := testDispatcher(t, queueManager)
+		d.maxWorkers = 0
+		d.cancelGrace = 5 * time.Second
+
+		started := make(chan struct{})
+		notified := make(chan struct{})
+		d.httpClientFactory = func() *http.Client {
+			return testHttpClient(t, func(req *http.Request) *http.Response {
+				if req.URL.Path == "/cancel" {
+					// the notification is sent to the cancelUrl.
+					assert.Equal(t, http.MethodPost, req.Method)
+					assert.Equal(t, "1", req.Header.Get("X-Hq-Job-Id"))
+					close(notified)
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       ioutil.NopCloser(bytes.NewBuffer(nil)),
+						Header:     make(http.Header),
+					}
+				}
+
+				close(started)
+				// the worker finishes the job within the grace period.
+				<-notified
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       ioutil.NopCloser(bytes.NewBufferString("canceled")),
+					Header:     make(http.Header),
+				}
+			})
+		}
+
+		job := &structs.Job{
+			ID:        1,
+			URL:       "http://example.com/job",
+			CancelURL: "http://example.com/cancel",
+		}
+		err := d.store.CreateJob(job)
+		assert.NoError(t, err)
+
+		go d.EventLoop()
+
+		err = queueManager.Enqueue(job)
+		assert.NoError(t, err)
+
+		<-started
+		queueManager.CancelJob(1)
+
+		d.Wait()
+
+		ret, err := d.store.GetJob(1)
+		assert.NoError(t, err)
+		assert.True(t, ret.Canceled)
+		assert.Equal(t, "canceled", ret.Output)
+	})
+
+	t.Run("accept an async job", func(t *testing.T) {
+		queueManager := NewQueueManager(10)
+		d := testDispatcher(t, queueManager)
+		d.maxWorkers = 0
+		d.httpClientFactory = func() *http.Client {
+			return testHttpClient(t, func(req *http.Request) *http.Response {
+				assert.NotEmpty(t, req.Header.Get(CallbackTokenHeader))
+				return &http.Response{
+					StatusCode: http.StatusAccepted,
+					Body:       ioutil.NopCloser(bytes.NewBuffer(nil)),
+					Header:     make(http.Header),
+				}
+			})
+		}
+
+		job := &structs.Job{
+			ID:    1,
+			URL:   "http://example.com",
+			Async: true,
+		}
+		err := d.store.CreateJob(job)
+		assert.NoError(t, err)
+
+		go d.EventLoop()
+
+		err = queueManager.Enqueue(job)
+		assert.NoError(t, err)
+
+		d.Wait()
+
+		ret, err := d.store.GetJob(1)
+		assert.NoError(t, err)
+		assert.Equal(t, structs.JobStatusRunningAsync, ret.Status())
+		assert.NotNil(t, ret.AcceptedAt)
+		assert.Nil(t, ret.FinishedAt)
+		assert.Equal(t, 0, queueManager.NumJobsRunning())
+		assert.Equal(t, 1, queueManager.NumJobsRunningAsync())
+	})
 }
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/kohkimakimoto/hq/internal/structs"
+)
+
+// DrainPollInterval is the interval to check whether the server has been drained.
+var DrainPollInterval = 500 * time.Millisecond
+
+// startDraining puts the server into the draining mode. In the mode, new jobs are rejected.
+func (a *App) startDraining() {
+	if atomic.CompareAndSwapInt32(&a.draining, 0, 1) {
+		a.Echo.Logger.Info("Started draining")
+	}
+}
+
+// stopDraining puts the server back into the normal mode.
+func (a *App) stopDraining() {
+	if atomic.CompareAndSwapInt32(&a.draining, 1, 0) {
+		a.Echo.Logger.Info("Stopped draining")
+	}
+}
+
+// isDraining reports whether the server is in the draining mode.
+func (a *App) isDraining() bool {
+	return atomic.LoadInt32(&a.draining) == 1
+}
+
+// drainState returns the state of the draining.
+// The server has been drained when there are no unfinished jobs (waiting, running, running asynchronously,
+// scheduled or blocked) and no workers are running.
+func (a *App) drainState() *structs.DrainState {
+	var numWorkers int64 = 0
+	for _, d := range a.dispatchers() {
+		numWorkers = numWorkers + d.NumWorkers()
+	}
+
+	state := &structs.DrainState{
+		Draining:            a.isDraining(),
+		NumJobsInQueue:      a.QueueManager.NumJobsInQueue(),
+		NumJobsWaiting:      a.QueueManager.NumJobsWaiting(),
+		NumJobsRunning:      a.QueueManager.NumJobsRunning(),
+		NumJobsRunningAsync: a.QueueManager.NumJobsRunningAsync(),
+		NumJobsScheduled:    a.QueueManager.NumJobsScheduled(),
+		NumJobsBlocked:      a.QueueManager.NumJobsBlocked(),
+		NumWorkers:          numWorkers,
+	}
+	state.Drained = state.Draining &&
+		state.NumJobsInQueue == 0 &&
+		state.NumJobsWaiting == 0 &&
+		state.NumJobsRunning == 0 &&
+		state.NumJobsRunningAsync == 0 &&
+		state.NumJobsScheduled == 0 &&
+		state.NumJobsBlocked == 0 &&
+		state.NumWorkers == 0
+
+	return state
+}
+
+// waitDrained blocks until the server has been drained or the context is done.
+func (a *App) waitDrained(ctx context.Context) *structs.DrainState {
+	ticker := time.NewTicker(DrainPollInterval)
+	defer ticker.Stop()
+
+	for {
+		state := a.drainState()
+		if state.Drained || !state.Draining {
+			return state
+		}
+
+		select {
+		case <-ctx.Done():
+			return a.drainState()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kohkimakimoto/hq/internal/structs"
+)
+
+func TestApp_Drain(t *testing.T) {
+	testInitApp(t)
+
+	assert.False(t, g.drainState().Draining)
+	assert.False(t, g.drainState().Drained)
+
+	job := &structs.Job{ID: 1, URL: "http://example.com"}
+	assert.NoError(t, g.submitJob(job))
+
+	g.startDraining()
+	state := g.drainState()
+	assert.True(t, state.Draining)
+	assert.False(t, state.Drained)
+	assert.Equal(t, 1, state.NumJobsInQueue)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.False(t, g.waitDrained(ctx).Drained)
+
+	ch := make(chan *structs.DrainState)
+	go func() {
+		ch <- g.waitDrained(context.Background())
+	}()
+
+	testFinishJob(t, job, true)
+
+	select {
+	case state := <-ch:
+		assert.True(t, state.Drained)
+	case <-time.After(3 * time.Second):
+		t.Fatal("waitDrained should return after the queue finished")
+	}
+
+	g.stopDraining()
+	assert.False(t, g.drainState().Draining)
+}
+
+func TestApp_DrainUnfinishedJobs(t *testing.T) {
+	testInitApp(t)
+
+	runAt := time.Now().Add(time.Hour)
+	scheduled := &structs.Job{ID: 1, URL: "http://example.com", RunAt: &runAt}
+	assert.NoError(t, g.submitJob(scheduled))
+	blocked := &structs.Job{ID: 2, URL: "http://example.com", DependsOn: structs.JobIDs{1}}
+	assert.NoError(t, g.submitJob(blocked))
+	testAsyncJob(t, 3)
+
+	g.startDraining()
+	state := g.drainState()
+	assert.False(t, state.Drained)
+	assert.Equal(t, 0, state.NumJobsInQueue)
+	assert.Equal(t, 1, state.NumJobsScheduled)
+	assert.Equal(t, 1, state.NumJobsBlocked)
+	assert.Equal(t, 1, state.NumJobsRunningAsync)
+
+	// canceling the parent also cancels the blocked job.
+	assert.NoError(t, g.cancelJob(g.QueueManager.LoadJobStatus(scheduled)))
+	assert.NoError(t, g.cancelAsyncJob(3))
+
+	state = g.drainState()
+	assert.True(t, state.Drained)
+}
@@ -2,6 +2,7 @@ package server
 
 import (
 	"net/http"
+	"strconv"
 
 	"github.com/labstack/echo/v4"
 
@@ -15,6 +16,34 @@ func NewValidationError(message string) *echo.HTTPError {
 	}
 }
 
+// QueueFullRetryAfter is the seconds that is sent by the 'Retry-After' header when the queue is full.
+var QueueFullRetryAfter = 5
+
+// NewQueueFullError returns an error that tells the client the queue is full and it should retry later.
+func NewQueueFullError(c echo.Context) *echo.HTTPError {
+	c.Response().Header().Set("Retry-After", strconv.Itoa(QueueFullRetryAfter))
+	return &echo.HTTPError{
+		Code:    http.StatusServiceUnavailable,
+		Message: "The queue is full. Retry later.",
+	}
+}
+
+// NewInvalidCallbackTokenError returns an error that tells the worker the callback token is missing or wrong.
+func NewInvalidCallbackTokenError() *echo.HTTPError {
+	return &echo.HTTPError{
+		Code:    http.StatusForbidden,
+		Message: "The callback token is invalid.",
+	}
+}
+
+// NewDrainingError returns an error that tells the client the server does not accept new jobs because it is draining.
+func NewDrainingError() *echo.HTTPError {
+	return &echo.HTTPError{
+		Code:    http.StatusServiceUnavailable,
+		Message: "The server is draining. Push the job to another server.",
+	}
+}
+
 func ErrorHandler(err error, c echo.Context) {
 	hErr := transformToHTTPError(err)
 	if hErr.Code >= 500 {
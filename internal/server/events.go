@@ -0,0 +1,227 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kohkimakimoto/hq/internal/structs"
+)
+
+// EventsKeepAliveInterval is the interval to send a comment to keep the idle event stream alive.
+var EventsKeepAliveInterval = 30 * time.Second
+
+// eventSubscriberBuffer is the number of the events that a subscriber can fall behind.
+// The subscriber that falls behind more is closed, and the client resumes the stream with the Last-Event-ID.
+const eventSubscriberBuffer = 256
+
+// PublishedEvent is an event in the log. The event is encoded when it is published, so that it is not changed later.
+type PublishedEvent struct {
+	ID     uint64
+	Type   string
+	JobID  uint64
+	Name   string
+	Status string
+	Data   []byte
+}
+
+// Events publishes the lifecycle events of the jobs to the subscribers.
+// It keeps the recent events in the bounded log, so that the subscribers can resume the stream.
+type Events struct {
+	mutex       sync.Mutex
+	nextID      func() (uint64, error)
+	size        int
+	log         []*PublishedEvent
+	subscribers map[*EventSubscriber]struct{}
+	closed      bool
+}
+
+// EventSubscriber receives the events that are published after it subscribed.
+type EventSubscriber struct {
+	events *Events
+	ch     chan *PublishedEvent
+}
+
+// NewEvents creates the events that keep the size of the recent events. The nextID generates the increasing event IDs.
+func NewEvents(size int, nextID func() (uint64, error)) *Events {
+	return &Events{
+		nextID:      nextID,
+		size:        size,
+		log:         []*PublishedEvent{},
+		subscribers: map[*EventSubscriber]struct{}{},
+	}
+}
+
+// Publish sends the event of the job to the subscribers.
+func (e *Events) Publish(typ string, job *structs.Job) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	// The ID is generated in the lock, so that the events are published in order of the IDs.
+	id, err := e.nextID()
+	if err != nil {
+		return errors.Wrap(err, "failed to generate uniq id")
+	}
+
+	data, err := json.Marshal(&structs.Event{
+		ID:   id,
+		Type: typ,
+		Time: time.Now().UTC().Truncate(time.Millisecond),
+		Job:  job,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to encode the event")
+	}
+
+	entry := &PublishedEvent{
+		ID:     id,
+		Type:   typ,
+		JobID:  job.ID,
+		Name:   job.Name,
+		Status: job.Status(),
+		Data:   data,
+	}
+
+	if e.size > 0 {
+		if len(e.log) >= e.size {
+			e.log = e.log[len(e.log)-e.size+1:]
+		}
+		e.log = append(e.log, entry)
+	}
+
+	for s := range e.subscribers {
+		select {
+		case s.ch <- entry:
+		default:
+			// The subscriber is too slow.
+			e.remove(s)
+		}
+	}
+
+	return nil
+}
+
+// Subscribe starts receiving the events. If the lastID is not 0, the events after it in the log are returned to resume the stream.
+// The subscriber must be closed after receiving.
+func (e *Events) Subscribe(lastID uint64) ([]*PublishedEvent, *EventSubscriber) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	s := &EventSubscriber{
+		events: e,
+		ch:     make(chan *PublishedEvent, eventSubscriberBuffer),
+	}
+	if e.closed {
+		close(s.ch)
+		return nil, s
+	}
+	e.subscribers[s] = struct{}{}
+
+	backlog := []*PublishedEvent{}
+	if lastID != 0 {
+		for _, entry := range e.log {
+			if entry.ID > lastID {
+				backlog = append(backlog, entry)
+			}
+		}
+	}
+
+	return backlog, s
+}
+
+// Close closes all the subscribers, and the later subscribers are closed immediately.
+func (e *Events) Close() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.closed = true
+	for s := range e.subscribers {
+		e.remove(s)
+	}
+}
+
+// Len returns the number of the subscribers.
+func (e *Events) Len() int {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	return len(e.subscribers)
+}
+
+func (e *Events) remove(s *EventSubscriber) {
+	if _, ok := e.subscribers[s]; ok {
+		delete(e.subscribers, s)
+		close(s.ch)
+	}
+}
+
+// C returns the channel to receive the events. It is closed when the subscriber is closed.
+func (s *EventSubscriber) C() <-chan *PublishedEvent {
+	return s.ch
+}
+
+// Close stops receiving the events.
+func (s *EventSubscriber) Close() {
+	s.events.mutex.Lock()
+	defer s.events.mutex.Unlock()
+
+	s.events.remove(s)
+}
+
+// eventFilter selects the events to stream.
+type eventFilter struct {
+	name   *regexp.Regexp
+	status string
+	id     uint64
+}
+
+func newEventFilter(req *structs.EventsRequest) (*eventFilter, error) {
+	f := &eventFilter{
+		status: req.Status,
+		id:     req.ID,
+	}
+	if req.Name != "" {
+		r, err := regexp.Compile(req.Name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid 'name': %v", err)
+		}
+		f.name = r
+	}
+	return f, nil
+}
+
+func (f *eventFilter) match(entry *PublishedEvent) bool {
+	if f.id != 0 && entry.JobID != f.id {
+		return false
+	}
+	if f.status != "" && entry.Status != f.status {
+		return false
+	}
+	if f.name != nil && !f.name.MatchString(entry.Name) {
+		return false
+	}
+	return true
+}
+
+// publishEvent publishes the event of the job with its latest state.
+func (a *App) publishEvent(typ string, job *structs.Job) {
+	logger := a.Echo.Logger
+
+	// The deleted job is not in the store anymore.
+	if typ != structs.EventTypeDeleted {
+		latest, err := a.Store.GetJob(job.ID)
+		if err != nil {
+			logger.Error(errors.Wrapf(err, "failed to load the job %d to publish the event", job.ID))
+			return
+		}
+		job = latest
+	}
+
+	if err := a.Events.Publish(typ, job); err != nil {
+		logger.Error(err)
+	}
+}
@@ -0,0 +1,159 @@
+package server
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kohkimakimoto/hq/internal/structs"
+)
+
+func testEvents(size int) *Events {
+	var id uint64
+	return NewEvents(size, func() (uint64, error) {
+		id++
+		return id, nil
+	})
+}
+
+func TestEvents_Publish(t *testing.T) {
+	e := testEvents(10)
+
+	_, sub := e.Subscribe(0)
+	assert.Equal(t, 1, e.Len())
+
+	assert.NoError(t, e.Publish(structs.EventTypePushed, &structs.Job{ID: 1, Name: "example", Waiting: true}))
+
+	ev := <-sub.C()
+	assert.Equal(t, uint64(1), ev.ID)
+	assert.Equal(t, structs.EventTypePushed, ev.Type)
+	assert.Equal(t, structs.JobStatusWaiting, ev.Status)
+
+	ret := &structs.Event{}
+	if err := json.Unmarshal(ev.Data, ret); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, uint64(1), ret.ID)
+	assert.Equal(t, structs.EventTypePushed, ret.Type)
+	assert.Equal(t, "example", ret.Job.Name)
+
+	sub.Close()
+	assert.Equal(t, 0, e.Len())
+
+	// closing the subscriber twice is safe.
+	sub.Close()
+}
+
+func TestEvents_Resume(t *testing.T) {
+	e := testEvents(3)
+
+	for i := uint64(1); i <= 5; i++ {
+		assert.NoError(t, e.Publish(structs.EventTypePushed, &structs.Job{ID: i}))
+	}
+
+	backlog, sub := e.Subscribe(0)
+	sub.Close()
+	assert.Len(t, backlog, 0)
+
+	backlog, sub = e.Subscribe(3)
+	sub.Close()
+	if assert.Len(t, backlog, 2) {
+		assert.Equal(t, uint64(4), backlog[0].ID)
+		assert.Equal(t, uint64(5), backlog[1].ID)
+	}
+
+	// the events before the log are dropped.
+	backlog, sub = e.Subscribe(1)
+	sub.Close()
+	if assert.Len(t, backlog, 3) {
+		assert.Equal(t, uint64(3), backlog[0].ID)
+	}
+}
+
+func TestEvents_SlowSubscriber(t *testing.T) {
+	e := testEvents(0)
+
+	_, sub := e.Subscribe(0)
+	for i := 0; i <= eventSubscriberBuffer; i++ {
+		assert.NoError(t, e.Publish(structs.EventTypePushed, &structs.Job{ID: 1}))
+	}
+	assert.Equal(t, 0, e.Len())
+
+	n := 0
+	for range sub.C() {
+		n++
+	}
+	assert.Equal(t, eventSubscriberBuffer, n)
+}
+
+func TestEvents_Close(t *testing.T) {
+	e := testEvents(10)
+
+	_, sub := e.Subscribe(0)
+	e.Close()
+
+	_, ok := <-sub.C()
+	assert.False(t, ok)
+
+	// the later subscriber is closed immediately.
+	_, sub = e.Subscribe(0)
+	_, ok = <-sub.C()
+	assert.False(t, ok)
+	assert.Equal(t, 0, e.Len())
+}
+
+func TestEventFilter(t *testing.T) {
+	ev := &PublishedEvent{JobID: 1, Name: "send-mail", Status: structs.JobStatusRunning}
+
+	f, err := newEventFilter(&structs.EventsRequest{})
+	assert.NoError(t, err)
+	assert.True(t, f.match(ev))
+
+	f, err = newEventFilter(&structs.EventsRequest{Name: "^send-", Status: structs.JobStatusRunning, ID: 1})
+	assert.NoError(t, err)
+	assert.True(t, f.match(ev))
+
+	f, err = newEventFilter(&structs.EventsRequest{Name: "^mail"})
+	assert.NoError(t, err)
+	assert.False(t, f.match(ev))
+
+	f, err = newEventFilter(&structs.EventsRequest{Status: structs.JobStatusSuccess})
+	assert.NoError(t, err)
+	assert.False(t, f.match(ev))
+
+	f, err = newEventFilter(&structs.EventsRequest{ID: 2})
+	assert.NoError(t, err)
+	assert.False(t, f.match(ev))
+
+	_, err = newEventFilter(&structs.EventsRequest{Name: "("})
+	assert.Error(t, err)
+}
+
+func TestApp_PublishEvents(t *testing.T) {
+	testInitApp(t)
+
+	_, sub := g.Events.Subscribe(0)
+	defer sub.Close()
+
+	job := &structs.Job{ID: 1, URL: "http://example.com"}
+	assert.NoError(t, g.submitJob(job))
+	testFinishJob(t, job, true)
+
+	// the scheduled job is canceled immediately.
+	runAt := time.Now().Add(1 * time.Hour)
+	job2 := &structs.Job{ID: 2, URL: "http://example.com", RunAt: &runAt}
+	assert.NoError(t, g.submitJob(job2))
+	assert.NoError(t, g.cancelJob(g.QueueManager.LoadJobStatus(job2)))
+
+	types := []string{}
+	statuses := []string{}
+	for i := 0; i < 4; i++ {
+		ev := <-sub.C()
+		types = append(types, ev.Type)
+		statuses = append(statuses, ev.Status)
+	}
+	assert.Equal(t, []string{structs.EventTypePushed, structs.EventTypeFinished, structs.EventTypePushed, structs.EventTypeCanceled}, types)
+	assert.Equal(t, []string{structs.JobStatusWaiting, structs.JobStatusSuccess, structs.JobStatusScheduled, structs.JobStatusCanceled}, statuses)
+}
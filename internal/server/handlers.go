@@ -1,9 +1,12 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"regexp"
 	"strconv"
 	"time"
 
@@ -25,6 +28,27 @@ func registerAPIHandlers(e *echo.Echo, prefix string) {
 	e.DELETE(prefix+"job/:id", DeleteJobHandler)
 	e.POST(prefix+"job/:id/stop", StopJobHandler)
 	e.POST(prefix+"job/:id/restart", RestartJobHandler)
+	e.POST(prefix+"job/:id/complete", CompleteJobHandler)
+	e.POST(prefix+"job/:id/heartbeat", HeartbeatJobHandler)
+	e.POST(prefix+"job/:id/progress", ProgressJobHandler)
+	e.GET(prefix+"job/:id/log", GetJobLogHandler)
+	e.GET(prefix+"job/:id/wait", WaitJobHandler)
+	e.GET(prefix+"events", EventsHandler)
+	e.POST(prefix+"schedule", CreateScheduleHandler)
+	e.GET(prefix+"schedule", ListSchedulesHandler)
+	e.GET(prefix+"schedule/:name", GetScheduleHandler)
+	e.DELETE(prefix+"schedule/:name", DeleteScheduleHandler)
+	e.POST(prefix+"schedule/:name/pause", PauseScheduleHandler)
+	e.POST(prefix+"schedule/:name/resume", ResumeScheduleHandler)
+	e.GET(prefix+"breakers", ListBreakersHandler)
+	e.POST(prefix+"batch", CreateBatchHandler)
+	e.POST(prefix+"pause", PauseHandler)
+	e.POST(prefix+"resume", ResumeHandler)
+	e.POST(prefix+"drain", DrainHandler)
+	e.GET(prefix+"drain", GetDrainHandler)
+	e.DELETE(prefix+"drain", StopDrainHandler)
+	e.POST(prefix+"admin/reload", ReloadHandler)
+	e.GET(prefix+"batch/:id", GetBatchHandler)
 }
 
 func InfoHandler(c echo.Context) error {
@@ -54,17 +78,172 @@ func PushJobHandler(c echo.Context) error {
 		return err
 	}
 
-	if req.URL == "" {
-		return NewValidationError("'url' is required")
+	// The wait is a query parameter, because the body is the job.
+	var wait time.Duration
+	if s := c.QueryParam("wait"); s != "" {
+		d, err := parseWaitTimeout("wait", s)
+		if err != nil {
+			return NewValidationError(err.Error())
+		}
+		wait = d
+	}
+
+	if g.isDraining() {
+		return NewDrainingError()
+	}
+
+	job, err := newJob(req)
+	if err != nil {
+		return err
+	}
+
+	if job.UniqueKey != "" {
+		job, _, err = g.submitUniqueJob(job)
+		if err == ErrQueueFull {
+			return NewQueueFullError(c)
+		}
+		if err != nil {
+			return err
+		}
+		return respondPushedJob(c, job, wait)
 	}
 
+	if err := g.submitJob(job); err != nil {
+		if err == ErrQueueFull {
+			return NewQueueFullError(c)
+		}
+		return err
+	}
+
+	return respondPushedJob(c, job, wait)
+}
+
+// respondPushedJob responds with the pushed job. If the wait is greater than 0, it waits for the job to finish
+// and responds with the finished job. If the job does not finish in time, it responds with 202 Accepted.
+func respondPushedJob(c echo.Context, job *structs.Job, wait time.Duration) error {
+	if wait == 0 {
+		return c.JSON(http.StatusOK, job)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), wait)
+	defer cancel()
+
+	ret, err := g.waitJob(ctx, job.ID)
+	if err != nil {
+		return err
+	}
+	if ret.FinishedAt == nil {
+		return c.JSON(http.StatusAccepted, ret)
+	}
+
+	return c.JSON(http.StatusOK, ret)
+}
+
+// newJob validates the request and creates a new job from it.
+func newJob(req *structs.PushJobRequest) (*structs.Job, error) {
 	if req.Name == "" {
 		req.Name = DefaultJobName
 	}
 
+	if req.Priority < MinJobPriority || req.Priority > MaxJobPriority {
+		return nil, NewValidationError(fmt.Sprintf("'priority' must be between %d and %d", MinJobPriority, MaxJobPriority))
+	}
+
+	if req.MaxRetries < 0 {
+		return nil, NewValidationError("'maxRetries' must not be negative")
+	}
+
+	if req.RetryDelay < 0 {
+		return nil, NewValidationError("'retryDelay' must not be negative")
+	}
+
+	if req.RetryBackoff != 0 && req.RetryBackoff < 1 {
+		return nil, NewValidationError("'retryBackoff' must be greater than or equal to 1")
+	}
+
+	if err := validateStatusPolicy(req.StatusPolicy); err != nil {
+		return nil, NewValidationError(err.Error())
+	}
+
+	if req.UniqueFor < 0 {
+		return nil, NewValidationError("'uniqueFor' must not be negative")
+	}
+
+	if req.CancelURL != "" {
+		if u, err := url.Parse(req.CancelURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return nil, NewValidationError("'cancelUrl' must be a http or https url but '" + req.CancelURL + "'.")
+		}
+	}
+
+	if req.NotifyURL != "" {
+		if u, err := url.Parse(req.NotifyURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return nil, NewValidationError("'notifyUrl' must be a http or https url but '" + req.NotifyURL + "'.")
+		}
+	}
+
+	if req.ConcurrencyLimit < 0 {
+		return nil, NewValidationError("'concurrencyLimit' must not be negative")
+	}
+
+	for _, parentID := range req.DependsOn {
+		if _, err := g.Store.GetJob(parentID); err != nil {
+			if _, ok := err.(*ErrJobNotFound); ok {
+				return nil, NewValidationError(fmt.Sprintf("The parent job %d is not found", parentID))
+			} else {
+				return nil, err
+			}
+		}
+	}
+
+	var runAt *time.Time
+	if req.RunAt != "" && req.Delay != 0 {
+		return nil, NewValidationError("'runAt' and 'delay' can not be specified at the same time")
+	}
+
+	if req.RunAt != "" {
+		t, err := time.Parse(time.RFC3339, req.RunAt)
+		if err != nil {
+			return nil, NewValidationError("'runAt' must be a RFC3339 formatted time but '" + req.RunAt + "'.")
+		}
+		t = t.UTC().Truncate(time.Millisecond)
+		runAt = &t
+	}
+
+	if req.Delay < 0 {
+		return nil, NewValidationError("'delay' must not be negative")
+	} else if req.Delay > 0 {
+		t := time.Now().UTC().Add(time.Duration(req.Delay) * time.Second).Truncate(time.Millisecond)
+		runAt = &t
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresAt != "" && req.TTL != 0 {
+		return nil, NewValidationError("'expiresAt' and 'ttl' can not be specified at the same time")
+	}
+
+	if req.ExpiresAt != "" {
+		t, err := time.Parse(time.RFC3339, req.ExpiresAt)
+		if err != nil {
+			return nil, NewValidationError("'expiresAt' must be a RFC3339 formatted time but '" + req.ExpiresAt + "'.")
+		}
+		t = t.UTC().Truncate(time.Millisecond)
+		expiresAt = &t
+	}
+
+	if req.TTL < 0 {
+		return nil, NewValidationError("'ttl' must not be negative")
+	} else if req.TTL > 0 {
+		t := time.Now().UTC().Add(time.Duration(req.TTL) * time.Second).Truncate(time.Millisecond)
+		expiresAt = &t
+	}
+
+	if runAt != nil && expiresAt != nil && !runAt.Before(*expiresAt) {
+		return nil, NewValidationError("The job expires before the time to run")
+	}
+
 	id, err := g.IdGen.NextID()
 	if err != nil {
-		return errors.Wrap(err, "failed to generate uniq id")
+		return nil, errors.Wrap(err, "failed to generate uniq id")
 	}
 
 	job := &structs.Job{}
@@ -76,14 +255,33 @@ func PushJobHandler(c echo.Context) error {
 	job.Payload = req.Payload
 	job.Headers = req.Headers
 	job.Timeout = req.Timeout
+	job.CancelURL = req.CancelURL
+	job.NotifyURL = req.NotifyURL
+	job.Async = req.Async
+	job.Queue = req.Queue
+	job.Priority = req.Priority
+	job.DependsOn = req.DependsOn
+	job.RunOnParentFailure = req.RunOnParentFailure
+	job.UniqueKey = req.UniqueKey
+	job.UniqueFor = req.UniqueFor
+	job.ConcurrencyKey = req.ConcurrencyKey
+	job.ConcurrencyLimit = req.ConcurrencyLimit
+	job.MaxRetries = req.MaxRetries
+	job.RetryDelay = req.RetryDelay
+	job.RetryBackoff = req.RetryBackoff
+	job.StatusPolicy = req.StatusPolicy
+	job.RunAt = runAt
+	job.ExpiresAt = expiresAt
 
-	if err := g.Store.CreateJob(job); err != nil {
-		return err
+	if err := g.resolveQueue(job); err != nil {
+		return nil, NewValidationError(err.Error())
 	}
 
-	g.QueueManager.EnqueueAsync(job)
+	if job.URL == "" {
+		return nil, NewValidationError("'url' is required")
+	}
 
-	return c.JSON(http.StatusOK, job)
+	return job, nil
 }
 
 func GetJobHandler(c echo.Context) error {
@@ -125,7 +323,7 @@ func RestartJobHandler(c echo.Context) error {
 		}
 	}
 
-	if job.Running {
+	if job.Running || job.RunningAsync {
 		return NewValidationError(fmt.Sprintf("The job %d is running now", job.ID))
 	}
 
@@ -133,6 +331,21 @@ func RestartJobHandler(c echo.Context) error {
 		return NewValidationError(fmt.Sprintf("The job %d is waiting now", job.ID))
 	}
 
+	if job.Scheduled {
+		return NewValidationError(fmt.Sprintf("The job %d is scheduled now", job.ID))
+	}
+
+	if job.Blocked {
+		return NewValidationError(fmt.Sprintf("The job %d is blocked now", job.ID))
+	}
+
+	if g.isDraining() {
+		return NewDrainingError()
+	}
+
+	// The job is restored if the queue is full.
+	original := *job
+
 	if req.Copy {
 		id, err := g.IdGen.NextID()
 		if err != nil {
@@ -141,34 +354,81 @@ func RestartJobHandler(c echo.Context) error {
 
 		job.ID = id
 		job.CreatedAt = katsubushi.ToTime(id)
+		job.Children = nil
+		job.BatchID = 0
+		job.UniqueKey = ""
+		job.UniqueFor = 0
 		job.StartedAt = nil
+		job.AcceptedAt = nil
+		job.HeartbeatAt = nil
 		job.FinishedAt = nil
 		job.Failure = false
 		job.Success = false
 		job.Canceled = false
+		job.Expired = false
 		job.StatusCode = nil
 		job.Err = ""
 		job.Output = ""
+		job.CallbackToken = ""
+		job.Attempt = 0
+		job.RunAt = nil
+		// The deadline has been passed in most cases, so the restarted job does not expire.
+		job.ExpiresAt = nil
+		job.Progress = nil
+		job.Deliveries = nil
 
 		if err := g.Store.CreateJob(job); err != nil {
 			return err
 		}
 	} else {
 		job.StartedAt = nil
+		job.AcceptedAt = nil
+		job.HeartbeatAt = nil
 		job.FinishedAt = nil
 		job.Failure = false
 		job.Success = false
 		job.Canceled = false
+		job.Expired = false
 		job.StatusCode = nil
 		job.Err = ""
 		job.Output = ""
+		job.CallbackToken = ""
+		job.Attempt = 0
+		job.RunAt = nil
+		job.ExpiresAt = nil
+		job.Progress = nil
+		job.Deliveries = nil
 
 		if err := g.Store.UpdateJob(job); err != nil {
 			return err
 		}
 	}
 
-	g.QueueManager.EnqueueAsync(job)
+	if err := g.QueueManager.Enqueue(job); err != nil {
+		if err == ErrQueueFull {
+			if req.Copy {
+				err = g.Store.DeleteJob(job.ID)
+			} else {
+				err = g.Store.UpdateJob(&original)
+			}
+			if err != nil {
+				c.Logger().Error(err)
+			}
+			return NewQueueFullError(c)
+		}
+		return errors.Wrap(err, "failed to enqueue the job")
+	}
+
+	if !req.Copy {
+		// The progress and the deliveries of the previous run are deleted after the job was accepted.
+		if err := g.Store.DeleteJobProgress(job.ID); err != nil {
+			return err
+		}
+		if err := g.Store.DeleteDeliveries(job.ID); err != nil {
+			return err
+		}
+	}
+	g.publishEvent(structs.EventTypePushed, job)
 
 	return c.JSON(http.StatusOK, job)
 }
@@ -188,17 +448,269 @@ func StopJobHandler(c echo.Context) error {
 		}
 	}
 
-	if !job.Running && !job.Waiting {
+	if !job.Running && !job.RunningAsync && !job.Waiting && !job.Scheduled && !job.Blocked {
 		return NewValidationError(fmt.Sprintf("The job %d is not active", job.ID))
 	}
 
-	g.QueueManager.CancelJob(job.ID)
+	if err := g.cancelJob(job); err != nil {
+		return err
+	}
 
 	return c.JSON(http.StatusOK, &structs.StoppedJob{
 		ID: id,
 	})
 }
 
+func CompleteJobHandler(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return NewValidationError("The job id must be a number but '" + c.Param("id") + "'.")
+	}
+
+	req := &structs.CompleteJobRequest{}
+	if err := bindRequest(req, c); err != nil {
+		c.Logger().Warn(errors.Wrap(err, "failed to bind request"))
+		return err
+	}
+
+	if req.StatusCode != 0 && (req.StatusCode < 100 || req.StatusCode > 599) {
+		return NewValidationError(fmt.Sprintf("'statusCode' must be a http status code but '%d'.", req.StatusCode))
+	}
+
+	job, err := g.Store.GetJob(id)
+	if err != nil {
+		if _, ok := err.(*ErrJobNotFound); ok {
+			return NewValidationError(err.Error())
+		} else {
+			return err
+		}
+	}
+
+	if !validCallbackToken(job, c.Request().Header.Get(CallbackTokenHeader)) {
+		return NewInvalidCallbackTokenError()
+	}
+
+	completed, err := g.completeAsyncJob(id, req)
+	if err != nil {
+		return err
+	}
+	if !completed {
+		return NewValidationError(fmt.Sprintf("The job %d is not running asynchronously", job.ID))
+	}
+
+	job, err = g.Store.GetJob(id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, job)
+}
+
+func HeartbeatJobHandler(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return NewValidationError("The job id must be a number but '" + c.Param("id") + "'.")
+	}
+
+	job, err := g.Store.GetJob(id)
+	if err != nil {
+		if _, ok := err.(*ErrJobNotFound); ok {
+			return NewValidationError(err.Error())
+		} else {
+			return err
+		}
+	}
+
+	if !validCallbackToken(job, c.Request().Header.Get(CallbackTokenHeader)) {
+		return NewInvalidCallbackTokenError()
+	}
+
+	if !g.QueueManager.HeartbeatAsyncJob(id, time.Now().UTC().Truncate(time.Millisecond)) {
+		return NewValidationError(fmt.Sprintf("The job %d is not running asynchronously", job.ID))
+	}
+
+	return c.JSON(http.StatusOK, g.QueueManager.LoadJobStatus(job))
+}
+
+func ProgressJobHandler(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return NewValidationError("The job id must be a number but '" + c.Param("id") + "'.")
+	}
+
+	req := &structs.ProgressJobRequest{}
+	if err := bindRequest(req, c); err != nil {
+		c.Logger().Warn(errors.Wrap(err, "failed to bind request"))
+		return err
+	}
+
+	if req.Percent != nil && (*req.Percent < 0 || *req.Percent > 100) {
+		return NewValidationError("The percent must be between 0 and 100.")
+	}
+
+	job, err := g.Store.GetJob(id)
+	if err != nil {
+		if _, ok := err.(*ErrJobNotFound); ok {
+			return NewValidationError(err.Error())
+		} else {
+			return err
+		}
+	}
+
+	if !validCallbackToken(job, c.Request().Header.Get(CallbackTokenHeader)) {
+		return NewInvalidCallbackTokenError()
+	}
+
+	if !job.Running && !job.RunningAsync {
+		return NewValidationError(fmt.Sprintf("The job %d is not running", job.ID))
+	}
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	progress, err := g.Store.ReportJobProgress(job, req, now)
+	if err != nil {
+		return err
+	}
+	job.Progress = progress
+
+	// The progress of the async job is also a heartbeat.
+	if job.RunningAsync {
+		g.QueueManager.HeartbeatAsyncJob(id, now)
+	}
+	g.publishEvent(structs.EventTypeProgress, job)
+
+	return c.JSON(http.StatusOK, g.QueueManager.LoadJobStatus(job))
+}
+
+func GetJobLogHandler(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return NewValidationError("The job id must be a number but '" + c.Param("id") + "'.")
+	}
+
+	req := &structs.GetJobLogRequest{}
+	if err := bindRequest(req, c); err != nil {
+		c.Logger().Warn(errors.Wrap(err, "failed to bind request"))
+		return err
+	}
+
+	if _, err := g.Store.GetJob(id); err != nil {
+		if _, ok := err.(*ErrJobNotFound); ok {
+			return NewValidationError(err.Error())
+		} else {
+			return err
+		}
+	}
+
+	jobLog, err := g.Store.GetJobLog(id, req.After, req.Limit)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, jobLog)
+}
+
+func WaitJobHandler(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return NewValidationError("The job id must be a number but '" + c.Param("id") + "'.")
+	}
+
+	req := &structs.WaitJobRequest{}
+	if err := bindRequest(req, c); err != nil {
+		c.Logger().Warn(errors.Wrap(err, "failed to bind request"))
+		return err
+	}
+
+	timeout, err := parseWaitTimeout("timeout", req.Timeout)
+	if err != nil {
+		return NewValidationError(err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), timeout)
+	defer cancel()
+
+	job, err := g.waitJob(ctx, id)
+	if err != nil {
+		if _, ok := err.(*ErrJobNotFound); ok {
+			return NewValidationError(err.Error())
+		} else {
+			return err
+		}
+	}
+
+	return c.JSON(http.StatusOK, job)
+}
+
+func EventsHandler(c echo.Context) error {
+	req := &structs.EventsRequest{}
+	if err := bindRequest(req, c); err != nil {
+		c.Logger().Warn(errors.Wrap(err, "failed to bind request"))
+		return err
+	}
+
+	filter, err := newEventFilter(req)
+	if err != nil {
+		return NewValidationError(err.Error())
+	}
+
+	// The client resumes the stream with the id of the last received event.
+	var lastID uint64
+	if v := c.Request().Header.Get("Last-Event-ID"); v != "" {
+		lastID, err = strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return NewValidationError("The Last-Event-ID must be a number but '" + v + "'.")
+		}
+	}
+
+	backlog, sub := g.Events.Subscribe(lastID)
+	defer sub.Close()
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.Header().Set("Connection", "keep-alive")
+	res.WriteHeader(http.StatusOK)
+
+	write := func(ev *PublishedEvent) error {
+		if !filter.match(ev) {
+			return nil
+		}
+		_, err := fmt.Fprintf(res, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, ev.Data)
+		return err
+	}
+
+	for _, ev := range backlog {
+		if err := write(ev); err != nil {
+			return nil
+		}
+	}
+	res.Flush()
+
+	ticker := time.NewTicker(EventsKeepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case ev, ok := <-sub.C():
+			if !ok {
+				// The server is shutting down, or the client is too slow.
+				return nil
+			}
+			if err := write(ev); err != nil {
+				return nil
+			}
+			res.Flush()
+		case <-ticker.C:
+			if _, err := fmt.Fprint(res, ": keep-alive\n\n"); err != nil {
+				return nil
+			}
+			res.Flush()
+		case <-c.Request().Context().Done():
+			return nil
+		}
+	}
+}
+
 func DeleteJobHandler(c echo.Context) error {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
 	if err != nil {
@@ -214,7 +726,7 @@ func DeleteJobHandler(c echo.Context) error {
 		}
 	}
 
-	if job.Running {
+	if job.Running || job.RunningAsync {
 		return NewValidationError(fmt.Sprintf("The job %d is running now", job.ID))
 	}
 
@@ -222,6 +734,14 @@ func DeleteJobHandler(c echo.Context) error {
 		return NewValidationError(fmt.Sprintf("The job %d is waiting now", job.ID))
 	}
 
+	if job.Scheduled {
+		return NewValidationError(fmt.Sprintf("The job %d is scheduled now", job.ID))
+	}
+
+	if job.Blocked {
+		return NewValidationError(fmt.Sprintf("The job %d is blocked now", job.ID))
+	}
+
 	if err := g.Store.DeleteJob(id); err != nil {
 		if _, ok := err.(*ErrJobNotFound); ok {
 			return NewValidationError(err.Error())
@@ -229,6 +749,7 @@ func DeleteJobHandler(c echo.Context) error {
 			return err
 		}
 	}
+	g.publishEvent(structs.EventTypeDeleted, job)
 
 	return c.JSON(http.StatusOK, &structs.DeletedJob{
 		ID: id,
@@ -243,7 +764,7 @@ func ListJobsHandler(c echo.Context) error {
 	}
 
 	if req.Limit == 0 {
-		req.Limit = g.Config.JobListDefaultLimit
+		req.Limit = g.config().JobListDefaultLimit
 	}
 
 	query := &ListJobsQuery{
@@ -265,6 +786,94 @@ func ListJobsHandler(c echo.Context) error {
 	return c.JSON(http.StatusOK, list)
 }
 
+func CreateScheduleHandler(c echo.Context) error {
+	req := &structs.CreateScheduleRequest{}
+	if err := bindRequest(req, c); err != nil {
+		c.Logger().Warn(errors.Wrap(err, "failed to bind request"))
+		return err
+	}
+
+	schedule := &structs.Schedule{
+		Name:     req.Name,
+		Cron:     req.Cron,
+		Timezone: req.Timezone,
+		Overlap:  req.Overlap,
+		CatchUp:  req.CatchUp,
+		Paused:   req.Paused,
+		Job:      req.Job,
+	}
+
+	if err := validateSchedule(schedule); err != nil {
+		return NewValidationError(err.Error())
+	}
+
+	if err := g.validateScheduleQueue(schedule); err != nil {
+		return NewValidationError(err.Error())
+	}
+
+	schedule, err := g.Scheduler.Put(schedule)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, schedule)
+}
+
+func ListSchedulesHandler(c echo.Context) error {
+	return c.JSON(http.StatusOK, &structs.ScheduleList{
+		Schedules: g.Scheduler.List(),
+	})
+}
+
+func GetScheduleHandler(c echo.Context) error {
+	schedule, err := g.Scheduler.Get(c.Param("name"))
+	if err != nil {
+		if _, ok := err.(*ErrScheduleNotFound); ok {
+			return NewValidationError(err.Error())
+		} else {
+			return err
+		}
+	}
+
+	return c.JSON(http.StatusOK, schedule)
+}
+
+func DeleteScheduleHandler(c echo.Context) error {
+	name := c.Param("name")
+	if err := g.Scheduler.Delete(name); err != nil {
+		if _, ok := err.(*ErrScheduleNotFound); ok {
+			return NewValidationError(err.Error())
+		} else {
+			return err
+		}
+	}
+
+	return c.JSON(http.StatusOK, &structs.DeletedSchedule{
+		Name: name,
+	})
+}
+
+func PauseScheduleHandler(c echo.Context) error {
+	return setSchedulePaused(c, true)
+}
+
+func ResumeScheduleHandler(c echo.Context) error {
+	return setSchedulePaused(c, false)
+}
+
+func setSchedulePaused(c echo.Context, paused bool) error {
+	schedule, err := g.Scheduler.SetPaused(c.Param("name"), paused)
+	if err != nil {
+		if _, ok := err.(*ErrScheduleNotFound); ok {
+			return NewValidationError(err.Error())
+		} else {
+			return err
+		}
+	}
+
+	return c.JSON(http.StatusOK, schedule)
+}
+
 func UIIndexHandler(c echo.Context) error {
 	return c.Render(http.StatusOK, "index.html", nil)
 }
@@ -281,7 +890,7 @@ func UIDashboardApiHandler(c echo.Context) error {
 	}
 
 	if req.Limit == 0 {
-		req.Limit = g.Config.JobListDefaultLimit
+		req.Limit = g.config().JobListDefaultLimit
 	}
 
 	query := &ListJobsQuery{
@@ -314,8 +923,10 @@ func UIDashboardApiHandler(c echo.Context) error {
 }
 
 func getStats() (*structs.Stats, error) {
+	config := g.config()
+
 	var numAllWorkers int64 = 0
-	for _, d := range g.Dispatchers {
+	for _, d := range g.dispatchers() {
 		numAllWorkers = numAllWorkers + d.NumWorkers()
 	}
 
@@ -334,16 +945,39 @@ func getStats() (*structs.Stats, error) {
 		return nil, err
 	}
 
+	pauses := g.Pauses.State()
+
+	namedQueues := map[string]*structs.QueueStats{}
+	for name, q := range config.NamedQueues {
+		stats := g.QueueManager.QueueStats(name)
+		stats.Concurrency = q.Concurrency
+		for _, d := range g.dispatchers() {
+			if d.queue == name {
+				stats.NumWorkers = stats.NumWorkers + d.NumWorkers()
+			}
+		}
+		namedQueues[name] = stats
+	}
+
 	return &structs.Stats{
-		Queues:              g.Config.Queues,
-		Dispatchers:         g.Config.Dispatchers,
-		MaxWorkers:          g.Config.MaxWorkers,
-		NumWorkers:          numAllWorkers,
-		NumJobsInQueue:      g.QueueManager.NumJobsInQueue(),
-		NumJobsWaiting:      g.QueueManager.NumJobsWaiting(),
-		NumJobsRunning:      g.QueueManager.NumJobsRunning(),
-		NumStoredJobs:       numJobs,
-		NumJobsInLastMinute: list.Count,
+		Queues:                   config.Queues,
+		Dispatchers:              config.Dispatchers,
+		MaxWorkers:               config.MaxWorkers,
+		NumWorkers:               numAllWorkers,
+		NumJobsInQueue:           g.QueueManager.NumJobsInQueue(),
+		NumJobsWaiting:           g.QueueManager.NumJobsWaiting(),
+		NumJobsRunning:           g.QueueManager.NumJobsRunning(),
+		NumJobsRunningAsync:      g.QueueManager.NumJobsRunningAsync(),
+		NumJobsScheduled:         g.QueueManager.NumJobsScheduled(),
+		NumJobsBlocked:           g.QueueManager.NumJobsBlocked(),
+		NumJobsWaitingByPriority: g.QueueManager.NumJobsWaitingByPriority(),
+		NamedQueues:              namedQueues,
+		NumBreakersOpen:          g.CircuitBreakers.NumOpen(),
+		Paused:                   pauses.Paused,
+		PausedNames:              pauses.Names,
+		Draining:                 g.isDraining(),
+		NumStoredJobs:            numJobs,
+		NumJobsInLastMinute:      list.Count,
 	}, nil
 }
 
@@ -365,3 +999,150 @@ func bindRequest(req interface{}, c echo.Context) error {
 
 	return nil
 }
+
+func ListBreakersHandler(c echo.Context) error {
+	return c.JSON(http.StatusOK, &structs.BreakerList{
+		Breakers: g.CircuitBreakers.List(),
+	})
+}
+
+func CreateBatchHandler(c echo.Context) error {
+	req := &structs.CreateBatchRequest{}
+	if err := bindRequest(req, c); err != nil {
+		c.Logger().Warn(errors.Wrap(err, "failed to bind request"))
+		return err
+	}
+
+	if len(req.Jobs) == 0 {
+		return NewValidationError("'jobs' must have at least one job")
+	}
+
+	if req.OnComplete != "" {
+		if u, err := url.Parse(req.OnComplete); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return NewValidationError("'onComplete' must be a http or https url but '" + req.OnComplete + "'.")
+		}
+	}
+
+	if g.isDraining() {
+		return NewDrainingError()
+	}
+
+	jobs := make([]*structs.Job, 0, len(req.Jobs))
+	for i, jobReq := range req.Jobs {
+		if jobReq == nil {
+			return NewValidationError(fmt.Sprintf("jobs[%d]: the job must not be null", i))
+		}
+		if jobReq.UniqueKey != "" {
+			return NewValidationError(fmt.Sprintf("jobs[%d]: 'uniqueKey' can not be used in a batch", i))
+		}
+		job, err := newJob(jobReq)
+		if err != nil {
+			if hErr, ok := err.(*echo.HTTPError); ok && hErr.Code == http.StatusUnprocessableEntity {
+				return NewValidationError(fmt.Sprintf("jobs[%d]: %v", i, hErr.Message))
+			}
+			return err
+		}
+		jobs = append(jobs, job)
+	}
+
+	batch, err := g.submitBatch(jobs, req.OnComplete)
+	if err == ErrQueueFull {
+		return NewQueueFullError(c)
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, batch)
+}
+
+func GetBatchHandler(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return NewValidationError("The batch id must be a number but '" + c.Param("id") + "'.")
+	}
+
+	batch, err := g.Store.GetBatch(id)
+	if err != nil {
+		if _, ok := err.(*ErrBatchNotFound); ok {
+			return NewValidationError(err.Error())
+		} else {
+			return err
+		}
+	}
+
+	return c.JSON(http.StatusOK, batch)
+}
+
+func PauseHandler(c echo.Context) error {
+	req := &structs.PauseRequest{}
+	if err := bindRequest(req, c); err != nil {
+		c.Logger().Warn(errors.Wrap(err, "failed to bind request"))
+		return err
+	}
+
+	if _, err := regexp.Compile(req.Name); err != nil {
+		return NewValidationError("'name' must be a valid regular expression: " + err.Error())
+	}
+
+	if err := g.pauseDispatching(req.Name); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, g.Pauses.State())
+}
+
+func ResumeHandler(c echo.Context) error {
+	req := &structs.PauseRequest{}
+	if err := bindRequest(req, c); err != nil {
+		c.Logger().Warn(errors.Wrap(err, "failed to bind request"))
+		return err
+	}
+
+	if err := g.resumeDispatching(req.Name); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, g.Pauses.State())
+}
+
+func DrainHandler(c echo.Context) error {
+	req := &structs.DrainRequest{}
+	if err := bindRequest(req, c); err != nil {
+		c.Logger().Warn(errors.Wrap(err, "failed to bind request"))
+		return err
+	}
+
+	if req.Wait < 0 {
+		return NewValidationError("'wait' must not be negative")
+	}
+
+	g.startDraining()
+
+	if req.Wait == 0 {
+		return c.JSON(http.StatusOK, g.drainState())
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), time.Duration(req.Wait)*time.Second)
+	defer cancel()
+
+	return c.JSON(http.StatusOK, g.waitDrained(ctx))
+}
+
+func GetDrainHandler(c echo.Context) error {
+	return c.JSON(http.StatusOK, g.drainState())
+}
+
+func StopDrainHandler(c echo.Context) error {
+	g.stopDraining()
+	return c.JSON(http.StatusOK, g.drainState())
+}
+
+func ReloadHandler(c echo.Context) error {
+	result, err := g.reloadConfig()
+	if err != nil {
+		return NewValidationError(err.Error())
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
@@ -3,10 +3,13 @@ package server
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"runtime"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -76,6 +79,583 @@ func TestCreateJobHandler(t *testing.T) {
 		assert.Equal(t, false, job.Failure)
 		assert.Equal(t, false, job.Success)
 	})
+
+	t.Run("invalid cancelUrl", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/job", bytes.NewBufferString(`{"url": "https://your-worker-app-server/example", "cancelUrl": "your-worker-app-server/cancel"}`))
+		req.Header.Set("Content-Type", "application/json")
+		res := httptest.NewRecorder()
+		testInitApp(t)
+		g.Echo.ServeHTTP(res, req)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, res.Code)
+	})
+}
+
+func TestCreateJobHandler_QueueFull(t *testing.T) {
+	testInitApp(t)
+	g.QueueManager.capacity = 1
+	err := g.QueueManager.Enqueue(&structs.Job{ID: 1})
+	assert.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/job", bytes.NewBufferString(`{"url": "https://your-worker-app-server/example"}`))
+	req.Header.Set("Content-Type", "application/json")
+	res := httptest.NewRecorder()
+	g.Echo.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, res.Code)
+	assert.Equal(t, "5", res.Header().Get("Retry-After"))
+	assert.Equal(t, 1, g.QueueManager.NumJobsInQueue())
+
+	// the rejected job is not stored.
+	n, err := g.Store.CountJobs()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	// the batch is rejected as a whole.
+	req = httptest.NewRequest(http.MethodPost, "/batch", bytes.NewBufferString(`{"jobs": [{"url": "https://your-worker-app-server/example"}]}`))
+	req.Header.Set("Content-Type", "application/json")
+	res = httptest.NewRecorder()
+	g.Echo.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, res.Code)
+	n, err = g.Store.CountJobs()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+}
+
+func TestCreateJobHandler_NamedQueue(t *testing.T) {
+	testInitApp(t)
+	g.Config.NamedQueues = map[string]*NamedQueueConfig{
+		"slow": {
+			Concurrency: 1,
+			URL:         "https://your-worker-app-server/slow",
+			Timeout:     30,
+			Headers: map[string]string{
+				"X-Queue":  "slow",
+				"X-Header": "queue",
+			},
+		},
+	}
+	g.QueueManager.AddQueue("slow")
+
+	t.Run("success", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/job", bytes.NewBufferString(`{"queue": "slow", "headers": {"X-Header": "job"}}`))
+		req.Header.Set("Content-Type", "application/json")
+		res := httptest.NewRecorder()
+		g.Echo.ServeHTTP(res, req)
+
+		assert.Equal(t, http.StatusOK, res.Code)
+
+		job := &structs.Job{}
+		if err := json.Unmarshal(res.Body.Bytes(), job); err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, "slow", job.Queue)
+		assert.Equal(t, "https://your-worker-app-server/slow", job.URL)
+		assert.Equal(t, int64(30), job.Timeout)
+		assert.Equal(t, "slow", job.Headers["X-Queue"])
+		assert.Equal(t, "job", job.Headers["X-Header"])
+		assert.Equal(t, 1, g.QueueManager.QueueStats("slow").NumJobsInQueue)
+	})
+
+	t.Run("default", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/job", bytes.NewBufferString(`{"queue": "default", "url": "https://your-worker-app-server/example"}`))
+		req.Header.Set("Content-Type", "application/json")
+		res := httptest.NewRecorder()
+		g.Echo.ServeHTTP(res, req)
+
+		assert.Equal(t, http.StatusOK, res.Code)
+
+		job := &structs.Job{}
+		if err := json.Unmarshal(res.Body.Bytes(), job); err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, "", job.Queue)
+	})
+
+	t.Run("unknown queue", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/job", bytes.NewBufferString(`{"queue": "unknown", "url": "https://your-worker-app-server/example"}`))
+		req.Header.Set("Content-Type", "application/json")
+		res := httptest.NewRecorder()
+		g.Echo.ServeHTTP(res, req)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, res.Code)
+	})
+}
+
+func TestCreateJobHandler_DependsOn(t *testing.T) {
+	testInitApp(t)
+	assert.NoError(t, g.submitJob(&structs.Job{ID: 1, URL: "https://your-worker-app-server/example"}))
+
+	t.Run("success", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/job", bytes.NewBufferString(`{"url": "https://your-worker-app-server/example", "dependsOn": ["1"]}`))
+		req.Header.Set("Content-Type", "application/json")
+		res := httptest.NewRecorder()
+		g.Echo.ServeHTTP(res, req)
+
+		assert.Equal(t, http.StatusOK, res.Code)
+
+		job := &structs.Job{}
+		if err := json.Unmarshal(res.Body.Bytes(), job); err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, structs.JobIDs{1}, job.DependsOn)
+		assert.True(t, job.Blocked)
+	})
+
+	t.Run("parent not found", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/job", bytes.NewBufferString(`{"url": "https://your-worker-app-server/example", "dependsOn": ["100"]}`))
+		req.Header.Set("Content-Type", "application/json")
+		res := httptest.NewRecorder()
+		g.Echo.ServeHTTP(res, req)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, res.Code)
+	})
+}
+
+func TestCreateJobHandler_UniqueKey(t *testing.T) {
+	testInitApp(t)
+
+	push := func(body string) (int, *structs.Job) {
+		req := httptest.NewRequest(http.MethodPost, "/job", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		res := httptest.NewRecorder()
+		g.Echo.ServeHTTP(res, req)
+
+		job := &structs.Job{}
+		if res.Code == http.StatusOK {
+			if err := json.Unmarshal(res.Body.Bytes(), job); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return res.Code, job
+	}
+
+	code, job1 := push(`{"url": "https://your-worker-app-server/example", "uniqueKey": "key"}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "key", job1.UniqueKey)
+
+	code, job2 := push(`{"url": "https://your-worker-app-server/example", "uniqueKey": "key"}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, job1.ID, job2.ID)
+	assert.Equal(t, 1, g.QueueManager.NumJobsInQueue())
+
+	code, job3 := push(`{"url": "https://your-worker-app-server/example", "uniqueKey": "other"}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.NotEqual(t, job1.ID, job3.ID)
+
+	code, _ = push(`{"url": "https://your-worker-app-server/example", "uniqueKey": "key", "uniqueFor": -1}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+}
+
+func TestCreateJobHandler_Wait(t *testing.T) {
+	testInitApp(t)
+
+	push := func(wait string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/job?wait="+wait, bytes.NewBufferString(`{"url": "https://your-worker-app-server/example"}`))
+		req.Header.Set("Content-Type", "application/json")
+		res := httptest.NewRecorder()
+		g.Echo.ServeHTTP(res, req)
+		return res
+	}
+
+	res := push("abc")
+	assert.Equal(t, http.StatusUnprocessableEntity, res.Code)
+	assert.Equal(t, 0, g.QueueManager.NumJobsInQueue())
+
+	// the wait expires, because no dispatcher runs the job.
+	res = push("100ms")
+	assert.Equal(t, http.StatusAccepted, res.Code)
+
+	job := &structs.Job{}
+	if err := json.Unmarshal(res.Body.Bytes(), job); err != nil {
+		t.Fatal(err)
+	}
+	assert.NotEqual(t, uint64(0), job.ID)
+	assert.Nil(t, job.FinishedAt)
+	testFinishJob(t, job, true)
+
+	// the job finishes while the request waits.
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- push("10s")
+	}()
+	for g.JobWaiters.Len() == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	job2 := g.QueueManager.Dequeue("")
+	g.QueueManager.RegisterRunningJob(job2, func() {})
+	g.QueueManager.RemoveRunningJob(job2)
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	job2.Success = true
+	job2.Output = "ok"
+	job2.FinishedAt = &now
+	assert.NoError(t, g.Store.UpdateJob(job2))
+	g.jobFinished(job2)
+
+	res = <-done
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	ret := &structs.Job{}
+	if err := json.Unmarshal(res.Body.Bytes(), ret); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, job2.ID, ret.ID)
+	assert.True(t, ret.Success)
+	assert.Equal(t, "ok", ret.Output)
+	assert.NotNil(t, ret.FinishedAt)
+}
+
+func TestCreateBatchHandler(t *testing.T) {
+	testInitApp(t)
+
+	t.Run("success", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/batch", bytes.NewBufferString(`{"jobs": [{"url": "https://your-worker-app-server/example"}, {"url": "https://your-worker-app-server/example"}]}`))
+		req.Header.Set("Content-Type", "application/json")
+		res := httptest.NewRecorder()
+		g.Echo.ServeHTTP(res, req)
+
+		assert.Equal(t, http.StatusOK, res.Code)
+
+		batch := &structs.Batch{}
+		if err := json.Unmarshal(res.Body.Bytes(), batch); err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, 2, batch.NumJobs)
+		assert.Equal(t, 2, batch.NumPending)
+
+		req = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/batch/%d", batch.ID), nil)
+		res = httptest.NewRecorder()
+		g.Echo.ServeHTTP(res, req)
+
+		assert.Equal(t, http.StatusOK, res.Code)
+	})
+
+	t.Run("invalid job", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/batch", bytes.NewBufferString(`{"jobs": [{"url": "https://your-worker-app-server/example"}, {"priority": 1}]}`))
+		req.Header.Set("Content-Type", "application/json")
+		res := httptest.NewRecorder()
+		g.Echo.ServeHTTP(res, req)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, res.Code)
+		assert.Contains(t, res.Body.String(), "jobs[1]")
+	})
+
+	t.Run("no jobs", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/batch", bytes.NewBufferString(`{"jobs": []}`))
+		req.Header.Set("Content-Type", "application/json")
+		res := httptest.NewRecorder()
+		g.Echo.ServeHTTP(res, req)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, res.Code)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/batch/100", nil)
+		res := httptest.NewRecorder()
+		g.Echo.ServeHTTP(res, req)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, res.Code)
+	})
+}
+
+func TestPauseHandler(t *testing.T) {
+	testInitApp(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/pause", bytes.NewBufferString(`{"name": "^send-"}`))
+	req.Header.Set("Content-Type", "application/json")
+	res := httptest.NewRecorder()
+	g.Echo.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	state := &structs.PauseState{}
+	if err := json.Unmarshal(res.Body.Bytes(), state); err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, state.Paused)
+	assert.Equal(t, []string{"^send-"}, state.Names)
+
+	stats, err := getStats()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"^send-"}, stats.PausedNames)
+
+	req = httptest.NewRequest(http.MethodPost, "/resume", bytes.NewBufferString(`{"name": "^send-"}`))
+	req.Header.Set("Content-Type", "application/json")
+	res = httptest.NewRecorder()
+	g.Echo.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, []string{}, g.Pauses.State().Names)
+
+	req = httptest.NewRequest(http.MethodPost, "/pause", bytes.NewBufferString(`{"name": "("}`))
+	req.Header.Set("Content-Type", "application/json")
+	res = httptest.NewRecorder()
+	g.Echo.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, res.Code)
+}
+
+func TestCompleteJobHandler(t *testing.T) {
+	testInitApp(t)
+
+	testAsyncJob(t, 1)
+
+	req := httptest.NewRequest(http.MethodPost, "/job/1/heartbeat", nil)
+	req.Header.Set(CallbackTokenHeader, "token")
+	res := httptest.NewRecorder()
+	g.Echo.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/job/1/complete", bytes.NewBufferString(`{"success": true, "output": "done"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(CallbackTokenHeader, "wrong")
+	res = httptest.NewRecorder()
+	g.Echo.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusForbidden, res.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/job/1/complete", bytes.NewBufferString(`{"success": true, "output": "done"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(CallbackTokenHeader, "token")
+	res = httptest.NewRecorder()
+	g.Echo.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	job := &structs.Job{}
+	if err := json.Unmarshal(res.Body.Bytes(), job); err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, job.Success)
+	assert.Equal(t, "done", job.Output)
+
+	// the finished job can not be completed again.
+	req = httptest.NewRequest(http.MethodPost, "/job/1/complete", bytes.NewBufferString(`{"success": false}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(CallbackTokenHeader, "token")
+	res = httptest.NewRecorder()
+	g.Echo.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, res.Code)
+}
+
+func TestProgressJobHandler(t *testing.T) {
+	testInitApp(t)
+
+	job := &structs.Job{ID: 1, URL: "http://example.com", Attempt: 1, CallbackToken: "token"}
+	assert.NoError(t, g.Store.CreateJob(job))
+
+	// the job is not running.
+	req := httptest.NewRequest(http.MethodPost, "/job/1/progress", bytes.NewBufferString(`{"percent": 50}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(CallbackTokenHeader, "token")
+	res := httptest.NewRecorder()
+	g.Echo.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, res.Code)
+
+	g.QueueManager.RegisterRunningJob(job, func() {})
+	defer g.QueueManager.RemoveRunningJob(job)
+
+	req = httptest.NewRequest(http.MethodPost, "/job/1/progress", bytes.NewBufferString(`{"percent": 50}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(CallbackTokenHeader, "wrong")
+	res = httptest.NewRecorder()
+	g.Echo.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusForbidden, res.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/job/1/progress", bytes.NewBufferString(`{"percent": 101}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(CallbackTokenHeader, "token")
+	res = httptest.NewRecorder()
+	g.Echo.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, res.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/job/1/progress", bytes.NewBufferString(`{"percent": 50, "message": "half", "log": ["hello"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(CallbackTokenHeader, "token")
+	res = httptest.NewRecorder()
+	g.Echo.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	ret := &structs.Job{}
+	if err := json.Unmarshal(res.Body.Bytes(), ret); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 50, *ret.Progress.Percent)
+	assert.Equal(t, "half", ret.Progress.Message)
+
+	req = httptest.NewRequest(http.MethodGet, "/job/1/log", nil)
+	res = httptest.NewRecorder()
+	g.Echo.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	jobLog := &structs.JobLog{}
+	if err := json.Unmarshal(res.Body.Bytes(), jobLog); err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, jobLog.Lines, 1)
+	assert.Equal(t, "hello", jobLog.Lines[0].Line)
+}
+
+func TestWaitJobHandler(t *testing.T) {
+	testInitApp(t)
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	job := &structs.Job{ID: 1, URL: "http://example.com", Success: true, FinishedAt: &now}
+	assert.NoError(t, g.Store.CreateJob(job))
+
+	req := httptest.NewRequest(http.MethodGet, "/job/1/wait?timeout=abc", nil)
+	res := httptest.NewRecorder()
+	g.Echo.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, res.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/job/1/wait?timeout=60s", nil)
+	res = httptest.NewRecorder()
+	g.Echo.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	ret := &structs.Job{}
+	if err := json.Unmarshal(res.Body.Bytes(), ret); err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, ret.Success)
+}
+
+func TestEventsHandler(t *testing.T) {
+	testInitApp(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/events?name=(", nil)
+	res := httptest.NewRecorder()
+	g.Echo.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, res.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/events", nil)
+	req.Header.Set("Last-Event-ID", "abc")
+	res = httptest.NewRecorder()
+	g.Echo.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, res.Code)
+
+	_, sub := g.Events.Subscribe(0)
+	for i := uint64(1); i <= 3; i++ {
+		assert.NoError(t, g.submitJob(&structs.Job{ID: i, Name: fmt.Sprintf("job%d", i), URL: "http://example.com"}))
+	}
+	first := <-sub.C()
+	sub.Close()
+
+	// resume the stream after the first event.
+	req = httptest.NewRequest(http.MethodGet, "/events?name=^job[234]$", nil)
+	req.Header.Set("Last-Event-ID", fmt.Sprintf("%d", first.ID))
+	res = httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		g.Echo.ServeHTTP(res, req)
+		close(done)
+	}()
+	for g.Events.Len() == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	assert.NoError(t, g.submitJob(&structs.Job{ID: 4, Name: "job4", URL: "http://example.com"}))
+	assert.NoError(t, g.submitJob(&structs.Job{ID: 5, Name: "other", URL: "http://example.com"}))
+
+	// closing the events ends the stream after the received events are written.
+	g.Events.Close()
+	<-done
+
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "text/event-stream", res.Header().Get("Content-Type"))
+
+	body := res.Body.String()
+	assert.Equal(t, 3, strings.Count(body, "event: pushed\n"))
+	assert.NotContains(t, body, fmt.Sprintf("id: %d\n", first.ID))
+	assert.Contains(t, body, `"name":"job2"`)
+	assert.Contains(t, body, `"name":"job3"`)
+	assert.Contains(t, body, `"name":"job4"`)
+}
+
+func TestDrainHandler(t *testing.T) {
+	testInitApp(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/drain", nil)
+	res := httptest.NewRecorder()
+	g.Echo.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	state := &structs.DrainState{}
+	if err := json.Unmarshal(res.Body.Bytes(), state); err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, state.Draining)
+	assert.True(t, state.Drained)
+
+	// new jobs are rejected while draining.
+	req = httptest.NewRequest(http.MethodPost, "/job", bytes.NewBufferString(`{"url": "https://your-worker-app-server/example"}`))
+	req.Header.Set("Content-Type", "application/json")
+	res = httptest.NewRecorder()
+	g.Echo.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, res.Code)
+
+	req = httptest.NewRequest(http.MethodDelete, "/drain", nil)
+	res = httptest.NewRecorder()
+	g.Echo.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/job", bytes.NewBufferString(`{"url": "https://your-worker-app-server/example"}`))
+	req.Header.Set("Content-Type", "application/json")
+	res = httptest.NewRecorder()
+	g.Echo.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+}
+
+func TestReloadHandler(t *testing.T) {
+	testInitApp(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/reload", nil)
+	res := httptest.NewRecorder()
+	g.Echo.ServeHTTP(res, req)
+
+	// the server was started without a config file.
+	assert.Equal(t, http.StatusUnprocessableEntity, res.Code)
+
+	base := *g.Config
+	g.Config.Loader = func() (*Config, error) {
+		c := base
+		c.JobListDefaultLimit = 5
+		return &c, nil
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/admin/reload", nil)
+	res = httptest.NewRecorder()
+	g.Echo.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	result := &structs.ReloadResult{}
+	if err := json.Unmarshal(res.Body.Bytes(), result); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"job_list_default_limit"}, result.Applied)
+	assert.Equal(t, []string{}, result.Rejected)
 }
 
 func TestStatsHandler(t *testing.T) {
@@ -102,3 +682,98 @@ func TestStatsHandler(t *testing.T) {
 		assert.Equal(t, 0, stats.NumStoredJobs)
 	})
 }
+
+func TestListBreakersHandler(t *testing.T) {
+	testInitApp(t)
+	g.CircuitBreakers = NewCircuitBreakers(1, 30*time.Second)
+	g.CircuitBreakers.Report(&structs.Job{ID: 1, URL: "http://worker.example.com/job"}, &StatusCodeError{StatusCode: http.StatusInternalServerError}, time.Now())
+
+	req := httptest.NewRequest(http.MethodGet, "/breakers", nil)
+	res := httptest.NewRecorder()
+	g.Echo.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	list := &structs.BreakerList{}
+	if err := json.Unmarshal(res.Body.Bytes(), list); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 1, len(list.Breakers))
+	assert.Equal(t, "worker.example.com", list.Breakers[0].Host)
+	assert.Equal(t, structs.BreakerStateOpen, list.Breakers[0].State)
+}
+
+func TestCreateScheduleHandler(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/schedule", bytes.NewBufferString(`
+{
+  "name": "nightly",
+  "cron": "0 3 * * *",
+  "timezone": "UTC",
+  "job": {
+    "url": "https://your-worker-app-server/example",
+    "payload": {
+      "message": "Hello world!"
+    }
+  }
+}
+`))
+		req.Header.Set("Content-Type", "application/json")
+		res := httptest.NewRecorder()
+		testInitApp(t)
+		g.Echo.ServeHTTP(res, req)
+
+		assert.Equal(t, http.StatusOK, res.Code)
+
+		schedule := &structs.Schedule{}
+		if err := json.Unmarshal(res.Body.Bytes(), schedule); err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, "nightly", schedule.Name)
+		assert.Equal(t, structs.ScheduleOverlapSkip, schedule.Overlap)
+		assert.Equal(t, structs.ScheduleCatchUpNone, schedule.CatchUp)
+		assert.Equal(t, "https://your-worker-app-server/example", schedule.Job.URL)
+		assert.NotNil(t, schedule.NextRunAt)
+
+		// get the schedule
+		req = httptest.NewRequest(http.MethodGet, "/schedule/nightly", nil)
+		res = httptest.NewRecorder()
+		g.Echo.ServeHTTP(res, req)
+		assert.Equal(t, http.StatusOK, res.Code)
+
+		// pause the schedule
+		req = httptest.NewRequest(http.MethodPost, "/schedule/nightly/pause", nil)
+		res = httptest.NewRecorder()
+		g.Echo.ServeHTTP(res, req)
+		assert.Equal(t, http.StatusOK, res.Code)
+
+		schedule = &structs.Schedule{}
+		if err := json.Unmarshal(res.Body.Bytes(), schedule); err != nil {
+			t.Fatal(err)
+		}
+		assert.True(t, schedule.Paused)
+
+		// delete the schedule
+		req = httptest.NewRequest(http.MethodDelete, "/schedule/nightly", nil)
+		res = httptest.NewRecorder()
+		g.Echo.ServeHTTP(res, req)
+		assert.Equal(t, http.StatusOK, res.Code)
+
+		req = httptest.NewRequest(http.MethodGet, "/schedule/nightly", nil)
+		res = httptest.NewRecorder()
+		g.Echo.ServeHTTP(res, req)
+		assert.Equal(t, http.StatusUnprocessableEntity, res.Code)
+	})
+
+	t.Run("invalid cron", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/schedule", bytes.NewBufferString(`{"name": "invalid", "cron": "* * *", "job": {"url": "https://your-worker-app-server/example"}}`))
+		req.Header.Set("Content-Type", "application/json")
+		res := httptest.NewRecorder()
+		testInitApp(t)
+		g.Echo.ServeHTTP(res, req)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, res.Code)
+	})
+}
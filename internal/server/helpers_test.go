@@ -37,10 +37,14 @@ func testLogger(t *testing.T) echo.Logger {
 func testDispatcher(t *testing.T, qm *QueueManager) *Dispatcher {
 	t.Helper()
 
+	logger := testLogger(t)
+	store := testStore(t, qm)
+
 	return &Dispatcher{
 		queueManager:      qm,
-		store:             testStore(t, qm),
-		logger:            testLogger(t),
+		store:             store,
+		timer:             NewTimer(logger, qm, store),
+		logger:            logger,
 		httpClientFactory: defaultHttpClientFactory,
 		maxWorkers:        0,
 		numWorkers:        0,
@@ -0,0 +1,333 @@
+package server
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kayac/go-katsubushi"
+	"github.com/pkg/errors"
+
+	"github.com/kohkimakimoto/hq/internal/structs"
+)
+
+// submitJob stores the new job and puts it into the queue.
+// If the queue is full, the job is not stored and it returns ErrQueueFull.
+func (a *App) submitJob(job *structs.Job) error {
+	if err := a.Store.CreateJob(job); err != nil {
+		return err
+	}
+
+	if err := a.startJob(job); err != nil {
+		a.rejectJob(job, err)
+		return err
+	}
+
+	a.publishEvent(structs.EventTypePushed, job)
+	return nil
+}
+
+// submitUniqueJob stores the new job that has a unique key and puts it into the queue.
+// If another job holds the same unique key, it returns the existing job instead and false.
+func (a *App) submitUniqueJob(job *structs.Job) (*structs.Job, bool, error) {
+	existing, err := a.Store.CreateUniqueJob(job)
+	if err != nil {
+		return nil, false, err
+	}
+	if existing != nil {
+		return existing, false, nil
+	}
+
+	if err := a.startJob(job); err != nil {
+		a.rejectJob(job, err)
+		return nil, false, err
+	}
+
+	a.publishEvent(structs.EventTypePushed, job)
+	return job, true, nil
+}
+
+// rejectJob deletes the stored job that was not accepted by the full queue.
+func (a *App) rejectJob(job *structs.Job, err error) {
+	if err != ErrQueueFull {
+		return
+	}
+	if e := a.Store.DeleteJob(job.ID); e != nil {
+		a.Echo.Logger.Error(e)
+	}
+}
+
+// startJob puts the stored job into the queue.
+// If the job depends on the other jobs, it is blocked until they finish.
+// It returns ErrQueueFull if the job is put into the queue immediately, and the queue has reached the capacity.
+func (a *App) startJob(job *structs.Job) error {
+	if len(job.DependsOn) > 0 {
+		return a.blockJob(job)
+	}
+
+	if isDue(job, time.Now()) {
+		return a.QueueManager.Enqueue(job)
+	}
+
+	return a.queueJob(job)
+}
+
+// isDue reports whether the job does not have the time to run in the future.
+func isDue(job *structs.Job, now time.Time) bool {
+	return job.RunAt == nil || !job.RunAt.After(now)
+}
+
+// queueJob puts the job into the queue.
+// If the job has the time to run in the future, it is put into the timer instead.
+func (a *App) queueJob(job *structs.Job) error {
+	if !isDue(job, time.Now()) {
+		if err := a.Timer.Schedule(job); err != nil {
+			return errors.Wrap(err, "failed to schedule the job")
+		}
+	} else {
+		// The job was accepted when it was pushed, so the capacity is not checked.
+		if err := a.QueueManager.Requeue(job); err != nil {
+			return errors.Wrap(err, "failed to enqueue the job")
+		}
+	}
+
+	return nil
+}
+
+// resolveQueue resolves the named queue of the job.
+// The job's url, timeout and headers are filled with the defaults of the queue. The headers of the job take precedence.
+func (a *App) resolveQueue(job *structs.Job) error {
+	q, err := a.config().NamedQueue(job.Queue)
+	if err != nil {
+		return err
+	}
+
+	if q == nil {
+		// default queue
+		job.Queue = ""
+		return nil
+	}
+
+	if job.URL == "" {
+		job.URL = q.URL
+	}
+	if job.Timeout == 0 {
+		job.Timeout = q.Timeout
+	}
+	if len(q.Headers) > 0 {
+		headers := map[string]string{}
+		for k, v := range q.Headers {
+			headers[k] = v
+		}
+		for k, v := range job.Headers {
+			headers[k] = v
+		}
+		job.Headers = headers
+	}
+
+	return nil
+}
+
+// validateScheduleQueue validates that the queue of the schedule's job template is defined
+// and the job has the url by itself or by the queue.
+func (a *App) validateScheduleQueue(schedule *structs.Schedule) error {
+	q, err := a.config().NamedQueue(schedule.Job.Queue)
+	if err != nil {
+		return fmt.Errorf("invalid 'job.queue': %v", err)
+	}
+
+	if schedule.Job.URL == "" && (q == nil || q.URL == "") {
+		return fmt.Errorf("'job.url' is required")
+	}
+
+	return nil
+}
+
+// cancelJob cancels the active job.
+// A waiting or running job is canceled by the dispatcher, a scheduled, blocked or async job is canceled immediately.
+func (a *App) cancelJob(job *structs.Job) error {
+	if job.RunningAsync {
+		return a.cancelAsyncJob(job.ID)
+	}
+
+	if job.Blocked {
+		// The blocked job is not in the queue yet. So it is canceled immediately.
+		if a.QueueManager.RemoveBlockedJob(job.ID) != nil {
+			return a.finishCanceledJob(job, "")
+		}
+		return nil
+	}
+
+	if !job.Scheduled {
+		a.QueueManager.CancelJob(job.ID)
+		return nil
+	}
+
+	// The scheduled job is not in the queue yet. So it is canceled immediately.
+	removed, err := a.Timer.Remove(job.ID)
+	if err != nil {
+		return err
+	}
+
+	if removed {
+		return a.finishCanceledJob(job, "")
+	}
+
+	return nil
+}
+
+// finishCanceledJob finishes the job that is canceled before it is put into the queue.
+func (a *App) finishCanceledJob(job *structs.Job, reason string) error {
+	// Truncate millisecond. It is compatible time for katsubushi ID generator timestamp.
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	job.Scheduled = false
+	job.Blocked = false
+	job.Canceled = true
+	job.FinishedAt = &now
+	job.Err = reason
+	if err := a.Store.UpdateJob(job); err != nil {
+		return err
+	}
+
+	a.jobFinished(job)
+	return nil
+}
+
+// finishExpiredJob finishes the job that passed its deadline before it started.
+func (a *App) finishExpiredJob(job *structs.Job) error {
+	// Truncate millisecond. It is compatible time for katsubushi ID generator timestamp.
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	job.Waiting = false
+	job.Scheduled = false
+	job.Blocked = false
+	job.Expired = true
+	job.FinishedAt = &now
+	job.Err = expiredJobErr(job)
+	if err := a.Store.UpdateJob(job); err != nil {
+		return err
+	}
+
+	a.jobFinished(job)
+	return nil
+}
+
+// expireOverdueJobs expires the waiting, scheduled and blocked jobs that passed their deadlines.
+func (a *App) expireOverdueJobs(now time.Time) {
+	logger := a.Echo.Logger
+
+	expired := a.QueueManager.RemoveExpiredJobs(now)
+	for _, job := range a.QueueManager.OverdueJobs(now) {
+		if job.Blocked {
+			if a.QueueManager.RemoveBlockedJob(job.ID) != nil {
+				expired = append(expired, job)
+			}
+			continue
+		}
+
+		removed, err := a.Timer.Remove(job.ID)
+		if err != nil {
+			logger.Error(err)
+			continue
+		}
+		if removed {
+			expired = append(expired, job)
+		}
+	}
+
+	for _, job := range expired {
+		if err := a.finishExpiredJob(job); err != nil {
+			logger.Error(err)
+		}
+		logger.Infof("job: %d expired", job.ID)
+	}
+}
+
+// expiredJobErr returns the error message of the expired job.
+func expiredJobErr(job *structs.Job) string {
+	return fmt.Sprintf("the job expired at %s before it started", job.ExpiresAt.Format(time.RFC3339))
+}
+
+// jobFinished is called when the job finished with success, failure, cancellation or expiration.
+func (a *App) jobFinished(job *structs.Job) {
+	a.releaseDependents(job)
+	if job.BatchID != 0 {
+		a.finishBatchJob(job)
+	}
+	a.notifyFinishedJob(job)
+	a.JobWaiters.Notify(job.ID)
+
+	if job.Canceled {
+		a.publishEvent(structs.EventTypeCanceled, job)
+	} else {
+		a.publishEvent(structs.EventTypeFinished, job)
+	}
+}
+
+// jobStarted is called when the dispatcher started an attempt of the job.
+func (a *App) jobStarted(job *structs.Job) {
+	a.publishEvent(structs.EventTypeStarted, job)
+}
+
+// pushScheduledJob pushes a new job from the job template of the schedule.
+func (a *App) pushScheduledJob(schedule *structs.Schedule) (*structs.Job, error) {
+	id, err := a.IdGen.NextID()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate uniq id")
+	}
+
+	tmpl := schedule.Job
+
+	job := &structs.Job{}
+	job.ID = id
+	job.CreatedAt = katsubushi.ToTime(id)
+	job.Name = tmpl.Name
+	if job.Name == "" {
+		job.Name = schedule.Name
+	}
+	job.Comment = tmpl.Comment
+	job.URL = tmpl.URL
+	job.Payload = tmpl.Payload
+	job.Headers = tmpl.Headers
+	job.Timeout = tmpl.Timeout
+	job.CancelURL = tmpl.CancelURL
+	job.NotifyURL = tmpl.NotifyURL
+	job.Async = tmpl.Async
+	job.Queue = tmpl.Queue
+	job.Priority = tmpl.Priority
+	job.ConcurrencyKey = tmpl.ConcurrencyKey
+	job.ConcurrencyLimit = tmpl.ConcurrencyLimit
+	job.MaxRetries = tmpl.MaxRetries
+	job.RetryDelay = tmpl.RetryDelay
+	job.RetryBackoff = tmpl.RetryBackoff
+	job.StatusPolicy = tmpl.StatusPolicy
+	if tmpl.TTL > 0 {
+		expiresAt := job.CreatedAt.UTC().Add(time.Duration(tmpl.TTL) * time.Second)
+		job.ExpiresAt = &expiresAt
+	}
+
+	if err := a.resolveQueue(job); err != nil {
+		return nil, err
+	}
+
+	if err := a.submitJob(job); err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// cancelScheduledJob cancels the job that was pushed by a schedule if it is still active.
+func (a *App) cancelScheduledJob(id uint64) error {
+	job, err := a.Store.GetJob(id)
+	if err != nil {
+		if _, ok := err.(*ErrJobNotFound); ok {
+			return nil
+		}
+		return err
+	}
+
+	if !job.Running && !job.RunningAsync && !job.Waiting && !job.Scheduled && !job.Blocked {
+		return nil
+	}
+
+	return a.cancelJob(job)
+}
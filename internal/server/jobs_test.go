@@ -0,0 +1,73 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kohkimakimoto/hq/internal/structs"
+)
+
+func TestApp_ExpireOverdueJobs(t *testing.T) {
+	testInitApp(t)
+
+	now := time.Now()
+	past := now.Add(-1 * time.Second)
+	future := now.Add(time.Hour)
+	runAt := now.Add(time.Minute)
+
+	waiting := &structs.Job{ID: 1, URL: "http://example.com", ExpiresAt: &past}
+	scheduled := &structs.Job{ID: 2, URL: "http://example.com", RunAt: &runAt, ExpiresAt: &past}
+	alive := &structs.Job{ID: 3, URL: "http://example.com", ExpiresAt: &future}
+	for _, job := range []*structs.Job{waiting, scheduled, alive} {
+		assert.NoError(t, g.submitJob(job))
+	}
+
+	g.expireOverdueJobs(now)
+
+	for _, id := range []uint64{1, 2} {
+		job, err := g.Store.GetJob(id)
+		assert.NoError(t, err)
+		assert.Equal(t, structs.JobStatusExpired, job.Status())
+		assert.NotNil(t, job.FinishedAt)
+	}
+
+	job, err := g.Store.GetJob(3)
+	assert.NoError(t, err)
+	assert.Equal(t, structs.JobStatusWaiting, job.Status())
+	assert.Equal(t, 1, g.QueueManager.NumJobsInQueue())
+	assert.Equal(t, 0, g.QueueManager.NumJobsScheduled())
+}
+
+func TestApp_PushScheduledJob(t *testing.T) {
+	testInitApp(t)
+
+	schedule := testSchedule("example")
+	schedule.Job.MaxRetries = 3
+	schedule.Job.RetryDelay = 10
+	schedule.Job.RetryBackoff = 1.5
+	schedule.Job.StatusPolicy = &structs.StatusPolicy{Retryable: []string{"503"}}
+	schedule.Job.ConcurrencyKey = "report"
+	schedule.Job.ConcurrencyLimit = 1
+	schedule.Job.NotifyURL = "http://example.com/notify"
+	schedule.Job.TTL = 60
+	assert.NoError(t, validateSchedule(schedule))
+
+	job, err := g.pushScheduledJob(schedule)
+	assert.NoError(t, err)
+
+	job, err = g.Store.GetJob(job.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "example", job.Name)
+	assert.Equal(t, 3, job.MaxRetries)
+	assert.Equal(t, int64(10), job.RetryDelay)
+	assert.Equal(t, 1.5, job.RetryBackoff)
+	assert.Equal(t, []string{"503"}, job.StatusPolicy.Retryable)
+	assert.Equal(t, "report", job.ConcurrencyKey)
+	assert.Equal(t, 1, job.ConcurrencyLimit)
+	assert.Equal(t, "http://example.com/notify", job.NotifyURL)
+	if assert.NotNil(t, job.ExpiresAt) {
+		assert.True(t, job.ExpiresAt.Equal(job.CreatedAt.Add(60*time.Second)))
+	}
+}
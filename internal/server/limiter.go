@@ -0,0 +1,150 @@
+package server
+
+import (
+	"math"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kohkimakimoto/hq/internal/structs"
+)
+
+// HostLimiter is a DispatchGate that limits the concurrent requests and the requests per second to the worker hosts.
+// The rate is limited by the token bucket algorithm. A job must be allowed by all the limits that match its URL.
+type HostLimiter struct {
+	mutex  sync.Mutex
+	limits []*hostLimit
+	// held is the limits that are taken by the running jobs.
+	held map[uint64][]*hostLimit
+}
+
+type hostLimit struct {
+	config    *HostLimitConfig
+	running   int64
+	tokens    float64
+	updatedAt time.Time
+}
+
+func NewHostLimiter(configs []*HostLimitConfig) *HostLimiter {
+	l := &HostLimiter{
+		held: map[uint64][]*hostLimit{},
+	}
+	for _, c := range configs {
+		l.limits = append(l.limits, &hostLimit{
+			config: c,
+			tokens: float64(c.Burst),
+		})
+	}
+	return l
+}
+
+func (l *HostLimiter) Acquire(job *structs.Job, now time.Time) (bool, time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	limits := l.match(job.URL)
+	if len(limits) == 0 {
+		return true, 0
+	}
+
+	var wait time.Duration
+	blocked := false
+	for _, hl := range limits {
+		hl.refill(now)
+		if hl.config.Concurrency > 0 && hl.running >= hl.config.Concurrency {
+			// wait for a running job to release the limit.
+			return false, 0
+		}
+		if hl.config.Rate > 0 && hl.tokens < 1 {
+			blocked = true
+			w := time.Duration((1 - hl.tokens) / hl.config.Rate * float64(time.Second))
+			if w > wait {
+				wait = w
+			}
+		}
+	}
+	if blocked {
+		return false, wait
+	}
+
+	for _, hl := range limits {
+		hl.running++
+		if hl.config.Rate > 0 {
+			hl.tokens--
+		}
+	}
+	l.held[job.ID] = limits
+
+	return true, 0
+}
+
+func (l *HostLimiter) Release(job *structs.Job) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.release(job, false)
+}
+
+// Rollback releases the limits of the job that was refused by a later gate.
+// The job was not sent, so it also gives back the tokens.
+func (l *HostLimiter) Rollback(job *structs.Job) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.release(job, true)
+}
+
+// release releases the limits taken by the job.
+// It must be called with the lock held.
+func (l *HostLimiter) release(job *structs.Job, refund bool) {
+	limits, ok := l.held[job.ID]
+	if !ok {
+		return
+	}
+	for _, hl := range limits {
+		hl.running--
+		if refund && hl.config.Rate > 0 {
+			hl.tokens = math.Min(float64(hl.config.Burst), hl.tokens+1)
+		}
+	}
+	delete(l.held, job.ID)
+}
+
+// match returns the limits that match the URL.
+// It must be called with the lock held.
+func (l *HostLimiter) match(rawurl string) []*hostLimit {
+	if len(l.limits) == 0 {
+		return nil
+	}
+
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		// The job fails by the invalid url. So it does not need to be limited.
+		return nil
+	}
+
+	var ret []*hostLimit
+	for _, hl := range l.limits {
+		m := hl.config.Match
+		if strings.Contains(m, "://") {
+			if strings.HasPrefix(rawurl, m) {
+				ret = append(ret, hl)
+			}
+		} else if strings.EqualFold(u.Host, m) || strings.EqualFold(u.Hostname(), m) {
+			ret = append(ret, hl)
+		}
+	}
+	return ret
+}
+
+// refill adds the tokens for the elapsed time.
+func (hl *hostLimit) refill(now time.Time) {
+	if hl.config.Rate <= 0 {
+		return
+	}
+	if !hl.updatedAt.IsZero() && now.After(hl.updatedAt) {
+		hl.tokens = math.Min(float64(hl.config.Burst), hl.tokens+now.Sub(hl.updatedAt).Seconds()*hl.config.Rate)
+	}
+	hl.updatedAt = now
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kohkimakimoto/hq/internal/structs"
+)
+
+func TestHostLimiter_Concurrency(t *testing.T) {
+	l := NewHostLimiter([]*HostLimitConfig{
+		{Match: "api.example.com", Concurrency: 2},
+	})
+	now := time.Now()
+
+	job1 := &structs.Job{ID: 1, URL: "http://api.example.com/a"}
+	job2 := &structs.Job{ID: 2, URL: "http://api.example.com:8080/b"}
+	job3 := &structs.Job{ID: 3, URL: "http://api.example.com/c"}
+	other := &structs.Job{ID: 4, URL: "http://other.example.com/"}
+
+	ok, _ := l.Acquire(job1, now)
+	assert.True(t, ok)
+	ok, _ = l.Acquire(job2, now)
+	assert.True(t, ok)
+	ok, wait := l.Acquire(job3, now)
+	assert.False(t, ok)
+	assert.Equal(t, time.Duration(0), wait)
+	ok, _ = l.Acquire(other, now)
+	assert.True(t, ok)
+
+	l.Release(job1)
+	// releasing the job that does not have a slot is ignored.
+	l.Release(job3)
+	ok, _ = l.Acquire(job3, now)
+	assert.True(t, ok)
+}
+
+func TestHostLimiter_Rate(t *testing.T) {
+	limits := []*HostLimitConfig{
+		{Match: "http://api.example.com/v1/", Rate: 2},
+	}
+	assert.NoError(t, validateHostLimits(limits))
+	l := NewHostLimiter(limits)
+	now := time.Now()
+
+	ok, _ := l.Acquire(&structs.Job{ID: 1, URL: "http://api.example.com/v1/a"}, now)
+	assert.True(t, ok)
+	ok, _ = l.Acquire(&structs.Job{ID: 2, URL: "http://api.example.com/v1/b"}, now)
+	assert.True(t, ok)
+	ok, wait := l.Acquire(&structs.Job{ID: 3, URL: "http://api.example.com/v1/c"}, now)
+	assert.False(t, ok)
+	assert.Equal(t, 500*time.Millisecond, wait)
+
+	// the url that does not have the prefix is not limited.
+	ok, _ = l.Acquire(&structs.Job{ID: 4, URL: "http://api.example.com/v2/"}, now)
+	assert.True(t, ok)
+
+	// a token is added after 0.5 seconds.
+	ok, _ = l.Acquire(&structs.Job{ID: 3, URL: "http://api.example.com/v1/c"}, now.Add(500*time.Millisecond))
+	assert.True(t, ok)
+}
+
+func TestHostLimiter_Rollback(t *testing.T) {
+	limits := []*HostLimitConfig{
+		{Match: "api.example.com", Rate: 1},
+	}
+	assert.NoError(t, validateHostLimits(limits))
+	l := NewHostLimiter(limits)
+	now := time.Now()
+
+	job1 := &structs.Job{ID: 1, URL: "http://api.example.com/a"}
+	job2 := &structs.Job{ID: 2, URL: "http://api.example.com/b"}
+
+	// the job refused by a later gate gives back the token.
+	ok, _ := l.Acquire(job1, now)
+	assert.True(t, ok)
+	l.Rollback(job1)
+	ok, _ = l.Acquire(job2, now)
+	assert.True(t, ok)
+
+	// the finished job does not.
+	l.Release(job2)
+	ok, _ = l.Acquire(job1, now)
+	assert.False(t, ok)
+}
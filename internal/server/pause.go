@@ -0,0 +1,137 @@
+package server
+
+import (
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kohkimakimoto/hq/internal/structs"
+)
+
+// Pauses is a DispatchGate that holds the jobs in the queue while dispatching them is paused.
+// Dispatching is paused for all the jobs, or for the jobs whose names match the regular expressions.
+type Pauses struct {
+	mutex  sync.RWMutex
+	global bool
+	names  map[string]*regexp.Regexp
+}
+
+func NewPauses() *Pauses {
+	return &Pauses{
+		names: map[string]*regexp.Regexp{},
+	}
+}
+
+func (p *Pauses) Acquire(job *structs.Job, now time.Time) (bool, time.Duration) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	if p.global {
+		// wait for resuming.
+		return false, 0
+	}
+	for _, r := range p.names {
+		if r.MatchString(job.Name) {
+			return false, 0
+		}
+	}
+	return true, 0
+}
+
+func (p *Pauses) Release(job *structs.Job) {
+}
+
+// Holding reports whether dispatching all the jobs is paused.
+func (p *Pauses) Holding() bool {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	return p.global
+}
+
+// Pause pauses dispatching the jobs whose names match the regular expression.
+// The empty name pauses dispatching all the jobs.
+func (p *Pauses) Pause(name string) error {
+	var r *regexp.Regexp
+	if name != "" {
+		var err error
+		r, err = regexp.Compile(name)
+		if err != nil {
+			return err
+		}
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if r == nil {
+		p.global = true
+	} else {
+		p.names[name] = r
+	}
+	return nil
+}
+
+// Resume resumes dispatching the jobs that were paused by the name.
+// The empty name resumes the pause for all the jobs. The pauses by names are kept.
+func (p *Pauses) Resume(name string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if name == "" {
+		p.global = false
+	} else {
+		delete(p.names, name)
+	}
+}
+
+// State returns the current pauses.
+func (p *Pauses) State() *structs.PauseState {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	names := make([]string, 0, len(p.names))
+	for name := range p.names {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return &structs.PauseState{
+		Paused: p.global,
+		Names:  names,
+	}
+}
+
+// pauseDispatching pauses dispatching the jobs by the name and persists the pause.
+func (a *App) pauseDispatching(name string) error {
+	if err := a.Pauses.Pause(name); err != nil {
+		return err
+	}
+	return a.Store.PutPause(name)
+}
+
+// resumeDispatching resumes dispatching the jobs that were paused by the name.
+func (a *App) resumeDispatching(name string) error {
+	if err := a.Store.DeletePause(name); err != nil {
+		return err
+	}
+	a.Pauses.Resume(name)
+	// The held jobs are not released by the gate, so the dispatchers must be woken up.
+	a.QueueManager.Wake()
+	return nil
+}
+
+// restorePauses restores the pauses that were persisted by the previous process.
+func (a *App) restorePauses() error {
+	names, err := a.Store.ListPauses()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := a.Pauses.Pause(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kohkimakimoto/hq/internal/structs"
+)
+
+func TestPauses(t *testing.T) {
+	p := NewPauses()
+	now := time.Now()
+
+	job1 := &structs.Job{ID: 1, Name: "send-email"}
+	job2 := &structs.Job{ID: 2, Name: "resize-image"}
+
+	ok, _ := p.Acquire(job1, now)
+	assert.True(t, ok)
+
+	assert.NoError(t, p.Pause("^send-"))
+	ok, _ = p.Acquire(job1, now)
+	assert.False(t, ok)
+	ok, _ = p.Acquire(job2, now)
+	assert.True(t, ok)
+
+	assert.False(t, p.Holding())
+	assert.NoError(t, p.Pause(""))
+	assert.True(t, p.Holding())
+	ok, _ = p.Acquire(job2, now)
+	assert.False(t, ok)
+	assert.Equal(t, &structs.PauseState{Paused: true, Names: []string{"^send-"}}, p.State())
+
+	// resuming all the jobs keeps the pauses by names.
+	p.Resume("")
+	ok, _ = p.Acquire(job1, now)
+	assert.False(t, ok)
+	ok, _ = p.Acquire(job2, now)
+	assert.True(t, ok)
+
+	p.Resume("^send-")
+	ok, _ = p.Acquire(job1, now)
+	assert.True(t, ok)
+
+	assert.Error(t, p.Pause("("))
+}
+
+func TestApp_PauseDispatching(t *testing.T) {
+	testInitApp(t)
+
+	assert.NoError(t, g.pauseDispatching(""))
+	assert.NoError(t, g.pauseDispatching("^send-"))
+
+	names, err := g.Store.ListPauses()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"", "^send-"}, names)
+
+	// the pauses are restored from the store.
+	g.Pauses = NewPauses()
+	assert.NoError(t, g.restorePauses())
+	assert.Equal(t, &structs.PauseState{Paused: true, Names: []string{"^send-"}}, g.Pauses.State())
+
+	assert.NoError(t, g.resumeDispatching(""))
+	names, err = g.Store.ListPauses()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"^send-"}, names)
+}
+
+func TestApp_StopDispatchersWhilePaused(t *testing.T) {
+	testInitApp(t)
+
+	assert.NoError(t, g.pauseDispatching(""))
+	assert.NoError(t, g.submitJob(&structs.Job{ID: 1, URL: "http://example.com"}))
+	g.startDispatchers()
+
+	// the paused job is kept in the queue, and it does not block the shutdown.
+	assert.True(t, g.stopDispatchers(3*time.Second))
+	assert.Equal(t, 1, g.QueueManager.NumJobsInQueue())
+}
@@ -1,47 +1,501 @@
 package server
 
 import (
+	"container/heap"
 	"context"
+	"errors"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/kohkimakimoto/hq/internal/structs"
 )
 
+const (
+	// MaxJobPriority is the highest priority of a job.
+	MaxJobPriority = 1000
+	// MinJobPriority is the lowest priority of a job.
+	MinJobPriority = -1000
+)
+
 // QueueManager provides a queue data structure and manages the queued job status.
+// The queue dispatches the job that has the highest priority first, and the jobs of the same priority in FIFO order.
+// It has the default queue and the named queues that are added by AddQueue. Each queue is dispatched independently.
+// If the QueueManager is bound to a Store by Restore, the queued entries are persisted in the boltdb.
 type QueueManager struct {
-	Queue chan *structs.Job
+	capacity int64
+	store    *Store
+
+	// properties for the queue
+	mutex   sync.RWMutex
+	queues  map[string]*queueEntries
+	aging   time.Duration
+	nextSeq uint64
+	wakeCh  chan struct{}
+	gates   []DispatchGate
+	// keyedJobs is the IDs of the queued jobs per concurrency key in the order of ID.
+	// Only the first job of a key can be dispatched, so the jobs of the same key run in the order they were pushed.
+	keyedJobs map[string][]uint64
 
 	// properties for job status
-	mutex       sync.RWMutex
-	waitingJobs map[uint64]*WaitingJob
-	runningJobs map[uint64]*RunningJob
+	waitingJobs   map[uint64]*WaitingJob
+	runningJobs   map[uint64]*RunningJob
+	scheduledJobs map[uint64]*structs.Job
+	blockedJobs   map[uint64]*structs.Job
+	asyncJobs     map[uint64]*structs.Job
+}
+
+// DispatchGate decides whether a job in the queue can be dispatched now.
+// A job that is not allowed stays in the queue as a waiting job, and the next job is dispatched instead.
+type DispatchGate interface {
+	// Acquire reports whether the job can be dispatched now, and takes a slot for the job if it can.
+	// If it can not, it returns the time to wait before the job may be allowed. 0 means waiting for a Release.
+	Acquire(job *structs.Job, now time.Time) (bool, time.Duration)
+	// Release releases the slot that was taken by Acquire. It is called when the job finishes running.
+	// It must ignore the job that does not have a slot.
+	Release(job *structs.Job)
+}
+
+// DispatchGateHolder is implemented by a DispatchGate that can hold all the jobs at once.
+// While Holding reports true, the queued jobs are not checked against the gates.
+type DispatchGateHolder interface {
+	Holding() bool
+}
+
+// DispatchGateRollbacker is implemented by a DispatchGate that takes more than a slot in Acquire.
+// Rollback is called instead of Release when a later gate refuses the job, so the job has never started.
+type DispatchGateRollbacker interface {
+	Rollback(job *structs.Job)
+}
+
+// QueueEntry is an entry of the queue.
+type QueueEntry struct {
+	Seq        uint64
+	EnqueuedAt time.Time
+	Job        *structs.Job
 }
 
 func NewQueueManager(queueSize int64) *QueueManager {
 	return &QueueManager{
-		Queue:       make(chan *structs.Job, queueSize),
-		waitingJobs: map[uint64]*WaitingJob{},
-		runningJobs: map[uint64]*RunningJob{},
+		capacity: queueSize,
+		queues: map[string]*queueEntries{
+			"": {},
+		},
+		nextSeq:       1,
+		wakeCh:        make(chan struct{}),
+		keyedJobs:     map[string][]uint64{},
+		waitingJobs:   map[uint64]*WaitingJob{},
+		runningJobs:   map[uint64]*RunningJob{},
+		scheduledJobs: map[uint64]*structs.Job{},
+		blockedJobs:   map[uint64]*structs.Job{},
+		asyncJobs:     map[uint64]*structs.Job{},
+	}
+}
+
+// SetPriorityAging sets the interval that raises the priority of the waiting jobs by 1.
+// It keeps the low priority jobs from starving. It must be called before any jobs are enqueued.
+func (m *QueueManager) SetPriorityAging(d time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.aging = d
+	for _, h := range m.queues {
+		h.aging = d
+		heap.Init(h)
+	}
+}
+
+// AddQueue adds the named queue. It must be called before any jobs are enqueued.
+func (m *QueueManager) AddQueue(name string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, ok := m.queues[name]; !ok {
+		m.queues[name] = &queueEntries{
+			aging: m.aging,
+		}
+	}
+}
+
+// AddGate adds the gate that throttles dispatching the jobs.
+func (m *QueueManager) AddGate(gate DispatchGate) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.gates = append(m.gates, gate)
+}
+
+// queue returns the queue of the name.
+// If the queue does not exist (e.g. it was removed from the config), it returns the default queue.
+// It must be called with the lock held.
+func (m *QueueManager) queue(name string) *queueEntries {
+	if h, ok := m.queues[name]; ok {
+		return h
 	}
+	return m.queues[""]
 }
 
-func (m *QueueManager) EnqueueAsync(job *structs.Job) {
+// Restore binds the queue to the store and loads the entries that were persisted by the previous process.
+func (m *QueueManager) Restore(store *Store) error {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
+	entries, err := store.LoadQueueEntries()
+	if err != nil {
+		return err
+	}
+
+	m.store = store
+	for _, e := range entries {
+		heap.Push(m.queue(e.Job.Queue), e)
+		m.addKeyedJob(e.Job)
+		m.waitingJobs[e.Job.ID] = &WaitingJob{
+			Job: e.Job,
+		}
+	}
+	m.wake()
+
+	return nil
+}
+
+// ErrQueueFull is returned when the queue does not have the capacity for the new jobs.
+var ErrQueueFull = errors.New("the queue is full")
+
+// Enqueue adds the new job to the queue.
+// It returns ErrQueueFull if the queue has reached the capacity. The capacity is checked in the same lock as adding the job.
+func (m *QueueManager) Enqueue(job *structs.Job) error {
+	return m.EnqueueAll([]*structs.Job{job})
+}
+
+// EnqueueAll adds the new jobs to the queue at once.
+// If the queue does not have the capacity for all the jobs, none of them is added and it returns ErrQueueFull.
+func (m *QueueManager) EnqueueAll(jobs []*structs.Job) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.capacity > 0 && int64(m.numJobsInQueue()+len(jobs)) > m.capacity {
+		return ErrQueueFull
+	}
+
+	for _, job := range jobs {
+		if err := m.enqueue(job); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Requeue adds the job that has been accepted already to the queue regardless of the capacity.
+// e.g. the next attempt of the failed job, the scheduled job that is due, or the recovered job.
+func (m *QueueManager) Requeue(job *structs.Job) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.enqueue(job)
+}
+
+// enqueue adds the job to the queue. It must be called with the lock held.
+func (m *QueueManager) enqueue(job *structs.Job) error {
+	now := time.Now()
+
+	var seq uint64
+	if m.store != nil {
+		s, err := m.store.PutQueueEntry(job.ID, now)
+		if err != nil {
+			return err
+		}
+		seq = s
+	} else {
+		seq = m.nextSeq
+		m.nextSeq++
+	}
+
+	heap.Push(m.queue(job.Queue), &QueueEntry{
+		Seq:        seq,
+		EnqueuedAt: now,
+		Job:        job,
+	})
+	m.addKeyedJob(job)
+
 	// set the job as a waiting job
 	m.waitingJobs[job.ID] = &WaitingJob{
 		Job: job,
 	}
+	delete(m.scheduledJobs, job.ID)
+	// The held job is checked when the dispatching is resumed.
+	if !m.holding() || job.Canceled || isJobExpired(job, now) {
+		m.wake()
+	}
 
-	// As enqueuing jobs asynchronously, it does NOT guarantee the order of the jobs.
-	go func() {
-		m.Queue <- job
-	}()
+	return nil
 }
 
-func (m *QueueManager) Dequeue() *structs.Job {
-	return <-m.Queue
+// Dequeue removes the job that has the highest priority from the queue of the name and returns it.
+// The empty name means the default queue. The jobs that are not allowed by the gates are skipped.
+// It blocks until a job is enqueued or allowed.
+func (m *QueueManager) Dequeue(name string) *structs.Job {
+	return m.DequeueOrStop(name, nil)
+}
+
+// DequeueOrStop is the same as Dequeue, but it returns nil without removing any jobs when the stopCh is closed.
+// The nil stopCh is never closed.
+func (m *QueueManager) DequeueOrStop(name string, stopCh <-chan struct{}) *structs.Job {
+	for {
+		m.mutex.Lock()
+		job, wait := m.dequeue(name, time.Now())
+		if job != nil {
+			m.mutex.Unlock()
+			return job
+		}
+		wakeCh := m.wakeCh
+		m.mutex.Unlock()
+
+		if wait > 0 {
+			t := time.NewTimer(wait)
+			select {
+			case <-wakeCh:
+			case <-t.C:
+			case <-stopCh:
+				t.Stop()
+				return nil
+			}
+			t.Stop()
+		} else {
+			select {
+			case <-wakeCh:
+			case <-stopCh:
+				return nil
+			}
+		}
+	}
+}
+
+// dequeue pops the first job that the gates allow, and pushes the skipped entries back.
+// If no job is allowed, it returns the shortest time to wait. 0 means waiting for the next wake-up.
+// It must be called with the lock held.
+func (m *QueueManager) dequeue(name string, now time.Time) (*structs.Job, time.Duration) {
+	h := m.queue(name)
+
+	var found *QueueEntry
+	var wait time.Duration
+	if m.holding() {
+		// All the jobs are held, so only the canceled or expired job is taken without checking the gates.
+		for i, e := range h.entries {
+			if e.Job.Canceled || isJobExpired(e.Job, now) {
+				found = heap.Remove(h, i).(*QueueEntry)
+				break
+			}
+		}
+	} else {
+		var skipped []*QueueEntry
+		for h.Len() > 0 {
+			e := heap.Pop(h).(*QueueEntry)
+			// The canceled or expired job is never sent, so it is not throttled.
+			if e.Job.Canceled || isJobExpired(e.Job, now) {
+				found = e
+				break
+			}
+			// The job waits for the earlier jobs of the same concurrency key.
+			if !m.isFirstKeyedJob(e.Job) {
+				skipped = append(skipped, e)
+				continue
+			}
+			ok, w := m.acquire(e.Job, now)
+			if ok {
+				found = e
+				break
+			}
+			if w > 0 && (wait == 0 || w < wait) {
+				wait = w
+			}
+			skipped = append(skipped, e)
+		}
+		for _, e := range skipped {
+			heap.Push(h, e)
+		}
+	}
+
+	if found == nil {
+		return nil, wait
+	}
+
+	if m.store != nil {
+		if err := m.store.DeleteQueueEntry(found.Seq); err != nil {
+			m.logError(err)
+		}
+	}
+	if found.Job.ConcurrencyKey != "" {
+		m.removeKeyedJob(found.Job)
+		// The next job of the key may be in another queue.
+		m.wake()
+	}
+	return found.Job, 0
+}
+
+// addKeyedJob adds the job to the jobs of its concurrency key.
+// It must be called with the lock held.
+func (m *QueueManager) addKeyedJob(job *structs.Job) {
+	if job.ConcurrencyKey == "" {
+		return
+	}
+	ids := m.keyedJobs[job.ConcurrencyKey]
+	i := sort.Search(len(ids), func(i int) bool { return ids[i] >= job.ID })
+	if i < len(ids) && ids[i] == job.ID {
+		return
+	}
+	ids = append(ids, 0)
+	copy(ids[i+1:], ids[i:])
+	ids[i] = job.ID
+	m.keyedJobs[job.ConcurrencyKey] = ids
+}
+
+// removeKeyedJob removes the job from the jobs of its concurrency key.
+// It must be called with the lock held.
+func (m *QueueManager) removeKeyedJob(job *structs.Job) {
+	ids := m.keyedJobs[job.ConcurrencyKey]
+	i := sort.Search(len(ids), func(i int) bool { return ids[i] >= job.ID })
+	if i == len(ids) || ids[i] != job.ID {
+		return
+	}
+	ids = append(ids[:i], ids[i+1:]...)
+	if len(ids) == 0 {
+		delete(m.keyedJobs, job.ConcurrencyKey)
+	} else {
+		m.keyedJobs[job.ConcurrencyKey] = ids
+	}
+}
+
+// isFirstKeyedJob reports whether the job is the first queued job of its concurrency key.
+// It must be called with the lock held.
+func (m *QueueManager) isFirstKeyedJob(job *structs.Job) bool {
+	if job.ConcurrencyKey == "" {
+		return true
+	}
+	ids := m.keyedJobs[job.ConcurrencyKey]
+	return len(ids) == 0 || ids[0] == job.ID
+}
+
+// RemoveExpiredJobs removes the waiting jobs that passed their deadlines from the queues and returns them.
+// The canceled jobs are left to the dispatchers.
+func (m *QueueManager) RemoveExpiredJobs(now time.Time) []*structs.Job {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var ret []*structs.Job
+	for _, h := range m.queues {
+		var kept []*QueueEntry
+		for _, e := range h.entries {
+			if e.Job.Canceled || !isJobExpired(e.Job, now) {
+				kept = append(kept, e)
+				continue
+			}
+
+			if m.store != nil {
+				if err := m.store.DeleteQueueEntry(e.Seq); err != nil {
+					m.logError(err)
+				}
+			}
+			if e.Job.ConcurrencyKey != "" {
+				m.removeKeyedJob(e.Job)
+			}
+			delete(m.waitingJobs, e.Job.ID)
+			ret = append(ret, e.Job)
+		}
+		if len(kept) != len(h.entries) {
+			h.entries = kept
+			heap.Init(h)
+		}
+	}
+	if len(ret) > 0 {
+		m.wake()
+	}
+
+	return ret
+}
+
+// OverdueJobs returns the scheduled and blocked jobs that passed their deadlines.
+func (m *QueueManager) OverdueJobs(now time.Time) []*structs.Job {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	var ret []*structs.Job
+	for _, job := range m.scheduledJobs {
+		if isJobExpired(job, now) {
+			ret = append(ret, job)
+		}
+	}
+	for _, job := range m.blockedJobs {
+		if isJobExpired(job, now) {
+			ret = append(ret, job)
+		}
+	}
+	return ret
+}
+
+// isJobExpired reports whether the job passed its deadline to start.
+func isJobExpired(job *structs.Job, now time.Time) bool {
+	return job.ExpiresAt != nil && !now.Before(*job.ExpiresAt)
+}
+
+// holding reports whether a gate holds all the jobs.
+// It must be called with the lock held.
+func (m *QueueManager) holding() bool {
+	for _, gate := range m.gates {
+		if h, ok := gate.(DispatchGateHolder); ok && h.Holding() {
+			return true
+		}
+	}
+	return false
+}
+
+// acquire takes the slots of all the gates for the job.
+// It must be called with the lock held.
+func (m *QueueManager) acquire(job *structs.Job, now time.Time) (bool, time.Duration) {
+	for i, gate := range m.gates {
+		if ok, wait := gate.Acquire(job, now); !ok {
+			for _, acquired := range m.gates[:i] {
+				if r, ok := acquired.(DispatchGateRollbacker); ok {
+					r.Rollback(job)
+				} else {
+					acquired.Release(job)
+				}
+			}
+			return false, wait
+		}
+	}
+	return true, 0
+}
+
+// Wake wakes up the dispatchers to check the queued jobs again.
+// It is needed when a gate allows the jobs by itself, not by a Release.
+func (m *QueueManager) Wake() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.wake()
+}
+
+// wake wakes up the goroutines that are blocked in Dequeue.
+// It must be called with the lock held.
+func (m *QueueManager) wake() {
+	close(m.wakeCh)
+	m.wakeCh = make(chan struct{})
+}
+
+func (m *QueueManager) logError(err error) {
+	if m.store != nil {
+		m.store.logger.Error(err)
+	}
+}
+
+// Full reports whether the number of the queued jobs reaches the capacity.
+// If the capacity is 0 or less, the queue is never full.
+func (m *QueueManager) Full() bool {
+	if m.capacity <= 0 {
+		return false
+	}
+	return int64(m.NumJobsInQueue()) >= m.capacity
 }
 
 func (m *QueueManager) RegisterRunningJob(job *structs.Job, cancel context.CancelFunc) {
@@ -57,11 +511,141 @@ func (m *QueueManager) RegisterRunningJob(job *structs.Job, cancel context.Cance
 	delete(m.waitingJobs, job.ID)
 }
 
+// RegisterScheduledJob sets the job as a scheduled job that waits for the time to run in the Timer.
+func (m *QueueManager) RegisterScheduledJob(job *structs.Job) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.scheduledJobs[job.ID] = job
+}
+
+// RemoveScheduledJob removes the job from the scheduled jobs.
+func (m *QueueManager) RemoveScheduledJob(job *structs.Job) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	delete(m.scheduledJobs, job.ID)
+}
+
+// RegisterBlockedJob sets the job as a blocked job that waits for its parent jobs to finish.
+func (m *QueueManager) RegisterBlockedJob(job *structs.Job) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.blockedJobs[job.ID] = job
+}
+
+// RemoveBlockedJob removes the job from the blocked jobs and returns it.
+// It returns nil if the job is not blocked.
+func (m *QueueManager) RemoveBlockedJob(id uint64) *structs.Job {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	job, ok := m.blockedJobs[id]
+	if !ok {
+		return nil
+	}
+	delete(m.blockedJobs, id)
+	return job
+}
+
+// BlockedJob returns the blocked job. It returns nil if the job is not blocked.
+func (m *QueueManager) BlockedJob(id uint64) *structs.Job {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return m.blockedJobs[id]
+}
+
+// RegisterAsyncJob sets the job as a job that the worker runs asynchronously and reports the result by the callback.
+func (m *QueueManager) RegisterAsyncJob(job *structs.Job) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.asyncJobs[job.ID] = job
+}
+
+// AcceptRunningJob moves the running job to the async jobs, because the worker accepted it to run asynchronously.
+// The job keeps the slots of the gates while the worker runs it, and they are released by ReleaseJob.
+func (m *QueueManager) AcceptRunningJob(job *structs.Job) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	delete(m.runningJobs, job.ID)
+	m.asyncJobs[job.ID] = job
+}
+
+// ReleaseJob releases the slots of the gates that are held by the async job that was removed from the async jobs.
+func (m *QueueManager) ReleaseJob(job *structs.Job) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.release(job)
+}
+
+// RemoveAsyncJob removes the job from the async jobs and returns it.
+// It returns nil if the job is not running asynchronously.
+func (m *QueueManager) RemoveAsyncJob(id uint64) *structs.Job {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	job, ok := m.asyncJobs[id]
+	if !ok {
+		return nil
+	}
+	delete(m.asyncJobs, id)
+	return job
+}
+
+// HeartbeatAsyncJob records the heartbeat of the async job.
+// It returns false if the job is not running asynchronously.
+func (m *QueueManager) HeartbeatAsyncJob(id uint64, now time.Time) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	job, ok := m.asyncJobs[id]
+	if !ok {
+		return false
+	}
+	job.HeartbeatAt = &now
+	return true
+}
+
+// RemoveStaleAsyncJobs removes the async jobs whose last heartbeats are before the deadline and returns them.
+func (m *QueueManager) RemoveStaleAsyncJobs(deadline time.Time) []*structs.Job {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var ret []*structs.Job
+	for id, job := range m.asyncJobs {
+		if job.HeartbeatAt == nil || job.HeartbeatAt.Before(deadline) {
+			delete(m.asyncJobs, id)
+			ret = append(ret, job)
+		}
+	}
+	return ret
+}
+
 func (m *QueueManager) RemoveRunningJob(job *structs.Job) {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
 	delete(m.runningJobs, job.ID)
+	m.release(job)
+}
+
+// release releases the slots of the gates, and wakes up the dispatchers that wait for them.
+// It must be called with the lock held.
+func (m *QueueManager) release(job *structs.Job) {
+	if len(m.gates) > 0 {
+		for _, gate := range m.gates {
+			gate.Release(job)
+		}
+		// The held jobs are checked again when the dispatching is resumed.
+		if !m.holding() {
+			m.wake()
+		}
+	}
 }
 
 func (m *QueueManager) CancelJob(id uint64) {
@@ -73,6 +657,8 @@ func (m *QueueManager) CancelJob(id uint64) {
 		rJob.Cancel()
 	} else if wJob, ok := m.waitingJobs[id]; ok {
 		wJob.Job.Canceled = true
+		// The canceled job is taken from the queue even if it is held by the gates.
+		m.wake()
 	}
 }
 
@@ -83,14 +669,42 @@ func (m *QueueManager) LoadJobStatus(job *structs.Job) *structs.Job {
 	if rJob, ok := m.runningJobs[job.ID]; ok {
 		job.Running = true
 		job.Canceled = rJob.Job.Canceled
+	} else if aJob, ok := m.asyncJobs[job.ID]; ok {
+		job.RunningAsync = true
+		job.HeartbeatAt = aJob.HeartbeatAt
 	} else if wJob, ok := m.waitingJobs[job.ID]; ok {
 		job.Waiting = true
 		job.Canceled = wJob.Job.Canceled
+	} else if _, ok := m.scheduledJobs[job.ID]; ok {
+		job.Scheduled = true
+	} else if _, ok := m.blockedJobs[job.ID]; ok {
+		job.Blocked = true
 	}
 
 	return job
 }
 
+// IsActive reports whether the job is waiting, running, scheduled or blocked.
+func (m *QueueManager) IsActive(id uint64) bool {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if _, ok := m.runningJobs[id]; ok {
+		return true
+	}
+	if _, ok := m.scheduledJobs[id]; ok {
+		return true
+	}
+	if _, ok := m.blockedJobs[id]; ok {
+		return true
+	}
+	if _, ok := m.asyncJobs[id]; ok {
+		return true
+	}
+	_, ok := m.waitingJobs[id]
+	return ok
+}
+
 func (m *QueueManager) NumJobsWaiting() int {
 	m.mutex.RLock()
 	defer m.mutex.RUnlock()
@@ -105,8 +719,75 @@ func (m *QueueManager) NumJobsRunning() int {
 	return len(m.runningJobs)
 }
 
+func (m *QueueManager) NumJobsRunningAsync() int {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return len(m.asyncJobs)
+}
+
+func (m *QueueManager) NumJobsScheduled() int {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return len(m.scheduledJobs)
+}
+
+func (m *QueueManager) NumJobsBlocked() int {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return len(m.blockedJobs)
+}
+
 func (m *QueueManager) NumJobsInQueue() int {
-	return len(m.Queue)
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return m.numJobsInQueue()
+}
+
+// numJobsInQueue returns the number of the jobs in all the queues. It must be called with the lock held.
+func (m *QueueManager) numJobsInQueue() int {
+	n := 0
+	for _, h := range m.queues {
+		n += h.Len()
+	}
+	return n
+}
+
+// QueueStats returns the number of the jobs of the named queue.
+func (m *QueueManager) QueueStats(name string) *structs.QueueStats {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	stats := &structs.QueueStats{}
+	if h, ok := m.queues[name]; ok {
+		stats.NumJobsInQueue = h.Len()
+	}
+	for _, wJob := range m.waitingJobs {
+		if wJob.Job.Queue == name {
+			stats.NumJobsWaiting++
+		}
+	}
+	for _, rJob := range m.runningJobs {
+		if rJob.Job.Queue == name {
+			stats.NumJobsRunning++
+		}
+	}
+	return stats
+}
+
+// NumJobsWaitingByPriority returns the number of the waiting jobs per priority.
+func (m *QueueManager) NumJobsWaitingByPriority() map[int]int {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	ret := map[int]int{}
+	for _, wJob := range m.waitingJobs {
+		ret[wJob.Job.Priority]++
+	}
+	return ret
 }
 
 type WaitingJob struct {
@@ -117,3 +798,46 @@ type RunningJob struct {
 	Job    *structs.Job
 	Cancel context.CancelFunc
 }
+
+// queueEntries implements heap.Interface.
+// The entry that has the highest priority comes first, and the entries of the same priority are ordered by the sequence.
+// If the aging is set, the priority of an entry is raised by 1 for every aging interval while it is waiting.
+// Because all the entries are aged at the same rate, the order can be decided by the static score
+// 'priority * aging - enqueuedAt' without re-sorting the heap over time.
+type queueEntries struct {
+	entries []*QueueEntry
+	aging   time.Duration
+}
+
+func (h *queueEntries) score(e *QueueEntry) int64 {
+	return int64(e.Job.Priority)*int64(h.aging) - e.EnqueuedAt.UnixNano()
+}
+
+func (h *queueEntries) Len() int { return len(h.entries) }
+
+func (h *queueEntries) Less(i, j int) bool {
+	a, b := h.entries[i], h.entries[j]
+	if h.aging > 0 {
+		if sa, sb := h.score(a), h.score(b); sa != sb {
+			return sa > sb
+		}
+	} else if a.Job.Priority != b.Job.Priority {
+		return a.Job.Priority > b.Job.Priority
+	}
+	return a.Seq < b.Seq
+}
+
+func (h *queueEntries) Swap(i, j int) { h.entries[i], h.entries[j] = h.entries[j], h.entries[i] }
+
+func (h *queueEntries) Push(x interface{}) {
+	h.entries = append(h.entries, x.(*QueueEntry))
+}
+
+func (h *queueEntries) Pop() interface{} {
+	old := h.entries
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	h.entries = old[:n-1]
+	return e
+}
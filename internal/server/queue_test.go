@@ -1,6 +1,7 @@
 package server
 
 import (
+	"container/heap"
 	"fmt"
 	"testing"
 	"time"
@@ -10,75 +11,346 @@ import (
 	"github.com/kohkimakimoto/hq/internal/structs"
 )
 
-func TestQueueManager_EnqueueAsync(t *testing.T) {
+func TestQueueManager_Enqueue(t *testing.T) {
 	m := NewQueueManager(10)
-	for i := uint64(0); i < 20; i++ {
-		m.EnqueueAsync(&structs.Job{
+	for i := uint64(0); i < 10; i++ {
+		err := m.Enqueue(&structs.Job{
 			ID:   i,
 			Name: fmt.Sprintf("job-%d", i),
 		})
-	}
-
-	// wait for jobs to be enqueued fully
-	for m.NumJobsInQueue() < 10 {
-		time.Sleep(time.Millisecond * 1)
+		assert.NoError(t, err)
 	}
 
 	// check queue status
 	assert.Equal(t, 10, m.NumJobsInQueue())
-	assert.Equal(t, 20, m.NumJobsWaiting())
+	assert.Equal(t, 10, m.NumJobsWaiting())
 	assert.Equal(t, 0, m.NumJobsRunning())
+	assert.True(t, m.Full())
+}
+
+func TestQueueManager_EnqueueFull(t *testing.T) {
+	m := NewQueueManager(2)
+	assert.NoError(t, m.Enqueue(&structs.Job{ID: 1}))
+
+	// none of the jobs is added if the queue does not have the capacity for all of them.
+	assert.Equal(t, ErrQueueFull, m.EnqueueAll([]*structs.Job{{ID: 2}, {ID: 3}}))
+	assert.Equal(t, 1, m.NumJobsInQueue())
+
+	assert.NoError(t, m.Enqueue(&structs.Job{ID: 2}))
+	assert.Equal(t, ErrQueueFull, m.Enqueue(&structs.Job{ID: 3}))
+
+	// the accepted job is requeued regardless of the capacity.
+	assert.NoError(t, m.Requeue(&structs.Job{ID: 4}))
+	assert.Equal(t, 3, m.NumJobsInQueue())
 }
 
 func TestQueueManager_Dequeue(t *testing.T) {
 	m := NewQueueManager(10)
 	for i := uint64(0); i < 10; i++ {
-		m.EnqueueAsync(&structs.Job{
+		err := m.Enqueue(&structs.Job{
 			ID:   i,
 			Name: fmt.Sprintf("job-%d", i),
 		})
+		assert.NoError(t, err)
 	}
 
-	// wait for jobs to be enqueued fully
-	for m.NumJobsInQueue() < 10 {
-		time.Sleep(time.Millisecond * 1)
-	}
-
-	// check queue status
-	assert.Equal(t, 10, m.NumJobsInQueue())
-	assert.Equal(t, 10, m.NumJobsWaiting())
-	assert.Equal(t, 0, m.NumJobsRunning())
-
 	// dequeue the first job
-	_ = m.Dequeue()
+	job := m.Dequeue("")
+	assert.Equal(t, uint64(0), job.ID)
 
 	// check queue status
 	assert.Equal(t, 9, m.NumJobsInQueue())
 	assert.Equal(t, 10, m.NumJobsWaiting()) // still waiting status
 	assert.Equal(t, 0, m.NumJobsRunning())
+	assert.False(t, m.Full())
+
+	// the jobs are dequeued in FIFO order
+	for i := uint64(1); i < 10; i++ {
+		job := m.Dequeue("")
+		assert.Equal(t, i, job.ID)
+	}
+}
+
+func TestQueueManager_DequeueOrStop(t *testing.T) {
+	m := NewQueueManager(10)
+	assert.NoError(t, m.Enqueue(&structs.Job{ID: 1}))
+
+	stopCh := make(chan struct{})
+	assert.Equal(t, uint64(1), m.DequeueOrStop("", stopCh).ID)
+
+	ch := make(chan *structs.Job)
+	go func() {
+		ch <- m.DequeueOrStop("", stopCh)
+	}()
+	close(stopCh)
+
+	select {
+	case job := <-ch:
+		assert.Nil(t, job)
+	case <-time.After(1 * time.Second):
+		t.Fatal("DequeueOrStop should return when it is stopped")
+	}
+}
+
+func TestQueueManager_AcceptRunningJob(t *testing.T) {
+	m := NewQueueManager(10)
+	m.AddGate(NewConcurrencyKeys())
+
+	assert.NoError(t, m.Enqueue(&structs.Job{ID: 1, ConcurrencyKey: "key"}))
+	assert.NoError(t, m.Enqueue(&structs.Job{ID: 2, ConcurrencyKey: "key"}))
+
+	job1 := m.Dequeue("")
+	assert.Equal(t, uint64(1), job1.ID)
+	m.RegisterRunningJob(job1, func() {})
+	m.AcceptRunningJob(job1)
+	assert.Equal(t, 0, m.NumJobsRunning())
+	assert.Equal(t, 1, m.NumJobsRunningAsync())
+
+	ch := make(chan *structs.Job)
+	go func() {
+		ch <- m.Dequeue("")
+	}()
+
+	// the async job keeps the slot of the key.
+	select {
+	case <-ch:
+		t.Fatal("Dequeue should block while the job of the key is running asynchronously")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	assert.Equal(t, job1, m.RemoveAsyncJob(1))
+	m.ReleaseJob(job1)
+
+	select {
+	case job := <-ch:
+		assert.Equal(t, uint64(2), job.ID)
+	case <-time.After(time.Second):
+		t.Fatal("Dequeue should return the job after the async job of the key finished")
+	}
+}
+
+func TestQueueManager_AsyncJobs(t *testing.T) {
+	m := NewQueueManager(10)
+
+	now := time.Now()
+	m.RegisterAsyncJob(&structs.Job{ID: 1, HeartbeatAt: &now})
+	m.RegisterAsyncJob(&structs.Job{ID: 2, HeartbeatAt: &now})
+	assert.Equal(t, 2, m.NumJobsRunningAsync())
+
+	assert.True(t, m.HeartbeatAsyncJob(2, now.Add(10*time.Second)))
+	assert.False(t, m.HeartbeatAsyncJob(3, now))
+
+	jobs := m.RemoveStaleAsyncJobs(now.Add(5 * time.Second))
+	assert.Len(t, jobs, 1)
+	assert.Equal(t, uint64(1), jobs[0].ID)
+
+	job := m.LoadJobStatus(&structs.Job{ID: 2})
+	assert.True(t, job.RunningAsync)
+
+	assert.Equal(t, uint64(2), m.RemoveAsyncJob(2).ID)
+	assert.Nil(t, m.RemoveAsyncJob(2))
+	assert.Equal(t, 0, m.NumJobsRunningAsync())
+}
+
+func TestQueueManager_DequeueNamedQueue(t *testing.T) {
+	m := NewQueueManager(10)
+	m.AddQueue("slow")
+
+	assert.NoError(t, m.Enqueue(&structs.Job{ID: 1, Queue: "slow"}))
+	assert.NoError(t, m.Enqueue(&structs.Job{ID: 2}))
+	// the job of the unknown queue goes to the default queue
+	assert.NoError(t, m.Enqueue(&structs.Job{ID: 3, Queue: "unknown"}))
+
+	assert.Equal(t, 3, m.NumJobsInQueue())
+
+	stats := m.QueueStats("slow")
+	assert.Equal(t, 1, stats.NumJobsInQueue)
+	assert.Equal(t, 1, stats.NumJobsWaiting)
+	assert.Equal(t, 0, stats.NumJobsRunning)
+
+	assert.Equal(t, uint64(1), m.Dequeue("slow").ID)
+	assert.Equal(t, uint64(2), m.Dequeue("").ID)
+	assert.Equal(t, uint64(3), m.Dequeue("").ID)
+	assert.Equal(t, 0, m.NumJobsInQueue())
+}
+
+func TestQueueManager_DequeueGate(t *testing.T) {
+	m := NewQueueManager(10)
+	m.AddGate(NewHostLimiter([]*HostLimitConfig{
+		{Match: "limited.example.com", Concurrency: 1},
+	}))
+
+	assert.NoError(t, m.Enqueue(&structs.Job{ID: 1, URL: "http://limited.example.com/"}))
+	assert.NoError(t, m.Enqueue(&structs.Job{ID: 2, URL: "http://limited.example.com/"}))
+	assert.NoError(t, m.Enqueue(&structs.Job{ID: 3, URL: "http://other.example.com/"}))
+
+	job1 := m.Dequeue("")
+	assert.Equal(t, uint64(1), job1.ID)
+	m.RegisterRunningJob(job1, func() {})
+
+	// the job 2 is skipped while the job 1 is running.
+	assert.Equal(t, uint64(3), m.Dequeue("").ID)
+	assert.Equal(t, 1, m.NumJobsInQueue())
+
+	ch := make(chan *structs.Job)
+	go func() {
+		ch <- m.Dequeue("")
+	}()
+
+	select {
+	case <-ch:
+		t.Fatal("Dequeue should block while the limit is reached")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	m.RemoveRunningJob(job1)
+
+	select {
+	case job := <-ch:
+		assert.Equal(t, uint64(2), job.ID)
+	case <-time.After(time.Second):
+		t.Fatal("Dequeue should return the job after the limit is released")
+	}
+}
+
+func TestQueueManager_DequeuePaused(t *testing.T) {
+	m := NewQueueManager(10)
+	p := NewPauses()
+	m.AddGate(p)
+	assert.NoError(t, p.Pause(""))
+
+	assert.NoError(t, m.Enqueue(&structs.Job{ID: 1}))
+	assert.NoError(t, m.Enqueue(&structs.Job{ID: 2}))
+
+	ch := make(chan *structs.Job)
+	go func() {
+		ch <- m.Dequeue("")
+	}()
+
+	select {
+	case <-ch:
+		t.Fatal("Dequeue should block while dispatching is paused")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// the canceled job is taken while dispatching is paused.
+	m.CancelJob(2)
+
+	select {
+	case job := <-ch:
+		assert.Equal(t, uint64(2), job.ID)
+		assert.True(t, job.Canceled)
+	case <-time.After(time.Second):
+		t.Fatal("Dequeue should return the canceled job")
+	}
+	assert.Equal(t, 1, m.NumJobsInQueue())
+
+	go func() {
+		ch <- m.Dequeue("")
+	}()
+
+	p.Resume("")
+	m.Wake()
+
+	select {
+	case job := <-ch:
+		assert.Equal(t, uint64(1), job.ID)
+	case <-time.After(time.Second):
+		t.Fatal("Dequeue should return the job after dispatching is resumed")
+	}
+}
+
+func TestQueueManager_DequeueConcurrencyKey(t *testing.T) {
+	m := NewQueueManager(10)
+	m.AddGate(NewConcurrencyKeys())
+
+	assert.NoError(t, m.Enqueue(&structs.Job{ID: 1, ConcurrencyKey: "key"}))
+	// the later job of the key waits for the earlier one even if it has a higher priority.
+	assert.NoError(t, m.Enqueue(&structs.Job{ID: 2, ConcurrencyKey: "key", Priority: 10}))
+	assert.NoError(t, m.Enqueue(&structs.Job{ID: 3, ConcurrencyKey: "other"}))
+
+	job1 := m.Dequeue("")
+	assert.Equal(t, uint64(1), job1.ID)
+	m.RegisterRunningJob(job1, func() {})
+
+	// the job 2 is skipped while the job 1 is running.
+	assert.Equal(t, uint64(3), m.Dequeue("").ID)
+	assert.Equal(t, 1, m.NumJobsInQueue())
+
+	ch := make(chan *structs.Job)
+	go func() {
+		ch <- m.Dequeue("")
+	}()
+
+	select {
+	case <-ch:
+		t.Fatal("Dequeue should block while the job of the key is running")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	m.RemoveRunningJob(job1)
+
+	select {
+	case job := <-ch:
+		assert.Equal(t, uint64(2), job.ID)
+	case <-time.After(time.Second):
+		t.Fatal("Dequeue should return the job after the job of the key finished")
+	}
+}
+
+func TestQueueManager_RemoveExpiredJobs(t *testing.T) {
+	m := NewQueueManager(10)
+	now := time.Now()
+	past := now.Add(-1 * time.Second)
+
+	assert.NoError(t, m.Enqueue(&structs.Job{ID: 1, ExpiresAt: &past}))
+	assert.NoError(t, m.Enqueue(&structs.Job{ID: 2}))
+	assert.NoError(t, m.Enqueue(&structs.Job{ID: 3, ExpiresAt: &past, ConcurrencyKey: "key"}))
+
+	jobs := m.RemoveExpiredJobs(now)
+	assert.Len(t, jobs, 2)
+	assert.Equal(t, 1, m.NumJobsInQueue())
+	assert.Equal(t, 1, m.NumJobsWaiting())
+	assert.Equal(t, uint64(2), m.Dequeue("").ID)
+}
+
+func TestQueueManager_DequeueBlocks(t *testing.T) {
+	m := NewQueueManager(10)
+
+	ch := make(chan *structs.Job)
+	go func() {
+		ch <- m.Dequeue("")
+	}()
+
+	select {
+	case <-ch:
+		t.Fatal("Dequeue should block while the queue is empty")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	err := m.Enqueue(&structs.Job{ID: 1})
+	assert.NoError(t, err)
+
+	select {
+	case job := <-ch:
+		assert.Equal(t, uint64(1), job.ID)
+	case <-time.After(1 * time.Second):
+		t.Fatal("Dequeue should return the enqueued job")
+	}
 }
 
 func TestQueueManager_RegisterRunningJob(t *testing.T) {
 	m := NewQueueManager(10)
 	for i := uint64(0); i < 10; i++ {
-		m.EnqueueAsync(&structs.Job{
+		err := m.Enqueue(&structs.Job{
 			ID:   i,
 			Name: fmt.Sprintf("job-%d", i),
 		})
+		assert.NoError(t, err)
 	}
 
-	// wait for jobs to be enqueued fully
-	for m.NumJobsInQueue() < 10 {
-		time.Sleep(time.Millisecond * 1)
-	}
-
-	// check queue status
-	assert.Equal(t, 10, m.NumJobsInQueue())
-	assert.Equal(t, 10, m.NumJobsWaiting())
-	assert.Equal(t, 0, m.NumJobsRunning())
-
 	// dequeue the first job
-	job := m.Dequeue()
+	job := m.Dequeue("")
 	m.RegisterRunningJob(job, func() {})
 
 	// check queue status
@@ -90,24 +362,15 @@ func TestQueueManager_RegisterRunningJob(t *testing.T) {
 func TestQueueManager_RemoveRunningJob(t *testing.T) {
 	m := NewQueueManager(10)
 	for i := uint64(0); i < 10; i++ {
-		m.EnqueueAsync(&structs.Job{
+		err := m.Enqueue(&structs.Job{
 			ID:   i,
 			Name: fmt.Sprintf("job-%d", i),
 		})
+		assert.NoError(t, err)
 	}
 
-	// wait for jobs to be enqueued fully
-	for m.NumJobsInQueue() < 10 {
-		time.Sleep(time.Millisecond * 1)
-	}
-
-	// check queue status
-	assert.Equal(t, 10, m.NumJobsInQueue())
-	assert.Equal(t, 10, m.NumJobsWaiting())
-	assert.Equal(t, 0, m.NumJobsRunning())
-
 	// dequeue the first job
-	job := m.Dequeue()
+	job := m.Dequeue("")
 	m.RegisterRunningJob(job, func() {})
 
 	// check queue status
@@ -123,3 +386,79 @@ func TestQueueManager_RemoveRunningJob(t *testing.T) {
 	assert.Equal(t, 9, m.NumJobsWaiting())
 	assert.Equal(t, 0, m.NumJobsRunning())
 }
+
+func TestQueueManager_Restore(t *testing.T) {
+	m := NewQueueManager(10)
+	store := testStore(t, m)
+	err := m.Restore(store)
+	assert.NoError(t, err)
+
+	for i := uint64(1); i <= 3; i++ {
+		job := &structs.Job{
+			ID:   i,
+			Name: fmt.Sprintf("job-%d", i),
+		}
+		assert.NoError(t, store.CreateJob(job))
+		assert.NoError(t, m.Enqueue(job))
+	}
+
+	// the first job is dequeued, so it is not persisted anymore.
+	job := m.Dequeue("")
+	assert.Equal(t, uint64(1), job.ID)
+
+	// restore the queue as another process
+	m2 := NewQueueManager(10)
+	err = m2.Restore(store)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 2, m2.NumJobsInQueue())
+	assert.Equal(t, 2, m2.NumJobsWaiting())
+	assert.Equal(t, uint64(2), m2.Dequeue("").ID)
+	assert.Equal(t, uint64(3), m2.Dequeue("").ID)
+}
+
+func TestQueueManager_DequeuePriority(t *testing.T) {
+	m := NewQueueManager(10)
+	priorities := []int{0, 10, -5, 10, 0}
+	for i, p := range priorities {
+		err := m.Enqueue(&structs.Job{
+			ID:       uint64(i),
+			Priority: p,
+		})
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, map[int]int{0: 2, 10: 2, -5: 1}, m.NumJobsWaitingByPriority())
+
+	// the highest priority first, and FIFO in the same priority
+	ids := []uint64{}
+	for i := 0; i < len(priorities); i++ {
+		ids = append(ids, m.Dequeue("").ID)
+	}
+	assert.Equal(t, []uint64{1, 3, 0, 4, 2}, ids)
+}
+
+func TestQueueManager_DequeuePriorityAging(t *testing.T) {
+	m := NewQueueManager(10)
+	m.SetPriorityAging(time.Second)
+
+	now := time.Now()
+	// A low priority job that has waited for 10 seconds is aged to priority 10.
+	heapPush := func(id uint64, priority int, enqueuedAt time.Time) {
+		m.queues[""].Push(&QueueEntry{
+			Seq:        id,
+			EnqueuedAt: enqueuedAt,
+			Job:        &structs.Job{ID: id, Priority: priority},
+		})
+	}
+	heapPush(1, 5, now)
+	heapPush(2, 0, now.Add(-10*time.Second))
+	heapPush(3, 12, now)
+	heap.Init(m.queues[""])
+
+	ids := []uint64{}
+	for i := 0; i < 3; i++ {
+		ids = append(ids, m.Dequeue("").ID)
+	}
+	assert.Equal(t, []uint64{3, 2, 1}, ids)
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kohkimakimoto/hq/internal/structs"
+)
+
+const (
+	// RecoverRunningJobsRetry enqueues the interrupted running jobs again.
+	RecoverRunningJobsRetry = "retry"
+	// RecoverRunningJobsFail marks the interrupted running jobs as failure.
+	RecoverRunningJobsFail = "fail"
+	// RecoverRunningJobsIgnore leaves the interrupted running jobs alone.
+	RecoverRunningJobsIgnore = "ignore"
+)
+
+func validateRecoverRunningJobs(v string) error {
+	switch v {
+	case RecoverRunningJobsRetry, RecoverRunningJobsFail, RecoverRunningJobsIgnore:
+		return nil
+	}
+	return fmt.Errorf("invalid recover_running_jobs '%s' (must be retry|fail|ignore)", v)
+}
+
+// recoverJobs loads the unfinished jobs that were left by the previous process.
+// The waiting and scheduled jobs that are not in the persisted queue or timer are enqueued or scheduled again. The interrupted running jobs are
+// handled according to the 'recover_running_jobs' config.
+func (a *App) recoverJobs() error {
+	logger := a.Echo.Logger
+
+	jobs, err := a.Store.ListUnfinishedJobs()
+	if err != nil {
+		return err
+	}
+
+	for _, job := range jobs {
+		if a.QueueManager.IsActive(job.ID) {
+			// It has already been restored from the persisted queue.
+			continue
+		}
+
+		if job.StartedAt == nil && len(job.DependsOn) > 0 {
+			job.AddEvent(structs.JobEventRecovered, "The job that depends on the other jobs was restored after the server restarted.")
+			if err := a.Store.UpdateJob(job); err != nil {
+				return err
+			}
+			// The parents are recovered before the job, because they have the smaller IDs.
+			if err := a.blockJob(job); err != nil {
+				return err
+			}
+			logger.Infof("job: %d recovered as a job that depends on the other jobs", job.ID)
+			continue
+		}
+
+		if job.StartedAt == nil && job.RunAt != nil && job.RunAt.After(time.Now()) {
+			job.AddEvent(structs.JobEventRecovered, "The scheduled job was scheduled again after the server restarted.")
+			if err := a.Store.UpdateJob(job); err != nil {
+				return err
+			}
+			if err := a.Timer.Schedule(job); err != nil {
+				return err
+			}
+			logger.Infof("job: %d recovered as a scheduled job", job.ID)
+			continue
+		}
+
+		if job.StartedAt == nil {
+			job.AddEvent(structs.JobEventRecovered, "The waiting job was enqueued again after the server restarted.")
+			if err := a.Store.UpdateJob(job); err != nil {
+				return err
+			}
+			if err := a.QueueManager.Requeue(job); err != nil {
+				return err
+			}
+			logger.Infof("job: %d recovered as a waiting job", job.ID)
+			continue
+		}
+
+		if job.AcceptedAt != nil {
+			// The worker may still be running the job, so it waits for the callback again.
+			// The heartbeat timeout starts over from now.
+			now := time.Now().UTC().Truncate(time.Millisecond)
+			job.HeartbeatAt = &now
+			job.AddEvent(structs.JobEventRecovered, "The asynchronous job was restored after the server restarted. It waits for the callback from the worker again.")
+			if err := a.Store.UpdateJob(job); err != nil {
+				return err
+			}
+			a.QueueManager.RegisterAsyncJob(job)
+			logger.Infof("job: %d recovered as an asynchronous job", job.ID)
+			continue
+		}
+
+		switch a.config().RecoverRunningJobs {
+		case RecoverRunningJobsRetry:
+			job.StartedAt = nil
+			job.AddEvent(structs.JobEventRecovered, "The running job was interrupted by the server restart. It was enqueued again.")
+			if err := a.Store.UpdateJob(job); err != nil {
+				return err
+			}
+			if err := a.QueueManager.Requeue(job); err != nil {
+				return err
+			}
+			logger.Infof("job: %d recovered as an interrupted job. retrying it", job.ID)
+		case RecoverRunningJobsFail:
+			// Truncate millisecond. It is compatible time for katsubushi ID generator timestamp.
+			now := time.Now().UTC().Truncate(time.Millisecond)
+			job.FinishedAt = &now
+			job.Success = false
+			job.Failure = true
+			job.Err = "the job was interrupted by the server restart"
+			job.AddEvent(structs.JobEventRecovered, "The running job was interrupted by the server restart. It was marked as failure.")
+			if err := a.Store.UpdateJob(job); err != nil {
+				return err
+			}
+			logger.Infof("job: %d recovered as an interrupted job. marked it as failure", job.ID)
+		default:
+			job.AddEvent(structs.JobEventRecovered, "The running job was interrupted by the server restart. It was left alone.")
+			if err := a.Store.UpdateJob(job); err != nil {
+				return err
+			}
+			logger.Infof("job: %d was interrupted by the server restart. leave it alone", job.ID)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kohkimakimoto/hq/internal/structs"
+)
+
+func TestApp_recoverJobs(t *testing.T) {
+	tests := []struct {
+		Policy          string
+		ExpectedStatus  string
+		ExpectedWaiting int
+	}{
+		{RecoverRunningJobsRetry, structs.JobStatusWaiting, 2},
+		{RecoverRunningJobsFail, structs.JobStatusFailure, 1},
+		{RecoverRunningJobsIgnore, structs.JobStatusUnfinished, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Policy, func(t *testing.T) {
+			testInitApp(t)
+			g.Config.RecoverRunningJobs = test.Policy
+
+			now := time.Now().UTC().Truncate(time.Millisecond)
+			waitingJob := &structs.Job{ID: 1, URL: "http://example.com"}
+			runningJob := &structs.Job{ID: 2, URL: "http://example.com", StartedAt: &now}
+			finishedJob := &structs.Job{ID: 3, URL: "http://example.com", StartedAt: &now, FinishedAt: &now, Success: true}
+			asyncJob := &structs.Job{ID: 4, URL: "http://example.com", Async: true, StartedAt: &now, AcceptedAt: &now}
+			blockedJob := &structs.Job{ID: 5, URL: "http://example.com", DependsOn: structs.JobIDs{1}}
+			for _, job := range []*structs.Job{waitingJob, runningJob, finishedJob, asyncJob, blockedJob} {
+				assert.NoError(t, g.Store.CreateJob(job))
+			}
+
+			err := g.recoverJobs()
+			assert.NoError(t, err)
+
+			assert.Equal(t, test.ExpectedWaiting, g.QueueManager.NumJobsWaiting())
+
+			job, err := g.Store.GetJob(1)
+			assert.NoError(t, err)
+			assert.Equal(t, structs.JobStatusWaiting, job.Status())
+			assert.Len(t, job.Events, 1)
+			assert.Equal(t, structs.JobEventRecovered, job.Events[0].Type)
+
+			job, err = g.Store.GetJob(2)
+			assert.NoError(t, err)
+			assert.Equal(t, test.ExpectedStatus, job.Status())
+			assert.Len(t, job.Events, 1)
+			assert.Equal(t, structs.JobEventRecovered, job.Events[0].Type)
+
+			job, err = g.Store.GetJob(3)
+			assert.NoError(t, err)
+			assert.Equal(t, structs.JobStatusSuccess, job.Status())
+			assert.Len(t, job.Events, 0)
+
+			job, err = g.Store.GetJob(4)
+			assert.NoError(t, err)
+			assert.Equal(t, structs.JobStatusRunningAsync, job.Status())
+			assert.Len(t, job.Events, 1)
+			assert.Equal(t, structs.JobEventRecovered, job.Events[0].Type)
+
+			job, err = g.Store.GetJob(5)
+			assert.NoError(t, err)
+			assert.Equal(t, structs.JobStatusBlocked, job.Status())
+			assert.Len(t, job.Events, 1)
+			assert.Equal(t, structs.JobEventRecovered, job.Events[0].Type)
+		})
+	}
+}
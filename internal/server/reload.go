@@ -0,0 +1,158 @@
+package server
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/kohkimakimoto/hq/internal/structs"
+)
+
+// reloadableSettings are the settings that can be changed without restarting the server.
+var reloadableSettings = map[string]bool{
+	"log_level":               true,
+	"dispatchers":             true,
+	"max_workers":             true,
+	"job_lifetime":            true,
+	"job_list_default_limit":  true,
+	"async_heartbeat_timeout": true,
+	"webhook":                 true,
+}
+
+// reloadConfig loads the config again and applies the settings that can be changed at runtime.
+// The changes of the other settings are rejected, because they need restarting the server.
+func (a *App) reloadConfig() (*structs.ReloadResult, error) {
+	a.reloadMutex.Lock()
+	defer a.reloadMutex.Unlock()
+
+	logger := a.Echo.Logger
+	current := a.config()
+	if current.Loader == nil {
+		return nil, fmt.Errorf("the server was started without a config file")
+	}
+
+	c, err := current.Loader()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load the config")
+	}
+
+	level, err := c.LogLevel()
+	if err != nil {
+		return nil, err
+	}
+	if c.Dispatchers < 0 {
+		return nil, fmt.Errorf("dispatchers must not be negative")
+	}
+	if c.HeartbeatTimeout < 0 {
+		return nil, fmt.Errorf("async_heartbeat_timeout must not be negative")
+	}
+	if err := validateWebhook(c.Webhook); err != nil {
+		return nil, errors.Wrap(err, "invalid webhook")
+	}
+
+	// The same defaults as NewApp are set, so that they are not detected as changes.
+	// The invalid settings are rejected as changes.
+	_ = validateNamedQueues(c.NamedQueues)
+	_ = validateHostLimits(c.HostLimits)
+
+	result := &structs.ReloadResult{
+		Applied:  []string{},
+		Rejected: []string{},
+	}
+	for _, name := range changedSettings(current, c) {
+		if reloadableSettings[name] {
+			result.Applied = append(result.Applied, name)
+		} else {
+			logger.Errorf("'%s' can not be changed without restarting the server. The change is ignored.", name)
+			result.Rejected = append(result.Rejected, name)
+		}
+	}
+
+	next := *current
+	next.LogLevelString = c.LogLevelString
+	next.Dispatchers = c.Dispatchers
+	next.MaxWorkers = c.MaxWorkers
+	next.JobLifetime = c.JobLifetime
+	next.JobListDefaultLimit = c.JobListDefaultLimit
+	next.HeartbeatTimeout = c.HeartbeatTimeout
+	next.Webhook = c.Webhook
+
+	a.configMutex.Lock()
+	a.Config = &next
+	a.configMutex.Unlock()
+
+	logger.SetLevel(level)
+	a.BackgroundCleaner.SetJobLifetime(next.JobLifetime)
+	a.scaleDispatchers(next.Dispatchers, next.MaxWorkers)
+
+	if len(result.Applied) > 0 {
+		logger.Infof("Reloaded the config: %s", strings.Join(result.Applied, ", "))
+	} else {
+		logger.Info("Reloaded the config: no changes")
+	}
+
+	return result, nil
+}
+
+// changedSettings returns the names of the settings that are different between the configs.
+func changedSettings(a, b *Config) []string {
+	va := reflect.ValueOf(a).Elem()
+	vb := reflect.ValueOf(b).Elem()
+	t := va.Type()
+
+	var ret []string
+	for i := 0; i < t.NumField(); i++ {
+		name := t.Field(i).Tag.Get("toml")
+		if name == "" || name == "-" {
+			continue
+		}
+		if !reflect.DeepEqual(va.Field(i).Interface(), vb.Field(i).Interface()) {
+			ret = append(ret, name)
+		}
+	}
+	return ret
+}
+
+// scaleDispatchers changes the number of the dispatchers of the default queue and their max workers.
+// The removed dispatchers stop after their running jobs finish. The dispatchers of the named queues are not changed.
+func (a *App) scaleDispatchers(n int64, maxWorkers int64) {
+	a.dispatchersMutex.Lock()
+	defer a.dispatchersMutex.Unlock()
+
+	var defaults, others []*Dispatcher
+	for _, d := range a.Dispatchers {
+		if d.queue == "" {
+			defaults = append(defaults, d)
+		} else {
+			others = append(others, d)
+		}
+	}
+
+	for _, d := range defaults {
+		d.SetMaxWorkers(maxWorkers)
+	}
+
+	for int64(len(defaults)) < n {
+		d := a.newDispatcher("", maxWorkers)
+		if a.dispatching {
+			go d.EventLoop()
+		}
+		defaults = append(defaults, d)
+	}
+
+	for int64(len(defaults)) > n {
+		d := defaults[len(defaults)-1]
+		defaults = defaults[:len(defaults)-1]
+		if a.dispatching {
+			a.retiredWg.Add(1)
+			go func() {
+				defer a.retiredWg.Done()
+				d.Stop()
+			}()
+		}
+	}
+
+	a.Dispatchers = append(defaults, others...)
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/labstack/gommon/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApp_ReloadConfig(t *testing.T) {
+	testInitApp(t)
+
+	// the config can not be reloaded without the loader.
+	_, err := g.reloadConfig()
+	assert.Error(t, err)
+
+	base := *g.Config
+	g.Config.Loader = func() (*Config, error) {
+		c := base
+		c.LogLevelString = "debug"
+		c.Dispatchers = base.Dispatchers + 1
+		c.MaxWorkers = 5
+		c.JobListDefaultLimit = 10
+		c.Addr = "127.0.0.1:8080"
+		return &c, nil
+	}
+
+	result, err := g.reloadConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"log_level", "dispatchers", "max_workers", "job_list_default_limit"}, result.Applied)
+	assert.Equal(t, []string{"addr"}, result.Rejected)
+
+	assert.Equal(t, log.DEBUG, g.Echo.Logger.Level())
+	assert.Equal(t, 10, g.config().JobListDefaultLimit)
+	assert.Equal(t, base.Addr, g.config().Addr)
+	assert.NotNil(t, g.config().Loader)
+
+	dispatchers := g.dispatchers()
+	assert.Len(t, dispatchers, int(base.Dispatchers)+1)
+	for _, d := range dispatchers {
+		assert.Equal(t, int64(5), d.maxWorkers)
+	}
+
+	// the removed dispatchers are stopped.
+	g.startDispatchers()
+	g.Config.Loader = func() (*Config, error) {
+		c := base
+		c.Dispatchers = 0
+		return &c, nil
+	}
+
+	result, err = g.reloadConfig()
+	assert.NoError(t, err)
+	assert.Contains(t, result.Applied, "dispatchers")
+	assert.Len(t, g.dispatchers(), 0)
+	assert.True(t, g.stopDispatchers(3*time.Second))
+}
+
+func TestChangedSettings(t *testing.T) {
+	a := NewConfig()
+	b := NewConfig()
+	assert.Len(t, changedSettings(a, b), 0)
+
+	b.Queues = 1
+	b.NamedQueues = map[string]*NamedQueueConfig{"slow": {Concurrency: 1}}
+	b.Loader = func() (*Config, error) { return nil, nil }
+	assert.Equal(t, []string{"queues", "queue"}, changedSettings(a, b))
+}
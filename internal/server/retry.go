@@ -0,0 +1,247 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kohkimakimoto/hq/internal/structs"
+)
+
+const (
+	// DefaultRetryBackoff is the multiplier used when a job does not specify 'retryBackoff'.
+	DefaultRetryBackoff = 2.0
+	// MaxRetryDelay caps the delay between attempts.
+	MaxRetryDelay = 24 * time.Hour
+	// retryJitterFactor is the maximum rate of the random jitter added to a retry delay.
+	retryJitterFactor = 0.2
+)
+
+// DefaultStatusPolicy returns the status policy that is used when it is not configured.
+func DefaultStatusPolicy() *structs.StatusPolicy {
+	return &structs.StatusPolicy{
+		Success:   []string{"2xx"},
+		Retryable: []string{"408", "429", "5xx"},
+		Permanent: []string{"4xx"},
+	}
+}
+
+// StatusCodeError is an error that is caused by the status code of the worker's response.
+type StatusCodeError struct {
+	StatusCode int
+	Retryable  bool
+	RetryAfter time.Duration
+}
+
+func (e *StatusCodeError) Error() string {
+	return http.StatusText(e.StatusCode)
+}
+
+// shouldRetry reports whether the job can be attempted again after the current attempt failed with the err.
+func shouldRetry(job *structs.Job, err error) bool {
+	if job.Canceled {
+		return false
+	}
+
+	if sErr, ok := err.(*StatusCodeError); ok && !sErr.Retryable {
+		return false
+	}
+
+	return job.Attempt <= job.MaxRetries
+}
+
+// retryDelay calculates the delay before the next attempt of the job.
+// The delay grows exponentially by the job's backoff multiplier
+// and up to 20% random jitter is added to avoid a thundering herd.
+// If the worker responded with a 'Retry-After' header, it takes precedence.
+func retryDelay(job *structs.Job, err error) time.Duration {
+	if sErr, ok := err.(*StatusCodeError); ok && sErr.RetryAfter > 0 {
+		if sErr.RetryAfter > MaxRetryDelay {
+			return MaxRetryDelay
+		}
+		return sErr.RetryAfter
+	}
+
+	if job.RetryDelay <= 0 {
+		return 0
+	}
+
+	backoff := job.RetryBackoff
+	if backoff == 0 {
+		backoff = DefaultRetryBackoff
+	}
+
+	retries := job.Attempt - 1
+	if retries < 0 {
+		retries = 0
+	}
+
+	delay := float64(job.RetryDelay) * math.Pow(backoff, float64(retries)) * float64(time.Second)
+	delay = delay + delay*retryJitterFactor*rand.Float64()
+	if delay > float64(MaxRetryDelay) {
+		return MaxRetryDelay
+	}
+
+	return time.Duration(delay)
+}
+
+// retryJob enqueues the failed job again. If the delay is specified, the job is scheduled by the timer.
+func retryJob(store *Store, queueManager *QueueManager, timer *Timer, job *structs.Job, delay time.Duration) error {
+	if delay <= 0 {
+		job.RunAt = nil
+		if err := store.UpdateJob(job); err != nil {
+			return err
+		}
+		return queueManager.Requeue(job)
+	}
+
+	// Truncate millisecond. It is compatible time for katsubushi ID generator timestamp.
+	runAt := time.Now().UTC().Add(delay).Truncate(time.Millisecond)
+	job.RunAt = &runAt
+	if err := store.UpdateJob(job); err != nil {
+		return err
+	}
+	return timer.Schedule(job)
+}
+
+// parseRetryAfter parses the value of 'Retry-After' header.
+// It supports both delay-seconds and HTTP-date formats.
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0
+	}
+
+	if sec, err := strconv.ParseInt(v, 10, 64); err == nil {
+		if sec <= 0 {
+			return 0
+		}
+		return time.Duration(sec) * time.Second
+	}
+
+	if t, err := http.ParseTime(v); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d
+		}
+	}
+
+	return 0
+}
+
+const (
+	statusClassSuccess = iota
+	statusClassRetryable
+	statusClassPermanent
+)
+
+// classifyStatusCode decides how the status code is treated by the policy.
+// When the status code matches patterns in multiple lists, the narrowest pattern wins.
+// A status code that does not match any pattern is a permanent failure.
+func classifyStatusCode(policy *structs.StatusPolicy, code int) int {
+	class := statusClassPermanent
+	width := -1
+
+	lists := []struct {
+		class    int
+		patterns []string
+	}{
+		{statusClassSuccess, policy.Success},
+		{statusClassPermanent, policy.Permanent},
+		{statusClassRetryable, policy.Retryable},
+	}
+
+	for _, l := range lists {
+		for _, pattern := range l.patterns {
+			lo, hi, err := parseStatusCodePattern(pattern)
+			if err != nil {
+				continue
+			}
+			if code < lo || code > hi {
+				continue
+			}
+			if width < 0 || hi-lo < width {
+				class = l.class
+				width = hi - lo
+			}
+		}
+	}
+
+	return class
+}
+
+// parseStatusCodePattern parses a status code pattern like "200", "2xx" or "500-599"
+// and returns the range of the status codes.
+func parseStatusCodePattern(pattern string) (int, int, error) {
+	p := strings.ToLower(strings.TrimSpace(pattern))
+
+	if len(p) == 3 && strings.HasSuffix(p, "xx") {
+		n, err := strconv.Atoi(p[:1])
+		if err != nil || n < 1 || n > 5 {
+			return 0, 0, fmt.Errorf("invalid status code pattern '%s'", pattern)
+		}
+		return n * 100, n*100 + 99, nil
+	}
+
+	if i := strings.Index(p, "-"); i >= 0 {
+		lo, err1 := strconv.Atoi(p[:i])
+		hi, err2 := strconv.Atoi(p[i+1:])
+		if err1 != nil || err2 != nil || lo > hi || lo < 100 || hi > 599 {
+			return 0, 0, fmt.Errorf("invalid status code pattern '%s'", pattern)
+		}
+		return lo, hi, nil
+	}
+
+	code, err := strconv.Atoi(p)
+	if err != nil || code < 100 || code > 599 {
+		return 0, 0, fmt.Errorf("invalid status code pattern '%s'", pattern)
+	}
+	return code, code, nil
+}
+
+// validateStatusPolicy checks all the patterns in the policy.
+func validateStatusPolicy(policy *structs.StatusPolicy) error {
+	if policy == nil {
+		return nil
+	}
+
+	for _, patterns := range [][]string{policy.Success, policy.Retryable, policy.Permanent} {
+		for _, pattern := range patterns {
+			if _, _, err := parseStatusCodePattern(pattern); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// mergeStatusPolicy returns a policy that the job's lists override the server's default lists.
+func mergeStatusPolicy(base *structs.StatusPolicy, override *structs.StatusPolicy) *structs.StatusPolicy {
+	if base == nil {
+		base = DefaultStatusPolicy()
+	}
+
+	ret := &structs.StatusPolicy{
+		Success:   base.Success,
+		Retryable: base.Retryable,
+		Permanent: base.Permanent,
+	}
+
+	if override != nil {
+		if override.Success != nil {
+			ret.Success = override.Success
+		}
+		if override.Retryable != nil {
+			ret.Retryable = override.Retryable
+		}
+		if override.Permanent != nil {
+			ret.Permanent = override.Permanent
+		}
+	}
+
+	return ret
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kohkimakimoto/hq/internal/structs"
+)
+
+func TestShouldRetry(t *testing.T) {
+	job := &structs.Job{MaxRetries: 2}
+	err := errors.New("failed to do http request")
+
+	job.Attempt = 1
+	assert.True(t, shouldRetry(job, err))
+	job.Attempt = 2
+	assert.True(t, shouldRetry(job, err))
+	job.Attempt = 3
+	assert.False(t, shouldRetry(job, err))
+
+	job.Attempt = 1
+	assert.True(t, shouldRetry(job, &StatusCodeError{StatusCode: 503, Retryable: true}))
+	assert.False(t, shouldRetry(job, &StatusCodeError{StatusCode: 400, Retryable: false}))
+
+	job.Canceled = true
+	assert.False(t, shouldRetry(job, err), "canceled job should not be retried")
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		Job *structs.Job
+		Min time.Duration
+	}{
+		{&structs.Job{RetryDelay: 0, Attempt: 1}, 0},
+		{&structs.Job{RetryDelay: 1, Attempt: 1}, 1 * time.Second},
+		{&structs.Job{RetryDelay: 1, Attempt: 3}, 4 * time.Second},
+		{&structs.Job{RetryDelay: 2, RetryBackoff: 3, Attempt: 3}, 18 * time.Second},
+		{&structs.Job{RetryDelay: 5, RetryBackoff: 1, Attempt: 10}, 5 * time.Second},
+	}
+
+	for _, test := range tests {
+		delay := retryDelay(test.Job, nil)
+		assert.GreaterOrEqual(t, int64(delay), int64(test.Min))
+		// jitter is up to 20%
+		assert.LessOrEqual(t, int64(delay), int64(float64(test.Min)*1.2))
+	}
+
+	// the delay is capped
+	assert.Equal(t, MaxRetryDelay, retryDelay(&structs.Job{RetryDelay: 60, Attempt: 30}, nil))
+
+	// Retry-After takes precedence
+	err := &StatusCodeError{StatusCode: 503, Retryable: true, RetryAfter: 30 * time.Second}
+	assert.Equal(t, 30*time.Second, retryDelay(&structs.Job{RetryDelay: 1, Attempt: 1}, err))
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2019, 10, 29, 7, 32, 26, 0, time.UTC)
+
+	assert.Equal(t, 120*time.Second, parseRetryAfter("120", now))
+	assert.Equal(t, 60*time.Second, parseRetryAfter(now.Add(60*time.Second).Format(http.TimeFormat), now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("-1", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("invalid", now))
+}
+
+func TestClassifyStatusCode(t *testing.T) {
+	policy := DefaultStatusPolicy()
+
+	tests := []struct {
+		StatusCode int
+		Expected   int
+	}{
+		{200, statusClassSuccess},
+		{201, statusClassSuccess},
+		{204, statusClassSuccess},
+		{302, statusClassPermanent},
+		{400, statusClassPermanent},
+		{404, statusClassPermanent},
+		{408, statusClassRetryable},
+		{429, statusClassRetryable},
+		{500, statusClassRetryable},
+		{503, statusClassRetryable},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.Expected, classifyStatusCode(policy, test.StatusCode), "status code %d", test.StatusCode)
+	}
+
+	// the job's policy overrides the default lists
+	policy = mergeStatusPolicy(DefaultStatusPolicy(), &structs.StatusPolicy{
+		Success:   []string{"200"},
+		Permanent: []string{"501", "4xx"},
+	})
+	assert.Equal(t, statusClassPermanent, classifyStatusCode(policy, 201))
+	assert.Equal(t, statusClassPermanent, classifyStatusCode(policy, 501))
+	assert.Equal(t, statusClassRetryable, classifyStatusCode(policy, 502))
+}
+
+func TestParseStatusCodePattern(t *testing.T) {
+	lo, hi, err := parseStatusCodePattern("2xx")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, lo)
+	assert.Equal(t, 299, hi)
+
+	lo, hi, err = parseStatusCodePattern("500-504")
+	assert.NoError(t, err)
+	assert.Equal(t, 500, lo)
+	assert.Equal(t, 504, hi)
+
+	lo, hi, err = parseStatusCodePattern("404")
+	assert.NoError(t, err)
+	assert.Equal(t, 404, lo)
+	assert.Equal(t, 404, hi)
+
+	for _, invalid := range []string{"", "abc", "9xx", "600", "504-500"} {
+		_, _, err = parseStatusCodePattern(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
@@ -0,0 +1,551 @@
+package server
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/kohkimakimoto/hq/internal/structs"
+	"github.com/kohkimakimoto/hq/pkg/cron"
+)
+
+const (
+	// ScheduleMissedThreshold is how late a run can be before it is treated as a missed run.
+	ScheduleMissedThreshold = 1 * time.Minute
+	// MaxScheduleCatchUpRuns caps the number of the missed runs that are run at once by the 'all' catch-up policy.
+	MaxScheduleCatchUpRuns = 100
+)
+
+var scheduleNameRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// Scheduler pushes a new job on every tick of the schedules.
+// The schedules are persisted in the boltdb, so they survive restarts.
+type Scheduler struct {
+	logger       echo.Logger
+	store        *Store
+	queueManager *QueueManager
+	push         func(schedule *structs.Schedule) (*structs.Job, error)
+	cancel       func(id uint64) error
+	// draining reports whether the server is draining. The runs while the server is draining are skipped.
+	draining func() bool
+	mutex    sync.Mutex
+	entries  map[string]*scheduleEntry
+	wakeCh   chan struct{}
+	stopCh   chan bool
+	wg       *sync.WaitGroup
+}
+
+type scheduleEntry struct {
+	schedule *structs.Schedule
+	cron     *cron.Schedule
+	location *time.Location
+}
+
+func NewScheduler(logger echo.Logger, store *Store, queueManager *QueueManager, push func(schedule *structs.Schedule) (*structs.Job, error), cancel func(id uint64) error) *Scheduler {
+	return &Scheduler{
+		logger:       logger,
+		store:        store,
+		queueManager: queueManager,
+		push:         push,
+		cancel:       cancel,
+		entries:      map[string]*scheduleEntry{},
+		wakeCh:       make(chan struct{}, 1),
+		stopCh:       make(chan bool),
+		wg:           &sync.WaitGroup{},
+	}
+}
+
+// validateSchedule checks the schedule and fills the default values.
+func validateSchedule(schedule *structs.Schedule) error {
+	if schedule.Name == "" {
+		return fmt.Errorf("'name' is required")
+	}
+
+	if !scheduleNameRegexp.MatchString(schedule.Name) {
+		return fmt.Errorf("'name' must consist of alphanumeric characters, '-', '_' or '.' but '%s'", schedule.Name)
+	}
+
+	if schedule.Cron == "" {
+		return fmt.Errorf("'cron' is required")
+	}
+
+	e, err := newScheduleEntry(schedule)
+	if err != nil {
+		return err
+	}
+
+	if e.cron.Next(time.Now().In(e.location)).IsZero() {
+		return fmt.Errorf("'cron' never matches '%s'", schedule.Cron)
+	}
+
+	switch schedule.Overlap {
+	case "":
+		schedule.Overlap = structs.ScheduleOverlapSkip
+	case structs.ScheduleOverlapSkip, structs.ScheduleOverlapAllow, structs.ScheduleOverlapCancel:
+	default:
+		return fmt.Errorf("'overlap' must be one of 'skip', 'allow' or 'cancel' but '%s'", schedule.Overlap)
+	}
+
+	switch schedule.CatchUp {
+	case "":
+		schedule.CatchUp = structs.ScheduleCatchUpNone
+	case structs.ScheduleCatchUpNone, structs.ScheduleCatchUpOnce, structs.ScheduleCatchUpAll:
+	default:
+		return fmt.Errorf("'catchUp' must be one of 'none', 'once' or 'all' but '%s'", schedule.CatchUp)
+	}
+
+	if schedule.Job == nil || (schedule.Job.URL == "" && schedule.Job.Queue == "") {
+		// If the job has a named queue, the url can be given by the queue.
+		return fmt.Errorf("'job.url' is required")
+	}
+
+	if schedule.Job.Timeout < 0 {
+		return fmt.Errorf("'job.timeout' must not be negative")
+	}
+
+	if schedule.Job.Priority < MinJobPriority || schedule.Job.Priority > MaxJobPriority {
+		return fmt.Errorf("'job.priority' must be between %d and %d", MinJobPriority, MaxJobPriority)
+	}
+
+	if schedule.Job.CancelURL != "" {
+		if u, err := url.Parse(schedule.Job.CancelURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("'job.cancelUrl' must be a http or https url but '%s'", schedule.Job.CancelURL)
+		}
+	}
+
+	if schedule.Job.NotifyURL != "" {
+		if u, err := url.Parse(schedule.Job.NotifyURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("'job.notifyUrl' must be a http or https url but '%s'", schedule.Job.NotifyURL)
+		}
+	}
+
+	if schedule.Job.ConcurrencyLimit < 0 {
+		return fmt.Errorf("'job.concurrencyLimit' must not be negative")
+	}
+
+	if schedule.Job.MaxRetries < 0 {
+		return fmt.Errorf("'job.maxRetries' must not be negative")
+	}
+
+	if schedule.Job.RetryDelay < 0 {
+		return fmt.Errorf("'job.retryDelay' must not be negative")
+	}
+
+	if schedule.Job.RetryBackoff != 0 && schedule.Job.RetryBackoff < 1 {
+		return fmt.Errorf("'job.retryBackoff' must be greater than or equal to 1")
+	}
+
+	if err := validateStatusPolicy(schedule.Job.StatusPolicy); err != nil {
+		return fmt.Errorf("invalid 'job.statusPolicy': %v", err)
+	}
+
+	if schedule.Job.TTL < 0 {
+		return fmt.Errorf("'job.ttl' must not be negative")
+	}
+
+	return nil
+}
+
+func newScheduleEntry(schedule *structs.Schedule) (*scheduleEntry, error) {
+	c, err := cron.Parse(schedule.Cron)
+	if err != nil {
+		return nil, fmt.Errorf("invalid 'cron': %v", err)
+	}
+
+	loc, err := time.LoadLocation(schedule.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid 'timezone': %v", err)
+	}
+
+	return &scheduleEntry{
+		schedule: schedule,
+		cron:     c,
+		location: loc,
+	}, nil
+}
+
+// next returns the next time to run after the t. It returns nil if the cron never matches.
+func (e *scheduleEntry) next(t time.Time) *time.Time {
+	n := e.cron.Next(t.In(e.location))
+	if n.IsZero() {
+		return nil
+	}
+	n = n.UTC()
+	return &n
+}
+
+// Restore loads the schedules from the boltdb.
+func (s *Scheduler) Restore() error {
+	schedules, err := s.store.ListSchedules()
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, schedule := range schedules {
+		e, err := newScheduleEntry(schedule)
+		if err != nil {
+			s.logger.Errorf("schedule: %s is ignored: %v", schedule.Name, err)
+			continue
+		}
+
+		if !schedule.Paused && schedule.NextRunAt == nil {
+			schedule.NextRunAt = e.next(time.Now())
+			if err := s.store.PutSchedule(schedule); err != nil {
+				return err
+			}
+		}
+
+		s.entries[schedule.Name] = e
+	}
+	s.wake()
+
+	return nil
+}
+
+// Put creates or replaces the schedule. The schedule must be validated by validateSchedule.
+// The state of the existing schedule (the last run) is inherited.
+func (s *Scheduler) Put(schedule *structs.Schedule) (*structs.Schedule, error) {
+	e, err := newScheduleEntry(schedule)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// Truncate millisecond. It is compatible time for katsubushi ID generator timestamp.
+	now := time.Now().UTC().Truncate(time.Millisecond)
+
+	schedule.CreatedAt = now
+	schedule.UpdatedAt = now
+	schedule.LastRunAt = nil
+	schedule.LastJobID = 0
+	schedule.NextRunAt = nil
+
+	if old, ok := s.entries[schedule.Name]; ok {
+		schedule.CreatedAt = old.schedule.CreatedAt
+		schedule.LastRunAt = old.schedule.LastRunAt
+		schedule.LastJobID = old.schedule.LastJobID
+		if old.schedule.Cron == schedule.Cron && old.schedule.Timezone == schedule.Timezone {
+			// keep the next time to run, so that the missed runs are handled by the catch-up policy.
+			schedule.NextRunAt = old.schedule.NextRunAt
+		}
+	}
+
+	if schedule.Paused {
+		schedule.NextRunAt = nil
+	} else if schedule.NextRunAt == nil {
+		schedule.NextRunAt = e.next(now)
+	}
+
+	if err := s.store.PutSchedule(schedule); err != nil {
+		return nil, err
+	}
+
+	s.entries[schedule.Name] = e
+	s.wake()
+
+	return copySchedule(schedule), nil
+}
+
+// Declare creates or replaces the schedule that is declared in the config file.
+// Unlike Put, it keeps the paused state of the existing schedule.
+func (s *Scheduler) Declare(schedule *structs.Schedule) (*structs.Schedule, error) {
+	s.mutex.Lock()
+	if old, ok := s.entries[schedule.Name]; ok {
+		schedule.Paused = old.schedule.Paused
+	}
+	s.mutex.Unlock()
+
+	schedule.Declared = true
+	return s.Put(schedule)
+}
+
+// Prune deletes the schedules that were declared in the config file but are not declared anymore.
+// The schedules that were created or replaced by the API are kept. It returns the names of the deleted schedules.
+func (s *Scheduler) Prune(declared []string) ([]string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	keep := map[string]bool{}
+	for _, name := range declared {
+		keep[name] = true
+	}
+
+	deleted := []string{}
+	for _, name := range s.names() {
+		if !s.entries[name].schedule.Declared || keep[name] {
+			continue
+		}
+
+		if err := s.store.DeleteSchedule(name); err != nil {
+			return deleted, err
+		}
+		delete(s.entries, name)
+		deleted = append(deleted, name)
+	}
+	s.wake()
+
+	return deleted, nil
+}
+
+func (s *Scheduler) Get(name string) (*structs.Schedule, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	e, ok := s.entries[name]
+	if !ok {
+		return nil, &ErrScheduleNotFound{Name: name}
+	}
+
+	return copySchedule(e.schedule), nil
+}
+
+// List returns all the schedules in the order of the name.
+func (s *Scheduler) List() []*structs.Schedule {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	schedules := []*structs.Schedule{}
+	for _, name := range s.names() {
+		schedules = append(schedules, copySchedule(s.entries[name].schedule))
+	}
+
+	return schedules
+}
+
+func (s *Scheduler) Delete(name string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.entries[name]; !ok {
+		return &ErrScheduleNotFound{Name: name}
+	}
+
+	if err := s.store.DeleteSchedule(name); err != nil {
+		return err
+	}
+
+	delete(s.entries, name)
+	s.wake()
+
+	return nil
+}
+
+// SetPaused pauses or resumes the schedule.
+// The runs while the schedule is paused are not caught up after it is resumed.
+func (s *Scheduler) SetPaused(name string, paused bool) (*structs.Schedule, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	e, ok := s.entries[name]
+	if !ok {
+		return nil, &ErrScheduleNotFound{Name: name}
+	}
+
+	schedule := e.schedule
+	if schedule.Paused == paused {
+		return copySchedule(schedule), nil
+	}
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	schedule.Paused = paused
+	schedule.UpdatedAt = now
+	if paused {
+		schedule.NextRunAt = nil
+	} else {
+		schedule.NextRunAt = e.next(now)
+	}
+
+	if err := s.store.PutSchedule(schedule); err != nil {
+		return nil, err
+	}
+	s.wake()
+
+	return copySchedule(schedule), nil
+}
+
+func (s *Scheduler) Start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			next := s.tick(time.Now())
+
+			var tm *time.Timer
+			var timerC <-chan time.Time
+			if next != nil {
+				tm = time.NewTimer(time.Until(*next))
+				timerC = tm.C
+			}
+
+			select {
+			case <-timerC:
+			case <-s.wakeCh:
+			case <-s.stopCh:
+				if tm != nil {
+					tm.Stop()
+				}
+				return
+			}
+
+			if tm != nil {
+				tm.Stop()
+			}
+		}
+	}()
+}
+
+func (s *Scheduler) Stop() {
+	close(s.stopCh)
+	s.wg.Wait()
+}
+
+// tick runs all the due schedules and returns the earliest next time to run.
+func (s *Scheduler) tick(now time.Time) *time.Time {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var next *time.Time
+	for _, name := range s.names() {
+		e := s.entries[name]
+		schedule := e.schedule
+		if schedule.Paused || schedule.NextRunAt == nil {
+			continue
+		}
+
+		if !schedule.NextRunAt.After(now) {
+			s.fire(e, now)
+		}
+
+		if schedule.NextRunAt != nil && (next == nil || schedule.NextRunAt.Before(*next)) {
+			n := *schedule.NextRunAt
+			next = &n
+		}
+	}
+
+	return next
+}
+
+// fire runs the due schedule according to its catch-up policy and advances the next time to run.
+// A run that is later than ScheduleMissedThreshold (e.g. while the server was down) is a missed run.
+func (s *Scheduler) fire(e *scheduleEntry, now time.Time) {
+	schedule := e.schedule
+
+	onTime := 0
+	missed := 0
+	t := schedule.NextRunAt
+	for t != nil && !t.After(now) {
+		if now.Sub(*t) > ScheduleMissedThreshold {
+			missed++
+		} else {
+			onTime++
+		}
+
+		if onTime+missed > MaxScheduleCatchUpRuns {
+			// Too many runs were missed. Skip to the next time after now.
+			t = e.next(now)
+			break
+		}
+		t = e.next(*t)
+	}
+
+	runs := onTime
+	switch schedule.CatchUp {
+	case structs.ScheduleCatchUpOnce:
+		if missed > 0 && runs == 0 {
+			runs = 1
+		}
+	case structs.ScheduleCatchUpAll:
+		runs += missed
+	}
+	if runs > MaxScheduleCatchUpRuns {
+		runs = MaxScheduleCatchUpRuns
+	}
+
+	if missed > 0 {
+		s.logger.Infof("schedule: %s missed %d run(s) (catch-up: %s)", schedule.Name, missed, schedule.CatchUp)
+	}
+
+	for i := 0; i < runs; i++ {
+		// The overlap policy is applied to the job of the previous tick only.
+		// The missed runs that are caught up at once do not overlap each other.
+		if !s.run(schedule, now, i == 0) {
+			break
+		}
+	}
+
+	schedule.NextRunAt = t
+	if err := s.store.PutSchedule(schedule); err != nil {
+		s.logger.Error(err)
+	}
+}
+
+// run pushes a new job of the schedule. If checkOverlap is true, the overlap policy is applied to the previous job.
+// It returns false if the run is skipped.
+func (s *Scheduler) run(schedule *structs.Schedule, now time.Time, checkOverlap bool) bool {
+	if checkOverlap && schedule.LastJobID != 0 && s.queueManager.IsActive(schedule.LastJobID) {
+		switch schedule.Overlap {
+		case structs.ScheduleOverlapAllow:
+		case structs.ScheduleOverlapCancel:
+			if err := s.cancel(schedule.LastJobID); err != nil {
+				s.logger.Error(err)
+			}
+		default:
+			s.logger.Infof("schedule: %s skipped the run, because the previous job %d is still active", schedule.Name, schedule.LastJobID)
+			return false
+		}
+	}
+
+	if s.draining != nil && s.draining() {
+		s.logger.Infof("schedule: %s skipped the run, because the server is draining", schedule.Name)
+		return false
+	}
+
+	job, err := s.push(schedule)
+	if err == ErrQueueFull {
+		s.logger.Warnf("schedule: %s skipped the run, because the queue is full", schedule.Name)
+		return false
+	}
+	if err != nil {
+		s.logger.Errorf("schedule: %s failed to push a job: %v", schedule.Name, err)
+		return false
+	}
+
+	lastRunAt := now.UTC().Truncate(time.Millisecond)
+	schedule.LastRunAt = &lastRunAt
+	schedule.LastJobID = job.ID
+	s.logger.Infof("schedule: %s pushed job: %d", schedule.Name, job.ID)
+	return true
+}
+
+// wake wakes up the scheduler loop to recalculate the next time.
+func (s *Scheduler) wake() {
+	select {
+	case s.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+// names returns the names of the schedules in order. It must be called with the lock held.
+func (s *Scheduler) names() []string {
+	names := make([]string, 0, len(s.entries))
+	for name := range s.entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func copySchedule(schedule *structs.Schedule) *structs.Schedule {
+	c := *schedule
+	return &c
+}
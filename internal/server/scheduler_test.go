@@ -0,0 +1,349 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kohkimakimoto/hq/internal/structs"
+)
+
+func testScheduler(t *testing.T, qm *QueueManager) *Scheduler {
+	t.Helper()
+
+	id := uint64(0)
+	push := func(schedule *structs.Schedule) (*structs.Job, error) {
+		id++
+		job := &structs.Job{
+			ID:   id,
+			Name: schedule.Name,
+			URL:  schedule.Job.URL,
+		}
+		if err := qm.Enqueue(job); err != nil {
+			return nil, err
+		}
+		return job, nil
+	}
+	cancel := func(id uint64) error {
+		qm.CancelJob(id)
+		return nil
+	}
+
+	return NewScheduler(testLogger(t), testStore(t, qm), qm, push, cancel)
+}
+
+func testSchedule(name string) *structs.Schedule {
+	return &structs.Schedule{
+		Name: name,
+		Cron: "* * * * *",
+		Job: &structs.JobTemplate{
+			URL: "http://example.com",
+		},
+	}
+}
+
+func TestValidateSchedule(t *testing.T) {
+	s := testSchedule("example")
+	err := validateSchedule(s)
+	assert.NoError(t, err)
+	assert.Equal(t, structs.ScheduleOverlapSkip, s.Overlap)
+	assert.Equal(t, structs.ScheduleCatchUpNone, s.CatchUp)
+
+	cases := []func(s *structs.Schedule){
+		func(s *structs.Schedule) { s.Name = "" },
+		func(s *structs.Schedule) { s.Name = "foo/bar" },
+		func(s *structs.Schedule) { s.Cron = "" },
+		func(s *structs.Schedule) { s.Cron = "* * *" },
+		func(s *structs.Schedule) { s.Cron = "0 0 30 2 *" },
+		func(s *structs.Schedule) { s.Timezone = "Unknown/Zone" },
+		func(s *structs.Schedule) { s.Overlap = "unknown" },
+		func(s *structs.Schedule) { s.CatchUp = "unknown" },
+		func(s *structs.Schedule) { s.Job = nil },
+		func(s *structs.Schedule) { s.Job.URL = "" },
+		func(s *structs.Schedule) { s.Job.Timeout = -1 },
+		func(s *structs.Schedule) { s.Job.NotifyURL = "ftp://example.com" },
+		func(s *structs.Schedule) { s.Job.ConcurrencyLimit = -1 },
+		func(s *structs.Schedule) { s.Job.MaxRetries = -1 },
+		func(s *structs.Schedule) { s.Job.RetryBackoff = 0.5 },
+		func(s *structs.Schedule) { s.Job.StatusPolicy = &structs.StatusPolicy{Success: []string{"6xx"}} },
+		func(s *structs.Schedule) { s.Job.TTL = -1 },
+	}
+	for _, fn := range cases {
+		s := testSchedule("example")
+		fn(s)
+		assert.Error(t, validateSchedule(s))
+	}
+}
+
+func TestScheduler_Put(t *testing.T) {
+	m := NewQueueManager(10)
+	s := testScheduler(t, m)
+
+	schedule := testSchedule("example")
+	assert.NoError(t, validateSchedule(schedule))
+	ret, err := s.Put(schedule)
+	assert.NoError(t, err)
+	assert.NotNil(t, ret.NextRunAt)
+	assert.True(t, ret.NextRunAt.After(time.Now()))
+
+	// persisted
+	stored, err := s.store.GetSchedule("example")
+	assert.NoError(t, err)
+	assert.Equal(t, "* * * * *", stored.Cron)
+
+	// replace it with paused one
+	schedule = testSchedule("example")
+	schedule.Paused = true
+	assert.NoError(t, validateSchedule(schedule))
+	ret, err = s.Put(schedule)
+	assert.NoError(t, err)
+	assert.Nil(t, ret.NextRunAt)
+	assert.Len(t, s.List(), 1)
+}
+
+func TestScheduler_tick(t *testing.T) {
+	m := NewQueueManager(10)
+	s := testScheduler(t, m)
+
+	schedule := testSchedule("example")
+	assert.NoError(t, validateSchedule(schedule))
+	ret, err := s.Put(schedule)
+	assert.NoError(t, err)
+
+	// not due
+	s.tick(ret.NextRunAt.Add(-time.Second))
+	assert.Equal(t, 0, m.NumJobsInQueue())
+
+	// due
+	now := ret.NextRunAt.Add(time.Second)
+	next := s.tick(now)
+	assert.Equal(t, 1, m.NumJobsInQueue())
+	assert.True(t, next.Equal(ret.NextRunAt.Add(time.Minute)))
+
+	ret, err = s.Get("example")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), ret.LastJobID)
+	assert.NotNil(t, ret.LastRunAt)
+
+	// the previous job is still waiting. the run is skipped by the overlap policy.
+	s.tick(ret.NextRunAt.Add(time.Second))
+	assert.Equal(t, 1, m.NumJobsInQueue())
+}
+
+func TestScheduler_tickOverlapAllow(t *testing.T) {
+	m := NewQueueManager(10)
+	s := testScheduler(t, m)
+
+	schedule := testSchedule("example")
+	schedule.Overlap = structs.ScheduleOverlapAllow
+	assert.NoError(t, validateSchedule(schedule))
+	ret, err := s.Put(schedule)
+	assert.NoError(t, err)
+
+	s.tick(ret.NextRunAt.Add(time.Second))
+	s.tick(ret.NextRunAt.Add(time.Minute + time.Second))
+	assert.Equal(t, 2, m.NumJobsInQueue())
+}
+
+func TestScheduler_tickCatchUp(t *testing.T) {
+	cases := []struct {
+		catchUp  string
+		expected int
+	}{
+		{structs.ScheduleCatchUpNone, 0},
+		{structs.ScheduleCatchUpOnce, 1},
+		{structs.ScheduleCatchUpAll, 10},
+	}
+
+	for _, c := range cases {
+		// the missed runs do not overlap each other.
+		for _, overlap := range []string{structs.ScheduleOverlapAllow, structs.ScheduleOverlapSkip} {
+			m := NewQueueManager(0)
+			s := testScheduler(t, m)
+
+			schedule := testSchedule("example")
+			schedule.Cron = "0 * * * *"
+			schedule.Overlap = overlap
+			schedule.CatchUp = c.catchUp
+			assert.NoError(t, validateSchedule(schedule))
+			ret, err := s.Put(schedule)
+			assert.NoError(t, err)
+
+			// the server was down and missed 10 runs.
+			now := ret.NextRunAt.Add(9*time.Hour + 30*time.Minute)
+			s.tick(now)
+			assert.Equal(t, c.expected, m.NumJobsInQueue(), c.catchUp+"/"+overlap)
+
+			ret, err = s.Get("example")
+			assert.NoError(t, err)
+			assert.True(t, ret.NextRunAt.After(now))
+		}
+	}
+}
+
+func TestScheduler_tickCatchUpOverlapSkip(t *testing.T) {
+	m := NewQueueManager(0)
+	s := testScheduler(t, m)
+
+	schedule := testSchedule("example")
+	schedule.Cron = "0 * * * *"
+	schedule.CatchUp = structs.ScheduleCatchUpAll
+	assert.NoError(t, validateSchedule(schedule))
+	ret, err := s.Put(schedule)
+	assert.NoError(t, err)
+
+	s.tick(ret.NextRunAt.Add(time.Second))
+	assert.Equal(t, 1, m.NumJobsInQueue())
+
+	// the job of the previous tick is still waiting. all the missed runs are skipped by the overlap policy.
+	ret, err = s.Get("example")
+	assert.NoError(t, err)
+	s.tick(ret.NextRunAt.Add(4*time.Hour + 30*time.Minute))
+	assert.Equal(t, 1, m.NumJobsInQueue())
+}
+
+func TestScheduler_tickWhileDraining(t *testing.T) {
+	m := NewQueueManager(10)
+	s := testScheduler(t, m)
+	draining := true
+	s.draining = func() bool { return draining }
+
+	schedule := testSchedule("example")
+	assert.NoError(t, validateSchedule(schedule))
+	ret, err := s.Put(schedule)
+	assert.NoError(t, err)
+
+	s.tick(ret.NextRunAt.Add(time.Second))
+	assert.Equal(t, 0, m.NumJobsInQueue())
+
+	draining = false
+	ret, err = s.Get("example")
+	assert.NoError(t, err)
+	s.tick(ret.NextRunAt.Add(time.Second))
+	assert.Equal(t, 1, m.NumJobsInQueue())
+}
+
+func TestScheduler_SetPaused(t *testing.T) {
+	m := NewQueueManager(10)
+	s := testScheduler(t, m)
+
+	schedule := testSchedule("example")
+	assert.NoError(t, validateSchedule(schedule))
+	ret, err := s.Put(schedule)
+	assert.NoError(t, err)
+
+	ret, err = s.SetPaused("example", true)
+	assert.NoError(t, err)
+	assert.True(t, ret.Paused)
+	assert.Nil(t, ret.NextRunAt)
+
+	// paused schedule is not run
+	next := s.tick(time.Now().Add(time.Hour))
+	assert.Nil(t, next)
+	assert.Equal(t, 0, m.NumJobsInQueue())
+
+	ret, err = s.SetPaused("example", false)
+	assert.NoError(t, err)
+	assert.False(t, ret.Paused)
+	assert.NotNil(t, ret.NextRunAt)
+
+	_, err = s.SetPaused("unknown", true)
+	assert.IsType(t, &ErrScheduleNotFound{}, err)
+}
+
+func TestScheduler_Delete(t *testing.T) {
+	m := NewQueueManager(10)
+	s := testScheduler(t, m)
+
+	schedule := testSchedule("example")
+	assert.NoError(t, validateSchedule(schedule))
+	_, err := s.Put(schedule)
+	assert.NoError(t, err)
+
+	err = s.Delete("example")
+	assert.NoError(t, err)
+	assert.Len(t, s.List(), 0)
+
+	_, err = s.store.GetSchedule("example")
+	assert.IsType(t, &ErrScheduleNotFound{}, err)
+
+	err = s.Delete("example")
+	assert.IsType(t, &ErrScheduleNotFound{}, err)
+}
+
+func TestScheduler_Restore(t *testing.T) {
+	m := NewQueueManager(10)
+	s := testScheduler(t, m)
+
+	for _, name := range []string{"b", "a"} {
+		schedule := testSchedule(name)
+		assert.NoError(t, validateSchedule(schedule))
+		_, err := s.Put(schedule)
+		assert.NoError(t, err)
+	}
+
+	// restore the schedules as if the server restarted
+	s2 := NewScheduler(testLogger(t), s.store, m, s.push, s.cancel)
+	err := s2.Restore()
+	assert.NoError(t, err)
+
+	schedules := s2.List()
+	assert.Len(t, schedules, 2)
+	assert.Equal(t, "a", schedules[0].Name)
+	assert.Equal(t, "b", schedules[1].Name)
+}
+
+func TestScheduler_Declare(t *testing.T) {
+	m := NewQueueManager(10)
+	s := testScheduler(t, m)
+
+	schedule := testSchedule("example")
+	assert.NoError(t, validateSchedule(schedule))
+	_, err := s.Put(schedule)
+	assert.NoError(t, err)
+	_, err = s.SetPaused("example", true)
+	assert.NoError(t, err)
+
+	// the paused state is kept
+	schedule = testSchedule("example")
+	schedule.Cron = "0 * * * *"
+	assert.NoError(t, validateSchedule(schedule))
+	ret, err := s.Declare(schedule)
+	assert.NoError(t, err)
+	assert.True(t, ret.Paused)
+	assert.Equal(t, "0 * * * *", ret.Cron)
+}
+
+func TestScheduler_Prune(t *testing.T) {
+	m := NewQueueManager(10)
+	s := testScheduler(t, m)
+
+	for _, name := range []string{"a", "b"} {
+		schedule := testSchedule(name)
+		assert.NoError(t, validateSchedule(schedule))
+		ret, err := s.Declare(schedule)
+		assert.NoError(t, err)
+		assert.True(t, ret.Declared)
+	}
+
+	// the schedule that is created by the API is not pruned.
+	schedule := testSchedule("c")
+	assert.NoError(t, validateSchedule(schedule))
+	_, err := s.Put(schedule)
+	assert.NoError(t, err)
+
+	// "b" was removed from the config file.
+	deleted, err := s.Prune([]string{"a"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b"}, deleted)
+
+	schedules := s.List()
+	if assert.Len(t, schedules, 2) {
+		assert.Equal(t, "a", schedules[0].Name)
+		assert.Equal(t, "c", schedules[1].Name)
+	}
+
+	_, err = s.store.GetSchedule("b")
+	assert.IsType(t, &ErrScheduleNotFound{}, err)
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"bytes"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
@@ -100,195 +101,1416 @@ func (s *Store) init() error {
 		if _, err := boltutil.CreateBucketIfNotExists(tx, []interface{}{BucketNameForJobs}); err != nil {
 			return err
 		}
+		if _, err := boltutil.CreateBucketIfNotExists(tx, []interface{}{BucketNameForQueue}); err != nil {
+			return err
+		}
+		if _, err := boltutil.CreateBucketIfNotExists(tx, []interface{}{BucketNameForTimer}); err != nil {
+			return err
+		}
+		if _, err := boltutil.CreateBucketIfNotExists(tx, []interface{}{BucketNameForSchedules}); err != nil {
+			return err
+		}
+		if _, err := boltutil.CreateBucketIfNotExists(tx, []interface{}{BucketNameForDependents}); err != nil {
+			return err
+		}
+		if _, err := boltutil.CreateBucketIfNotExists(tx, []interface{}{BucketNameForBatches}); err != nil {
+			return err
+		}
+		if _, err := boltutil.CreateBucketIfNotExists(tx, []interface{}{BucketNameForBatchJobs}); err != nil {
+			return err
+		}
+		if _, err := boltutil.CreateBucketIfNotExists(tx, []interface{}{BucketNameForUniqueKeys}); err != nil {
+			return err
+		}
+		if _, err := boltutil.CreateBucketIfNotExists(tx, []interface{}{BucketNameForPauses}); err != nil {
+			return err
+		}
+		if _, err := boltutil.CreateBucketIfNotExists(tx, []interface{}{BucketNameForProgress}); err != nil {
+			return err
+		}
+		if _, err := boltutil.CreateBucketIfNotExists(tx, []interface{}{BucketNameForLogs}); err != nil {
+			return err
+		}
+		if _, err := boltutil.CreateBucketIfNotExists(tx, []interface{}{BucketNameForDeliveries}); err != nil {
+			return err
+		}
+		return nil
+	})
+}
+
+const (
+	BucketNameForJobs      = "j"
+	BucketNameForQueue     = "q"
+	BucketNameForTimer     = "t"
+	BucketNameForSchedules = "s"
+	// BucketNameForDependents is the reverse index from a parent job to its children.
+	BucketNameForDependents = "d"
+	BucketNameForBatches    = "b"
+	// BucketNameForBatchJobs is the index from a batch to its jobs.
+	BucketNameForBatchJobs = "bj"
+	// BucketNameForUniqueKeys is the index from a unique key to the job.
+	BucketNameForUniqueKeys = "u"
+	BucketNameForPauses     = "p"
+	// BucketNameForProgress has the progress of the jobs that is reported by the workers.
+	BucketNameForProgress = "pg"
+	// BucketNameForLogs has a nested bucket of the log lines for each job.
+	BucketNameForLogs = "l"
+	// BucketNameForDeliveries has the webhook deliveries of the finished jobs.
+	BucketNameForDeliveries = "w"
+)
+
+// J is internal representation of a job in the boltdb.
+type J struct {
+	ID                 uint64
+	Name               string
+	Comment            string
+	URL                string
+	Payload            json.RawMessage
+	Headers            map[string]string
+	Timeout            int64
+	CancelURL          string
+	NotifyURL          string
+	Async              bool
+	CallbackToken      string
+	Queue              string
+	Priority           int
+	DependsOn          []uint64
+	RunOnParentFailure bool
+	BatchID            uint64
+	UniqueKey          string
+	UniqueFor          int64
+	ConcurrencyKey     string
+	ConcurrencyLimit   int
+	MaxRetries         int
+	RetryDelay         int64
+	RetryBackoff       float64
+	StatusPolicy       *structs.StatusPolicy
+	Attempt            int
+	RunAt              *time.Time
+	ExpiresAt          *time.Time
+	CreatedAt          time.Time
+	StartedAt          *time.Time
+	AcceptedAt         *time.Time
+	HeartbeatAt        *time.Time
+	FinishedAt         *time.Time
+	Failure            bool
+	Success            bool
+	Canceled           bool
+	Expired            bool
+	StatusCode         *int
+	Err                string
+	Output             string
+	Events             []*structs.JobEvent
+}
+
+func newJ(job *structs.Job) *J {
+	return &J{
+		ID:                 job.ID,
+		Name:               job.Name,
+		Comment:            job.Comment,
+		URL:                job.URL,
+		Payload:            job.Payload,
+		Headers:            job.Headers,
+		Timeout:            job.Timeout,
+		CancelURL:          job.CancelURL,
+		NotifyURL:          job.NotifyURL,
+		Async:              job.Async,
+		CallbackToken:      job.CallbackToken,
+		Queue:              job.Queue,
+		DependsOn:          job.DependsOn,
+		RunOnParentFailure: job.RunOnParentFailure,
+		BatchID:            job.BatchID,
+		UniqueKey:          job.UniqueKey,
+		UniqueFor:          job.UniqueFor,
+		ConcurrencyKey:     job.ConcurrencyKey,
+		ConcurrencyLimit:   job.ConcurrencyLimit,
+		Priority:           job.Priority,
+		MaxRetries:         job.MaxRetries,
+		RetryDelay:         job.RetryDelay,
+		RetryBackoff:       job.RetryBackoff,
+		StatusPolicy:       job.StatusPolicy,
+		Attempt:            job.Attempt,
+		RunAt:              job.RunAt,
+		ExpiresAt:          job.ExpiresAt,
+		CreatedAt:          job.CreatedAt,
+		StartedAt:          job.StartedAt,
+		AcceptedAt:         job.AcceptedAt,
+		HeartbeatAt:        job.HeartbeatAt,
+		FinishedAt:         job.FinishedAt,
+		Failure:            job.Failure,
+		Success:            job.Success,
+		Canceled:           job.Canceled,
+		Expired:            job.Expired,
+		StatusCode:         job.StatusCode,
+		Err:                job.Err,
+		Output:             job.Output,
+		Events:             job.Events,
+	}
+}
+
+func (in *J) toJob() *structs.Job {
+	return &structs.Job{
+		ID:                 in.ID,
+		Name:               in.Name,
+		Comment:            in.Comment,
+		URL:                in.URL,
+		Payload:            in.Payload,
+		Headers:            in.Headers,
+		Timeout:            in.Timeout,
+		CancelURL:          in.CancelURL,
+		NotifyURL:          in.NotifyURL,
+		Async:              in.Async,
+		CallbackToken:      in.CallbackToken,
+		Queue:              in.Queue,
+		DependsOn:          in.DependsOn,
+		RunOnParentFailure: in.RunOnParentFailure,
+		BatchID:            in.BatchID,
+		UniqueKey:          in.UniqueKey,
+		UniqueFor:          in.UniqueFor,
+		ConcurrencyKey:     in.ConcurrencyKey,
+		ConcurrencyLimit:   in.ConcurrencyLimit,
+		Priority:           in.Priority,
+		MaxRetries:         in.MaxRetries,
+		RetryDelay:         in.RetryDelay,
+		RetryBackoff:       in.RetryBackoff,
+		StatusPolicy:       in.StatusPolicy,
+		Attempt:            in.Attempt,
+		RunAt:              in.RunAt,
+		ExpiresAt:          in.ExpiresAt,
+		CreatedAt:          in.CreatedAt,
+		StartedAt:          in.StartedAt,
+		AcceptedAt:         in.AcceptedAt,
+		HeartbeatAt:        in.HeartbeatAt,
+		FinishedAt:         in.FinishedAt,
+		Failure:            in.Failure,
+		Success:            in.Success,
+		Canceled:           in.Canceled,
+		Expired:            in.Expired,
+		StatusCode:         in.StatusCode,
+		Err:                in.Err,
+		Output:             in.Output,
+		Events:             in.Events,
+	}
+}
+
+type ErrJobNotFound struct {
+	ID uint64
+}
+
+func (e *ErrJobNotFound) Error() string {
+	return fmt.Sprintf("The job '%d' is not found", e.ID)
+}
+
+type ErrJobAlreadyExisted struct {
+	ID   uint64
+	Name string
+}
+
+func (e *ErrJobAlreadyExisted) Error() string {
+	return fmt.Sprintf("'%d' (%s) is already exsited", e.ID, e.Name)
+}
+
+func (s *Store) CreateJob(job *structs.Job) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return createJob(tx, job)
+	})
+}
+
+func createJob(tx *bolt.Tx, job *structs.Job) error {
+	if err := boltutil.Get(tx, []interface{}{BucketNameForJobs}, job.ID, &J{}); err == nil {
+		return &ErrJobAlreadyExisted{ID: job.ID, Name: job.Name}
+	}
+
+	in := newJ(job)
+
+	if err := boltutil.Set(tx, []interface{}{BucketNameForJobs}, job.ID, in); err != nil {
+		return err
+	}
+
+	for _, parentID := range job.DependsOn {
+		if err := boltutil.Set(tx, []interface{}{BucketNameForDependents}, dependentKey(parentID, job.ID), &D{
+			ParentID: parentID,
+			ChildID:  job.ID,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) UpdateJob(job *structs.Job) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := boltutil.Get(tx, []interface{}{BucketNameForJobs}, job.ID, &J{}); err != nil {
+			if err == boltutil.ErrNotFound {
+				return &ErrJobNotFound{ID: job.ID}
+			} else {
+				return err
+			}
+		}
+
+		in := newJ(job)
+
+		if err := boltutil.Set(tx, []interface{}{BucketNameForJobs}, job.ID, in); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (s *Store) DeleteJob(id uint64) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		in := &J{}
+		if err := boltutil.Get(tx, []interface{}{BucketNameForJobs}, id, in); err != nil {
+			if err == boltutil.ErrNotFound {
+				return &ErrJobNotFound{ID: id}
+			} else {
+				return err
+			}
+		}
+
+		if err := boltutil.Delete(tx, []interface{}{BucketNameForJobs}, id); err != nil {
+			return err
+		}
+
+		// remove the job from the reverse index as a child and as a parent.
+		for _, parentID := range in.DependsOn {
+			if err := boltutil.Delete(tx, []interface{}{BucketNameForDependents}, dependentKey(parentID, id)); err != nil {
+				return err
+			}
+		}
+		children, err := listDependents(tx, id)
+		if err != nil {
+			return err
+		}
+		for _, childID := range children {
+			if err := boltutil.Delete(tx, []interface{}{BucketNameForDependents}, dependentKey(id, childID)); err != nil {
+				return err
+			}
+		}
+
+		if err := deleteJobProgress(tx, id); err != nil {
+			return err
+		}
+		if err := boltutil.Delete(tx, []interface{}{BucketNameForDeliveries}, id); err != nil {
+			return err
+		}
+		if in.BatchID != 0 {
+			if err := boltutil.Delete(tx, []interface{}{BucketNameForBatchJobs}, batchJobKey(in.BatchID, id)); err != nil {
+				return err
+			}
+		}
+
+		// remove the unique key if it still points to the job.
+		if in.UniqueKey != "" {
+			u := &U{}
+			if err := boltutil.Get(tx, []interface{}{BucketNameForUniqueKeys}, in.UniqueKey, u); err == nil && u.JobID == id {
+				if err := boltutil.Delete(tx, []interface{}{BucketNameForUniqueKeys}, in.UniqueKey); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+func (s *Store) CountJobs() (int, error) {
+	var ret *bolt.BucketStats
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket, err := boltutil.Bucket(tx, []interface{}{BucketNameForJobs})
+		if err != nil {
+			return err
+		}
+
+		stats := bucket.Stats()
+		ret = &stats
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return ret.KeyN, nil
+}
+
+func (s *Store) GetJob(id uint64) (*structs.Job, error) {
+	job := &structs.Job{}
+	if err := s.db.View(func(tx *bolt.Tx) error {
+		out := &J{}
+		if err := boltutil.Get(tx, []interface{}{BucketNameForJobs}, id, out); err != nil {
+			if err == boltutil.ErrNotFound {
+				return &ErrJobNotFound{ID: id}
+			} else {
+				return err
+			}
+		}
+
+		job = out.toJob()
+
+		children, err := listDependents(tx, id)
+		if err != nil {
+			return err
+		}
+		if len(children) > 0 {
+			job.Children = children
+		}
+
+		if err := loadJobProgress(tx, job); err != nil {
+			return err
+		}
+		return loadJobDeliveries(tx, job)
+	}); err != nil {
+		return nil, err
+	}
+
+	job = s.queueManager.LoadJobStatus(job)
+
+	return job, nil
+}
+
+// ListUnfinishedJobs returns all the jobs that have not finished yet in the order of ID.
+func (s *Store) ListUnfinishedJobs() ([]*structs.Job, error) {
+	jobs := []*structs.Job{}
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c, err := boltutil.Cursor(tx, []interface{}{BucketNameForJobs})
+		if err != nil {
+			if err == boltutil.ErrNotFound {
+				return nil
+			} else {
+				return err
+			}
+		}
+
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			in := &J{}
+			if err := boltutil.Deserialize(v, in); err != nil {
+				return err
+			}
+
+			if in.FinishedAt == nil {
+				jobs = append(jobs, in.toJob())
+			}
+		}
+
+		return nil
+	})
+
+	return jobs, err
+}
+
+// Q is internal representation of a queue entry in the boltdb.
+// The entries are keyed by the enqueue sequence.
+type Q struct {
+	Seq        uint64
+	JobID      uint64
+	EnqueuedAt time.Time
+}
+
+// PutQueueEntry persists a queue entry for the job and returns its sequence.
+func (s *Store) PutQueueEntry(jobID uint64, enqueuedAt time.Time) (uint64, error) {
+	var seq uint64
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := boltutil.CreateBucketIfNotExists(tx, []interface{}{BucketNameForQueue})
+		if err != nil {
+			return err
+		}
+
+		seq, err = bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+
+		return boltutil.Set(tx, []interface{}{BucketNameForQueue}, seq, &Q{
+			Seq:        seq,
+			JobID:      jobID,
+			EnqueuedAt: enqueuedAt,
+		})
+	})
+	return seq, err
+}
+
+// DeleteQueueEntry removes the persisted queue entry.
+func (s *Store) DeleteQueueEntry(seq uint64) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return boltutil.Delete(tx, []interface{}{BucketNameForQueue}, seq)
+	})
+}
+
+// LoadQueueEntries loads the persisted queue entries in the order of the sequence.
+// The entries whose jobs have been deleted or finished are removed.
+func (s *Store) LoadQueueEntries() ([]*QueueEntry, error) {
+	entries := []*QueueEntry{}
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		c, err := boltutil.Cursor(tx, []interface{}{BucketNameForQueue})
+		if err != nil {
+			if err == boltutil.ErrNotFound {
+				return nil
+			} else {
+				return err
+			}
+		}
+
+		stale := []uint64{}
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			q := &Q{}
+			if err := boltutil.Deserialize(v, q); err != nil {
+				return err
+			}
+
+			in := &J{}
+			if err := boltutil.Get(tx, []interface{}{BucketNameForJobs}, q.JobID, in); err != nil {
+				if err == boltutil.ErrNotFound {
+					stale = append(stale, q.Seq)
+					continue
+				} else {
+					return err
+				}
+			}
+
+			if in.FinishedAt != nil {
+				stale = append(stale, q.Seq)
+				continue
+			}
+
+			enqueuedAt := q.EnqueuedAt
+			if enqueuedAt.IsZero() {
+				// The entry that was persisted by the older version does not have the time.
+				enqueuedAt = time.Now()
+			}
+
+			entries = append(entries, &QueueEntry{
+				Seq:        q.Seq,
+				EnqueuedAt: enqueuedAt,
+				Job:        in.toJob(),
+			})
+		}
+
+		for _, seq := range stale {
+			if err := boltutil.Delete(tx, []interface{}{BucketNameForQueue}, seq); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return entries, err
+}
+
+// D is internal representation of an entry of the reverse index from a parent job to its child.
+// The entries are keyed by the parent ID and the child ID, so the children of a parent can be found by the prefix.
+type D struct {
+	ParentID uint64
+	ChildID  uint64
+}
+
+func dependentKey(parentID uint64, childID uint64) []byte {
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint64(key[0:8], parentID)
+	binary.BigEndian.PutUint64(key[8:16], childID)
+	return key
+}
+
+// ListDependents returns the IDs of the jobs that depend on the job in the order of ID.
+func (s *Store) ListDependents(parentID uint64) ([]uint64, error) {
+	var ids []uint64
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		ids, err = listDependents(tx, parentID)
+		return err
+	})
+	return ids, err
+}
+
+func listDependents(tx *bolt.Tx, parentID uint64) ([]uint64, error) {
+	c, err := boltutil.Cursor(tx, []interface{}{BucketNameForDependents})
+	if err != nil {
+		if err == boltutil.ErrNotFound {
+			return nil, nil
+		} else {
+			return nil, err
+		}
+	}
+
+	ids := []uint64{}
+	prefix := dependentKey(parentID, 0)[0:8]
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		ids = append(ids, binary.BigEndian.Uint64(k[8:16]))
+	}
+	return ids, nil
+}
+
+// T is internal representation of a timer entry in the boltdb.
+// The entries are keyed by the time to run and the job ID.
+type T struct {
+	RunAt time.Time
+	JobID uint64
+}
+
+func timerEntryKey(runAt time.Time, jobID uint64) []byte {
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint64(key[0:8], uint64(runAt.UnixNano()))
+	binary.BigEndian.PutUint64(key[8:16], jobID)
+	return key
+}
+
+// PutTimerEntry persists a timer entry for the job.
+func (s *Store) PutTimerEntry(runAt time.Time, jobID uint64) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return boltutil.Set(tx, []interface{}{BucketNameForTimer}, timerEntryKey(runAt, jobID), &T{
+			RunAt: runAt,
+			JobID: jobID,
+		})
+	})
+}
+
+// DeleteTimerEntry removes the persisted timer entry.
+func (s *Store) DeleteTimerEntry(runAt time.Time, jobID uint64) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return boltutil.Delete(tx, []interface{}{BucketNameForTimer}, timerEntryKey(runAt, jobID))
+	})
+}
+
+// LoadTimerEntries loads the persisted timer entries in the order of the time to run.
+// The entries whose jobs have been deleted or finished are removed.
+func (s *Store) LoadTimerEntries() ([]*structs.Job, error) {
+	jobs := []*structs.Job{}
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		c, err := boltutil.Cursor(tx, []interface{}{BucketNameForTimer})
+		if err != nil {
+			if err == boltutil.ErrNotFound {
+				return nil
+			} else {
+				return err
+			}
+		}
+
+		stale := [][]byte{}
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			t := &T{}
+			if err := boltutil.Deserialize(v, t); err != nil {
+				return err
+			}
+
+			in := &J{}
+			if err := boltutil.Get(tx, []interface{}{BucketNameForJobs}, t.JobID, in); err != nil {
+				if err == boltutil.ErrNotFound {
+					stale = append(stale, timerEntryKey(t.RunAt, t.JobID))
+					continue
+				} else {
+					return err
+				}
+			}
+
+			if in.FinishedAt != nil {
+				stale = append(stale, timerEntryKey(t.RunAt, t.JobID))
+				continue
+			}
+
+			job := in.toJob()
+			runAt := t.RunAt
+			job.RunAt = &runAt
+			jobs = append(jobs, job)
+		}
+
+		for _, key := range stale {
+			if err := boltutil.Delete(tx, []interface{}{BucketNameForTimer}, key); err != nil {
+				return err
+			}
+		}
+
 		return nil
 	})
+
+	return jobs, err
+}
+
+// S is internal representation of a schedule in the boltdb.
+type S struct {
+	Name      string
+	Cron      string
+	Timezone  string
+	Overlap   string
+	CatchUp   string
+	Paused    bool
+	Declared  bool
+	Job       *structs.JobTemplate
+	LastRunAt *time.Time
+	LastJobID uint64
+	NextRunAt *time.Time
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func newS(schedule *structs.Schedule) *S {
+	return &S{
+		Name:      schedule.Name,
+		Cron:      schedule.Cron,
+		Timezone:  schedule.Timezone,
+		Overlap:   schedule.Overlap,
+		CatchUp:   schedule.CatchUp,
+		Paused:    schedule.Paused,
+		Declared:  schedule.Declared,
+		Job:       schedule.Job,
+		LastRunAt: schedule.LastRunAt,
+		LastJobID: schedule.LastJobID,
+		NextRunAt: schedule.NextRunAt,
+		CreatedAt: schedule.CreatedAt,
+		UpdatedAt: schedule.UpdatedAt,
+	}
+}
+
+func (in *S) toSchedule() *structs.Schedule {
+	return &structs.Schedule{
+		Name:      in.Name,
+		Cron:      in.Cron,
+		Timezone:  in.Timezone,
+		Overlap:   in.Overlap,
+		CatchUp:   in.CatchUp,
+		Paused:    in.Paused,
+		Declared:  in.Declared,
+		Job:       in.Job,
+		LastRunAt: in.LastRunAt,
+		LastJobID: in.LastJobID,
+		NextRunAt: in.NextRunAt,
+		CreatedAt: in.CreatedAt,
+		UpdatedAt: in.UpdatedAt,
+	}
+}
+
+type ErrScheduleNotFound struct {
+	Name string
+}
+
+func (e *ErrScheduleNotFound) Error() string {
+	return fmt.Sprintf("The schedule '%s' is not found", e.Name)
+}
+
+// PutSchedule creates or replaces the schedule.
+func (s *Store) PutSchedule(schedule *structs.Schedule) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return boltutil.Set(tx, []interface{}{BucketNameForSchedules}, schedule.Name, newS(schedule))
+	})
+}
+
+func (s *Store) GetSchedule(name string) (*structs.Schedule, error) {
+	var schedule *structs.Schedule
+	if err := s.db.View(func(tx *bolt.Tx) error {
+		out := &S{}
+		if err := boltutil.Get(tx, []interface{}{BucketNameForSchedules}, name, out); err != nil {
+			if err == boltutil.ErrNotFound {
+				return &ErrScheduleNotFound{Name: name}
+			} else {
+				return err
+			}
+		}
+
+		schedule = out.toSchedule()
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return schedule, nil
+}
+
+func (s *Store) DeleteSchedule(name string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := boltutil.Get(tx, []interface{}{BucketNameForSchedules}, name, &S{}); err != nil {
+			if err == boltutil.ErrNotFound {
+				return &ErrScheduleNotFound{Name: name}
+			} else {
+				return err
+			}
+		}
+
+		return boltutil.Delete(tx, []interface{}{BucketNameForSchedules}, name)
+	})
+}
+
+// ListSchedules returns all the schedules in the order of the name.
+func (s *Store) ListSchedules() ([]*structs.Schedule, error) {
+	schedules := []*structs.Schedule{}
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c, err := boltutil.Cursor(tx, []interface{}{BucketNameForSchedules})
+		if err != nil {
+			if err == boltutil.ErrNotFound {
+				return nil
+			} else {
+				return err
+			}
+		}
+
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			out := &S{}
+			if err := boltutil.Deserialize(v, out); err != nil {
+				return err
+			}
+			schedules = append(schedules, out.toSchedule())
+		}
+
+		return nil
+	})
+
+	return schedules, err
+}
+
+// B is internal representation of a batch in the boltdb.
+type B struct {
+	ID          uint64
+	OnComplete  string
+	Jobs        []uint64
+	NumFinished int
+	CreatedAt   time.Time
+	FinishedAt  *time.Time
+}
+
+func newB(batch *structs.Batch) *B {
+	return &B{
+		ID:         batch.ID,
+		OnComplete: batch.OnComplete,
+		Jobs:       batch.Jobs,
+		CreatedAt:  batch.CreatedAt,
+		FinishedAt: batch.FinishedAt,
+	}
+}
+
+func (in *B) toBatch() *structs.Batch {
+	return &structs.Batch{
+		ID:         in.ID,
+		OnComplete: in.OnComplete,
+		Jobs:       in.Jobs,
+		NumJobs:    len(in.Jobs),
+		Finished:   in.FinishedAt != nil,
+		CreatedAt:  in.CreatedAt,
+		FinishedAt: in.FinishedAt,
+	}
+}
+
+// BJ is internal representation of a job in a batch.
+// The entries are keyed by the batch ID and the job ID.
+type BJ struct {
+	BatchID  uint64
+	JobID    uint64
+	Finished bool
+}
+
+func batchJobKey(batchID uint64, jobID uint64) []byte {
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint64(key[0:8], batchID)
+	binary.BigEndian.PutUint64(key[8:16], jobID)
+	return key
+}
+
+type ErrBatchNotFound struct {
+	ID uint64
+}
+
+func (e *ErrBatchNotFound) Error() string {
+	return fmt.Sprintf("The batch '%d' is not found", e.ID)
+}
+
+// CreateBatch stores the batch and its jobs in a transaction.
+// If any of the jobs can not be stored, nothing is stored.
+func (s *Store) CreateBatch(batch *structs.Batch, jobs []*structs.Job) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, job := range jobs {
+			if err := createJob(tx, job); err != nil {
+				return err
+			}
+			if err := boltutil.Set(tx, []interface{}{BucketNameForBatchJobs}, batchJobKey(batch.ID, job.ID), &BJ{
+				BatchID: batch.ID,
+				JobID:   job.ID,
+			}); err != nil {
+				return err
+			}
+		}
+
+		return boltutil.Set(tx, []interface{}{BucketNameForBatches}, batch.ID, newB(batch))
+	})
+}
+
+// GetBatch returns the batch with the numbers of its jobs by the status.
+// The jobs that have been deleted are not counted.
+func (s *Store) GetBatch(id uint64) (*structs.Batch, error) {
+	var batch *structs.Batch
+	jobs := []*structs.Job{}
+	if err := s.db.View(func(tx *bolt.Tx) error {
+		out := &B{}
+		if err := boltutil.Get(tx, []interface{}{BucketNameForBatches}, id, out); err != nil {
+			if err == boltutil.ErrNotFound {
+				return &ErrBatchNotFound{ID: id}
+			} else {
+				return err
+			}
+		}
+
+		batch = out.toBatch()
+
+		for _, jobID := range out.Jobs {
+			in := &J{}
+			if err := boltutil.Get(tx, []interface{}{BucketNameForJobs}, jobID, in); err != nil {
+				if err == boltutil.ErrNotFound {
+					continue
+				} else {
+					return err
+				}
+			}
+			jobs = append(jobs, in.toJob())
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	for _, job := range jobs {
+		job = s.queueManager.LoadJobStatus(job)
+		switch job.Status() {
+		case structs.JobStatusWaiting, structs.JobStatusScheduled, structs.JobStatusBlocked, structs.JobStatusUnfinished:
+			batch.NumPending++
+		case structs.JobStatusRunning, structs.JobStatusRunningAsync, structs.JobStatusCanceling:
+			batch.NumRunning++
+		case structs.JobStatusSuccess:
+			batch.NumSuccess++
+		case structs.JobStatusCanceled:
+			batch.NumCanceled++
+		case structs.JobStatusExpired:
+			batch.NumExpired++
+		default:
+			batch.NumFailure++
+		}
+	}
+
+	return batch, nil
+}
+
+// FinishBatchJob marks the job in the batch as finished.
+// It returns true only once when the last job of the batch finished.
+func (s *Store) FinishBatchJob(batchID uint64, jobID uint64) (bool, error) {
+	done := false
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		key := batchJobKey(batchID, jobID)
+		bj := &BJ{}
+		if err := boltutil.Get(tx, []interface{}{BucketNameForBatchJobs}, key, bj); err != nil {
+			if err == boltutil.ErrNotFound {
+				return &ErrBatchNotFound{ID: batchID}
+			} else {
+				return err
+			}
+		}
+		if bj.Finished {
+			return nil
+		}
+
+		in := &B{}
+		if err := boltutil.Get(tx, []interface{}{BucketNameForBatches}, batchID, in); err != nil {
+			if err == boltutil.ErrNotFound {
+				return &ErrBatchNotFound{ID: batchID}
+			} else {
+				return err
+			}
+		}
+
+		bj.Finished = true
+		if err := boltutil.Set(tx, []interface{}{BucketNameForBatchJobs}, key, bj); err != nil {
+			return err
+		}
+
+		in.NumFinished++
+		if in.NumFinished >= len(in.Jobs) && in.FinishedAt == nil {
+			// Truncate millisecond. It is compatible time for katsubushi ID generator timestamp.
+			now := time.Now().UTC().Truncate(time.Millisecond)
+			in.FinishedAt = &now
+			done = true
+		}
+
+		return boltutil.Set(tx, []interface{}{BucketNameForBatches}, batchID, in)
+	})
+	return done, err
 }
 
-const (
-	BucketNameForJobs = "j"
-)
+// DeleteFinishedBatches deletes the batches that finished before the time, and the index to their jobs.
+// It returns the number of the deleted batches.
+func (s *Store) DeleteFinishedBatches(before time.Time) (int, error) {
+	n := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		c, err := boltutil.Cursor(tx, []interface{}{BucketNameForBatches})
+		if err != nil {
+			return err
+		}
 
-// J is internal representation of a job in the boltdb.
-type J struct {
-	ID         uint64
-	Name       string
-	Comment    string
-	URL        string
-	Payload    json.RawMessage
-	Headers    map[string]string
-	Timeout    int64
-	CreatedAt  time.Time
-	StartedAt  *time.Time
-	FinishedAt *time.Time
-	Failure    bool
-	Success    bool
-	Canceled   bool
-	StatusCode *int
-	Err        string
-	Output     string
+		ids := []uint64{}
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			in := &B{}
+			if err := boltutil.Deserialize(v, in); err != nil {
+				return err
+			}
+			if in.FinishedAt != nil && in.FinishedAt.Before(before) {
+				ids = append(ids, in.ID)
+			}
+		}
+
+		for _, id := range ids {
+			if err := deleteBatchJobs(tx, id); err != nil {
+				return err
+			}
+			if err := boltutil.Delete(tx, []interface{}{BucketNameForBatches}, id); err != nil {
+				return err
+			}
+		}
+		n = len(ids)
+
+		return nil
+	})
+	return n, err
 }
 
-type ErrJobNotFound struct {
-	ID uint64
+// DeleteBatch deletes the batch and the index to its jobs. The jobs are not deleted.
+func (s *Store) DeleteBatch(id uint64) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := deleteBatchJobs(tx, id); err != nil {
+			return err
+		}
+		return boltutil.Delete(tx, []interface{}{BucketNameForBatches}, id)
+	})
 }
 
-func (e *ErrJobNotFound) Error() string {
-	return fmt.Sprintf("The job '%d' is not found", e.ID)
+// deleteBatchJobs deletes the index from the batch to its jobs that have not been deleted yet.
+func deleteBatchJobs(tx *bolt.Tx, batchID uint64) error {
+	c, err := boltutil.Cursor(tx, []interface{}{BucketNameForBatchJobs})
+	if err != nil {
+		return err
+	}
+
+	jobIDs := []uint64{}
+	prefix := batchJobKey(batchID, 0)[0:8]
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		jobIDs = append(jobIDs, binary.BigEndian.Uint64(k[8:16]))
+	}
+
+	for _, jobID := range jobIDs {
+		if err := boltutil.Delete(tx, []interface{}{BucketNameForBatchJobs}, batchJobKey(batchID, jobID)); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-type ErrJobAlreadyExisted struct {
-	ID   uint64
-	Name string
+// U is internal representation of a unique key of a job in the boltdb.
+type U struct {
+	Key   string
+	JobID uint64
 }
 
-func (e *ErrJobAlreadyExisted) Error() string {
-	return fmt.Sprintf("'%d' (%s) is already exsited", e.ID, e.Name)
+// isUniqueKeyActive reports whether the job still holds its unique key at the time.
+// The key is held while the job is not finished, and for uniqueFor seconds after it finished.
+func isUniqueKeyActive(in *J, now time.Time) bool {
+	if in.FinishedAt == nil {
+		return true
+	}
+	return now.Before(in.FinishedAt.Add(time.Duration(in.UniqueFor) * time.Second))
 }
 
-func (s *Store) CreateJob(job *structs.Job) error {
-	return s.db.Update(func(tx *bolt.Tx) error {
-		if err := boltutil.Get(tx, []interface{}{BucketNameForJobs}, job.ID, &J{}); err == nil {
-			return &ErrJobAlreadyExisted{ID: job.ID, Name: job.Name}
-		}
-
-		in := &J{
-			ID:         job.ID,
-			Name:       job.Name,
-			Comment:    job.Comment,
-			URL:        job.URL,
-			Payload:    job.Payload,
-			Headers:    job.Headers,
-			Timeout:    job.Timeout,
-			StartedAt:  job.StartedAt,
-			CreatedAt:  job.CreatedAt,
-			FinishedAt: job.FinishedAt,
-			Failure:    job.Failure,
-			Success:    job.Success,
-			Canceled:   job.Canceled,
-			StatusCode: job.StatusCode,
-			Err:        job.Err,
-			Output:     job.Output,
+// CreateUniqueJob stores the job with its unique key.
+// If another job holds the same unique key, the job is not stored and the existing job is returned instead.
+func (s *Store) CreateUniqueJob(job *structs.Job) (*structs.Job, error) {
+	var existing *structs.Job
+	if err := s.db.Update(func(tx *bolt.Tx) error {
+		u := &U{}
+		if err := boltutil.Get(tx, []interface{}{BucketNameForUniqueKeys}, job.UniqueKey, u); err == nil {
+			in := &J{}
+			if err := boltutil.Get(tx, []interface{}{BucketNameForJobs}, u.JobID, in); err == nil {
+				if isUniqueKeyActive(in, time.Now()) {
+					existing = in.toJob()
+					return nil
+				}
+			} else if err != boltutil.ErrNotFound {
+				return err
+			}
+		} else if err != boltutil.ErrNotFound {
+			return err
 		}
 
-		if err := boltutil.Set(tx, []interface{}{BucketNameForJobs}, job.ID, in); err != nil {
+		if err := createJob(tx, job); err != nil {
 			return err
 		}
 
-		return nil
-	})
+		return boltutil.Set(tx, []interface{}{BucketNameForUniqueKeys}, job.UniqueKey, &U{
+			Key:   job.UniqueKey,
+			JobID: job.ID,
+		})
+	}); err != nil {
+		return nil, err
+	}
+
+	if existing != nil {
+		existing = s.queueManager.LoadJobStatus(existing)
+	}
+
+	return existing, nil
 }
 
-func (s *Store) UpdateJob(job *structs.Job) error {
-	return s.db.Update(func(tx *bolt.Tx) error {
-		if err := boltutil.Get(tx, []interface{}{BucketNameForJobs}, job.ID, &J{}); err != nil {
-			if err == boltutil.ErrNotFound {
-				return &ErrJobNotFound{ID: job.ID}
-			} else {
+// DeleteExpiredUniqueKeys deletes the unique keys that are no longer held by the jobs.
+// It returns the number of the deleted keys.
+func (s *Store) DeleteExpiredUniqueKeys(now time.Time) (int, error) {
+	n := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		c, err := boltutil.Cursor(tx, []interface{}{BucketNameForUniqueKeys})
+		if err != nil {
+			return err
+		}
+
+		keys := []string{}
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			u := &U{}
+			if err := boltutil.Deserialize(v, u); err != nil {
 				return err
 			}
+
+			in := &J{}
+			if err := boltutil.Get(tx, []interface{}{BucketNameForJobs}, u.JobID, in); err != nil {
+				if err != boltutil.ErrNotFound {
+					return err
+				}
+			} else if isUniqueKeyActive(in, now) {
+				continue
+			}
+			keys = append(keys, u.Key)
 		}
 
-		in := &J{
-			ID:         job.ID,
-			Name:       job.Name,
-			Comment:    job.Comment,
-			URL:        job.URL,
-			Payload:    job.Payload,
-			Headers:    job.Headers,
-			Timeout:    job.Timeout,
-			CreatedAt:  job.CreatedAt,
-			StartedAt:  job.StartedAt,
-			FinishedAt: job.FinishedAt,
-			Failure:    job.Failure,
-			Success:    job.Success,
-			Canceled:   job.Canceled,
-			StatusCode: job.StatusCode,
-			Err:        job.Err,
-			Output:     job.Output,
+		for _, key := range keys {
+			if err := boltutil.Delete(tx, []interface{}{BucketNameForUniqueKeys}, key); err != nil {
+				return err
+			}
 		}
+		n = len(keys)
 
-		if err := boltutil.Set(tx, []interface{}{BucketNameForJobs}, job.ID, in); err != nil {
+		return nil
+	})
+	return n, err
+}
+
+// P is internal representation of a pause in the boltdb.
+// The empty name is the pause for all the jobs.
+type P struct {
+	Name     string
+	PausedAt time.Time
+}
+
+// pauseKey returns the key of the pause. The bucket does not accept the empty key.
+func pauseKey(name string) string {
+	return "name:" + name
+}
+
+// PutPause persists the pause.
+func (s *Store) PutPause(name string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return boltutil.Set(tx, []interface{}{BucketNameForPauses}, pauseKey(name), &P{
+			Name:     name,
+			PausedAt: time.Now().UTC().Truncate(time.Millisecond),
+		})
+	})
+}
+
+// DeletePause deletes the pause.
+func (s *Store) DeletePause(name string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return boltutil.Delete(tx, []interface{}{BucketNameForPauses}, pauseKey(name))
+	})
+}
+
+// ListPauses returns the names of the pauses.
+func (s *Store) ListPauses() ([]string, error) {
+	names := []string{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c, err := boltutil.Cursor(tx, []interface{}{BucketNameForPauses})
+		if err != nil {
 			return err
 		}
 
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			p := &P{}
+			if err := boltutil.Deserialize(v, p); err != nil {
+				return err
+			}
+			names = append(names, p.Name)
+		}
+
 		return nil
 	})
+
+	return names, err
 }
 
-func (s *Store) DeleteJob(id uint64) error {
-	return s.db.Update(func(tx *bolt.Tx) error {
-		if err := boltutil.Get(tx, []interface{}{BucketNameForJobs}, id, &J{}); err != nil {
-			if err == boltutil.ErrNotFound {
-				return &ErrJobNotFound{ID: id}
-			} else {
+// PG is internal representation of the progress of a job in the boltdb.
+type PG struct {
+	JobID     uint64
+	Attempt   int
+	Percent   *int
+	Message   string
+	UpdatedAt time.Time
+}
+
+// L is internal representation of a log line of a job in the boltdb.
+type L struct {
+	Attempt int
+	Time    time.Time
+	Line    string
+}
+
+// loadJobProgress sets the progress of the current attempt to the job.
+func loadJobProgress(tx *bolt.Tx, job *structs.Job) error {
+	pg := &PG{}
+	if err := boltutil.Get(tx, []interface{}{BucketNameForProgress}, job.ID, pg); err != nil {
+		if err == boltutil.ErrNotFound {
+			return nil
+		}
+		return err
+	}
+
+	// The progress of the previous attempt is stale.
+	if pg.Attempt != job.Attempt {
+		return nil
+	}
+
+	job.Progress = &structs.JobProgress{
+		Attempt:   pg.Attempt,
+		Percent:   pg.Percent,
+		Message:   pg.Message,
+		UpdatedAt: pg.UpdatedAt,
+	}
+	return nil
+}
+
+// ReportJobProgress updates the progress of the current attempt of the job and appends the log lines.
+// The percent and the message that are not reported keep the previous values.
+func (s *Store) ReportJobProgress(job *structs.Job, req *structs.ProgressJobRequest, now time.Time) (*structs.JobProgress, error) {
+	var ret *structs.JobProgress
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		if req.Percent != nil || req.Message != nil {
+			pg := &PG{}
+			if err := boltutil.Get(tx, []interface{}{BucketNameForProgress}, job.ID, pg); err != nil && err != boltutil.ErrNotFound {
+				return err
+			}
+			if pg.Attempt != job.Attempt {
+				pg = &PG{JobID: job.ID, Attempt: job.Attempt}
+			}
+			if req.Percent != nil {
+				pg.Percent = req.Percent
+			}
+			if req.Message != nil {
+				pg.Message = *req.Message
+			}
+			pg.UpdatedAt = now
+
+			if err := boltutil.Set(tx, []interface{}{BucketNameForProgress}, job.ID, pg); err != nil {
 				return err
 			}
 		}
 
-		if err := boltutil.Delete(tx, []interface{}{BucketNameForJobs}, id); err != nil {
-			return err
+		if len(req.Log) > 0 {
+			bucket, err := boltutil.CreateBucketIfNotExists(tx, []interface{}{BucketNameForLogs, job.ID})
+			if err != nil {
+				return err
+			}
+			for _, line := range req.Log {
+				seq, err := bucket.NextSequence()
+				if err != nil {
+					return err
+				}
+				if err := boltutil.Set(tx, []interface{}{BucketNameForLogs, job.ID}, seq, &L{
+					Attempt: job.Attempt,
+					Time:    now,
+					Line:    line,
+				}); err != nil {
+					return err
+				}
+			}
 		}
 
+		out := &structs.Job{ID: job.ID, Attempt: job.Attempt}
+		if err := loadJobProgress(tx, out); err != nil {
+			return err
+		}
+		ret = out.Progress
 		return nil
 	})
+	return ret, err
 }
 
-func (s *Store) CountJobs() (int, error) {
-	var ret *bolt.BucketStats
+// GetJobLog returns the log lines of the job whose seq is greater than after.
+// If the limit is greater than 0, it returns the lines up to the limit.
+func (s *Store) GetJobLog(id uint64, after uint64, limit int) (*structs.JobLog, error) {
+	ret := &structs.JobLog{
+		ID:    id,
+		Lines: []*structs.JobLogLine{},
+		Next:  after,
+	}
+
 	err := s.db.View(func(tx *bolt.Tx) error {
-		bucket, err := boltutil.Bucket(tx, []interface{}{BucketNameForJobs})
+		c, err := boltutil.Cursor(tx, []interface{}{BucketNameForLogs, id})
+		if err != nil {
+			if err == boltutil.ErrNotFound {
+				return nil
+			}
+			return err
+		}
+
+		afterB, err := boltutil.ToKeyBytes(after + 1)
 		if err != nil {
 			return err
 		}
 
-		stats := bucket.Stats()
-		ret = &stats
+		for k, v := c.Seek(afterB); k != nil; k, v = c.Next() {
+			l := &L{}
+			if err := boltutil.Deserialize(v, l); err != nil {
+				return err
+			}
 
+			seq := binary.BigEndian.Uint64(k)
+			ret.Lines = append(ret.Lines, &structs.JobLogLine{
+				Seq:     seq,
+				Attempt: l.Attempt,
+				Time:    l.Time,
+				Line:    l.Line,
+			})
+			ret.Next = seq
+
+			if limit > 0 && len(ret.Lines) >= limit {
+				break
+			}
+		}
 		return nil
 	})
+
+	return ret, err
+}
+
+// DeleteJobProgress deletes the progress and the log of the job.
+func (s *Store) DeleteJobProgress(id uint64) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return deleteJobProgress(tx, id)
+	})
+}
+
+func deleteJobProgress(tx *bolt.Tx, id uint64) error {
+	if err := boltutil.Delete(tx, []interface{}{BucketNameForProgress}, id); err != nil {
+		return err
+	}
+	bucket, err := boltutil.Bucket(tx, []interface{}{BucketNameForLogs, id})
 	if err != nil {
-		return 0, err
+		return err
 	}
-	return ret.KeyN, nil
+	if bucket == nil {
+		return nil
+	}
+	return boltutil.DeleteBucket(tx, []interface{}{BucketNameForLogs, id})
 }
 
-func (s *Store) GetJob(id uint64) (*structs.Job, error) {
-	job := &structs.Job{}
-	if err := s.db.View(func(tx *bolt.Tx) error {
-		out := &J{}
-		if err := boltutil.Get(tx, []interface{}{BucketNameForJobs}, id, out); err != nil {
+// W is internal representation of the webhook deliveries of a finished job in the boltdb.
+// FinishedAt identifies the finish of the job that the deliveries are for, because a restarted job finishes again.
+type W struct {
+	JobID      uint64
+	FinishedAt time.Time
+	Deliveries []*structs.Delivery
+}
+
+// loadJobDeliveries sets the webhook deliveries of the last finish to the job.
+func loadJobDeliveries(tx *bolt.Tx, job *structs.Job) error {
+	w := &W{}
+	if err := boltutil.Get(tx, []interface{}{BucketNameForDeliveries}, job.ID, w); err != nil {
+		if err == boltutil.ErrNotFound {
+			return nil
+		}
+		return err
+	}
+
+	if job.FinishedAt == nil || !job.FinishedAt.Equal(w.FinishedAt) {
+		return nil
+	}
+
+	job.Deliveries = w.Deliveries
+	return nil
+}
+
+// PutDeliveries stores the new webhook deliveries of the finished job.
+func (s *Store) PutDeliveries(job *structs.Job, deliveries []*structs.Delivery) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return boltutil.Set(tx, []interface{}{BucketNameForDeliveries}, job.ID, &W{
+			JobID:      job.ID,
+			FinishedAt: *job.FinishedAt,
+			Deliveries: deliveries,
+		})
+	})
+}
+
+// UpdateDelivery updates the i-th webhook delivery of the finished job.
+// It returns false if the deliveries are for another finish of the job, or they were deleted.
+func (s *Store) UpdateDelivery(job *structs.Job, i int, delivery *structs.Delivery) (bool, error) {
+	updated := false
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		w := &W{}
+		if err := boltutil.Get(tx, []interface{}{BucketNameForDeliveries}, job.ID, w); err != nil {
 			if err == boltutil.ErrNotFound {
-				return &ErrJobNotFound{ID: id}
-			} else {
-				return err
+				return nil
 			}
+			return err
 		}
 
-		job.ID = out.ID
-		job.Name = out.Name
-		job.Comment = out.Comment
-		job.URL = out.URL
-		job.Payload = out.Payload
-		job.Headers = out.Headers
-		job.Timeout = out.Timeout
-		job.CreatedAt = out.CreatedAt
-		job.StartedAt = out.StartedAt
-		job.Failure = out.Failure
-		job.FinishedAt = out.FinishedAt
-		job.Success = out.Success
-		job.Canceled = out.Canceled
-		job.StatusCode = out.StatusCode
-		job.Err = out.Err
-		job.Output = out.Output
+		if !job.FinishedAt.Equal(w.FinishedAt) || i >= len(w.Deliveries) {
+			return nil
+		}
 
-		return nil
-	}); err != nil {
-		return nil, err
-	}
+		w.Deliveries[i] = delivery
+		updated = true
+		return boltutil.Set(tx, []interface{}{BucketNameForDeliveries}, job.ID, w)
+	})
+	return updated, err
+}
 
-	job = s.queueManager.LoadJobStatus(job)
+// DeleteDeliveries deletes the webhook deliveries of the job.
+func (s *Store) DeleteDeliveries(id uint64) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return boltutil.Delete(tx, []interface{}{BucketNameForDeliveries}, id)
+	})
+}
 
-	return job, nil
+// ListPendingDeliveries returns the IDs of the jobs that have the pending webhook deliveries.
+func (s *Store) ListPendingDeliveries() ([]uint64, error) {
+	ids := []uint64{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c, err := boltutil.Cursor(tx, []interface{}{BucketNameForDeliveries})
+		if err != nil {
+			if err == boltutil.ErrNotFound {
+				return nil
+			}
+			return err
+		}
+
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			w := &W{}
+			if err := boltutil.Deserialize(v, w); err != nil {
+				return err
+			}
+
+			for _, d := range w.Deliveries {
+				if d.Status == structs.DeliveryStatusPending {
+					ids = append(ids, w.JobID)
+					break
+				}
+			}
+		}
+		return nil
+	})
+	return ids, err
 }
 
 type ListJobsQuery struct {
@@ -338,7 +1560,7 @@ func (s *Store) ListJobs(query *ListJobsQuery) (*structs.JobList, error) {
 				}
 
 				for ; k != nil; k, v = c.Prev() {
-					if err := s.appendJob(v, query, ret); err != nil {
+					if err := s.appendJob(tx, v, query, ret); err != nil {
 						return err
 					}
 
@@ -348,7 +1570,7 @@ func (s *Store) ListJobs(query *ListJobsQuery) (*structs.JobList, error) {
 				}
 			} else {
 				for k, v := c.Last(); k != nil; k, v = c.Prev() {
-					if err := s.appendJob(v, query, ret); err != nil {
+					if err := s.appendJob(tx, v, query, ret); err != nil {
 						return err
 					}
 
@@ -374,7 +1596,7 @@ func (s *Store) ListJobs(query *ListJobsQuery) (*structs.JobList, error) {
 				}
 
 				for k, v := c.Seek(beginB); k != nil; k, v = c.Next() {
-					if err := s.appendJob(v, query, ret); err != nil {
+					if err := s.appendJob(tx, v, query, ret); err != nil {
 						return err
 					}
 
@@ -384,7 +1606,7 @@ func (s *Store) ListJobs(query *ListJobsQuery) (*structs.JobList, error) {
 				}
 			} else {
 				for k, v := c.First(); k != nil; k, v = c.Next() {
-					if err := s.appendJob(v, query, ret); err != nil {
+					if err := s.appendJob(tx, v, query, ret); err != nil {
 						return err
 					}
 
@@ -411,29 +1633,18 @@ func (s *Store) ListJobs(query *ListJobsQuery) (*structs.JobList, error) {
 	return ret, err
 }
 
-func (s *Store) appendJob(v []byte, query *ListJobsQuery, ret *structs.JobList) error {
+func (s *Store) appendJob(tx *bolt.Tx, v []byte, query *ListJobsQuery, ret *structs.JobList) error {
 	in := &J{}
 	if err := boltutil.Deserialize(v, in); err != nil {
 		return err
 	}
 
-	job := &structs.Job{
-		ID:         in.ID,
-		Name:       in.Name,
-		Comment:    in.Comment,
-		URL:        in.URL,
-		Payload:    in.Payload,
-		Headers:    in.Headers,
-		Timeout:    in.Timeout,
-		CreatedAt:  in.CreatedAt,
-		StartedAt:  in.StartedAt,
-		FinishedAt: in.FinishedAt,
-		Failure:    in.Failure,
-		Success:    in.Success,
-		Canceled:   in.Canceled,
-		StatusCode: in.StatusCode,
-		Err:        in.Err,
-		Output:     in.Output,
+	job := in.toJob()
+	if err := loadJobProgress(tx, job); err != nil {
+		return err
+	}
+	if err := loadJobDeliveries(tx, job); err != nil {
+		return err
 	}
 
 	job = s.queueManager.LoadJobStatus(job)
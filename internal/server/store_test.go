@@ -2,9 +2,11 @@ package server
 
 import (
 	"testing"
+	"time"
 
 	"github.com/kayac/go-katsubushi"
 	"github.com/stretchr/testify/assert"
+	bolt "go.etcd.io/bbolt"
 
 	"github.com/kohkimakimoto/hq/internal/structs"
 )
@@ -117,3 +119,269 @@ func TestStore_DeleteJob(t *testing.T) {
 	_, err = store.GetJob(109192606348480512)
 	assert.IsType(t, &ErrJobNotFound{}, err)
 }
+
+func TestStore_ListDependents(t *testing.T) {
+	store := testStore(t, NewQueueManager(10))
+
+	assert.NoError(t, store.CreateJob(&structs.Job{ID: 1}))
+	assert.NoError(t, store.CreateJob(&structs.Job{ID: 2}))
+	assert.NoError(t, store.CreateJob(&structs.Job{ID: 3, DependsOn: structs.JobIDs{1, 2}}))
+	assert.NoError(t, store.CreateJob(&structs.Job{ID: 4, DependsOn: structs.JobIDs{1}}))
+
+	ids, err := store.ListDependents(1)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{3, 4}, ids)
+
+	ids, err = store.ListDependents(2)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{3}, ids)
+
+	job, err := store.GetJob(1)
+	assert.NoError(t, err)
+	assert.Equal(t, structs.JobIDs{3, 4}, job.Children)
+
+	// the deleted job is removed from the index.
+	assert.NoError(t, store.DeleteJob(3))
+	ids, err = store.ListDependents(1)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{4}, ids)
+	ids, err = store.ListDependents(2)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{}, ids)
+}
+
+func TestStore_Batch(t *testing.T) {
+	store := testStore(t, NewQueueManager(10))
+
+	batch := &structs.Batch{ID: 10, Jobs: structs.JobIDs{1, 2}}
+	jobs := []*structs.Job{{ID: 1, BatchID: 10}, {ID: 2, BatchID: 10}}
+	assert.NoError(t, store.CreateBatch(batch, jobs))
+
+	b, err := store.GetBatch(10)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, b.NumJobs)
+	assert.Equal(t, 2, b.NumPending)
+	assert.False(t, b.Finished)
+
+	job, err := store.GetJob(1)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(10), job.BatchID)
+
+	done, err := store.FinishBatchJob(10, 1)
+	assert.NoError(t, err)
+	assert.False(t, done)
+
+	// finishing the same job again is ignored.
+	done, err = store.FinishBatchJob(10, 1)
+	assert.NoError(t, err)
+	assert.False(t, done)
+
+	done, err = store.FinishBatchJob(10, 2)
+	assert.NoError(t, err)
+	assert.True(t, done)
+
+	b, err = store.GetBatch(10)
+	assert.NoError(t, err)
+	assert.True(t, b.Finished)
+	assert.NotNil(t, b.FinishedAt)
+
+	_, err = store.GetBatch(11)
+	assert.IsType(t, &ErrBatchNotFound{}, err)
+
+	// the batch is not stored if any of the jobs can not be stored.
+	err = store.CreateBatch(&structs.Batch{ID: 20, Jobs: structs.JobIDs{3, 1}}, []*structs.Job{{ID: 3, BatchID: 20}, {ID: 1, BatchID: 20}})
+	assert.IsType(t, &ErrJobAlreadyExisted{}, err)
+	_, err = store.GetBatch(20)
+	assert.IsType(t, &ErrBatchNotFound{}, err)
+	_, err = store.GetJob(3)
+	assert.IsType(t, &ErrJobNotFound{}, err)
+}
+
+func TestStore_DeleteFinishedBatches(t *testing.T) {
+	store := testStore(t, NewQueueManager(10))
+
+	countBatchJobs := func() int {
+		n := 0
+		assert.NoError(t, store.db.View(func(tx *bolt.Tx) error {
+			n = tx.Bucket([]byte(BucketNameForBatchJobs)).Stats().KeyN
+			return nil
+		}))
+		return n
+	}
+
+	assert.NoError(t, store.CreateBatch(&structs.Batch{ID: 10, Jobs: structs.JobIDs{1, 2}}, []*structs.Job{{ID: 1, BatchID: 10}, {ID: 2, BatchID: 10}}))
+	assert.NoError(t, store.CreateBatch(&structs.Batch{ID: 20, Jobs: structs.JobIDs{3}}, []*structs.Job{{ID: 3, BatchID: 20}}))
+	assert.Equal(t, 3, countBatchJobs())
+
+	// deleting the job removes it from the batch.
+	assert.NoError(t, store.DeleteJob(1))
+	assert.Equal(t, 2, countBatchJobs())
+	_, err := store.FinishBatchJob(10, 1)
+	assert.IsType(t, &ErrBatchNotFound{}, err)
+
+	done, err := store.FinishBatchJob(10, 2)
+	assert.NoError(t, err)
+	assert.False(t, done)
+
+	done, err = store.FinishBatchJob(20, 3)
+	assert.NoError(t, err)
+	assert.True(t, done)
+
+	// the batch that finished recently is kept.
+	n, err := store.DeleteFinishedBatches(time.Now().Add(-1 * time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	// the unfinished batch is kept.
+	n, err = store.DeleteFinishedBatches(time.Now().Add(1 * time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	_, err = store.GetBatch(20)
+	assert.IsType(t, &ErrBatchNotFound{}, err)
+	_, err = store.GetBatch(10)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, countBatchJobs())
+}
+
+func TestStore_CreateUniqueJob(t *testing.T) {
+	store := testStore(t, NewQueueManager(10))
+
+	existing, err := store.CreateUniqueJob(&structs.Job{ID: 1, UniqueKey: "key", UniqueFor: 60})
+	assert.NoError(t, err)
+	assert.Nil(t, existing)
+
+	// the key is held while the job is not finished.
+	existing, err = store.CreateUniqueJob(&structs.Job{ID: 2, UniqueKey: "key"})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), existing.ID)
+	_, err = store.GetJob(2)
+	assert.IsType(t, &ErrJobNotFound{}, err)
+
+	// the key is held for uniqueFor seconds after the job finished.
+	job, err := store.GetJob(1)
+	assert.NoError(t, err)
+	finishedAt := time.Now().UTC()
+	job.FinishedAt = &finishedAt
+	assert.NoError(t, store.UpdateJob(job))
+
+	existing, err = store.CreateUniqueJob(&structs.Job{ID: 3, UniqueKey: "key"})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), existing.ID)
+
+	n, err := store.DeleteExpiredUniqueKeys(time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	n, err = store.DeleteExpiredUniqueKeys(time.Now().Add(61 * time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	existing, err = store.CreateUniqueJob(&structs.Job{ID: 4, UniqueKey: "key"})
+	assert.NoError(t, err)
+	assert.Nil(t, existing)
+
+	// the key is released when the job is deleted.
+	assert.NoError(t, store.DeleteJob(4))
+	existing, err = store.CreateUniqueJob(&structs.Job{ID: 5, UniqueKey: "key"})
+	assert.NoError(t, err)
+	assert.Nil(t, existing)
+}
+
+func TestStore_ReportJobProgress(t *testing.T) {
+	store := testStore(t, NewQueueManager(10))
+
+	job := &structs.Job{ID: 1, URL: "http://example.com", Attempt: 1}
+	err := store.CreateJob(job)
+	assert.NoError(t, err)
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	percent := 30
+	message := "downloading"
+	progress, err := store.ReportJobProgress(job, &structs.ProgressJobRequest{
+		Percent: &percent,
+		Message: &message,
+		Log:     []string{"line1", "line2"},
+	}, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 30, *progress.Percent)
+	assert.Equal(t, "downloading", progress.Message)
+
+	// the message is kept if it is not reported.
+	percent = 60
+	progress, err = store.ReportJobProgress(job, &structs.ProgressJobRequest{
+		Percent: &percent,
+		Log:     []string{"line3"},
+	}, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 60, *progress.Percent)
+	assert.Equal(t, "downloading", progress.Message)
+
+	ret, err := store.GetJob(1)
+	assert.NoError(t, err)
+	assert.Equal(t, 60, *ret.Progress.Percent)
+
+	jobLog, err := store.GetJobLog(1, 0, 0)
+	assert.NoError(t, err)
+	assert.Len(t, jobLog.Lines, 3)
+	assert.Equal(t, "line1", jobLog.Lines[0].Line)
+	assert.Equal(t, uint64(3), jobLog.Next)
+
+	jobLog, err = store.GetJobLog(1, 1, 1)
+	assert.NoError(t, err)
+	assert.Len(t, jobLog.Lines, 1)
+	assert.Equal(t, "line2", jobLog.Lines[0].Line)
+	assert.Equal(t, uint64(2), jobLog.Next)
+
+	// the progress of the previous attempt is not loaded.
+	job.Attempt = 2
+	err = store.UpdateJob(job)
+	assert.NoError(t, err)
+
+	ret, err = store.GetJob(1)
+	assert.NoError(t, err)
+	assert.Nil(t, ret.Progress)
+
+	err = store.DeleteJob(1)
+	assert.NoError(t, err)
+
+	jobLog, err = store.GetJobLog(1, 0, 0)
+	assert.NoError(t, err)
+	assert.Len(t, jobLog.Lines, 0)
+}
+
+func TestStore_Schedule(t *testing.T) {
+	store := testStore(t, NewQueueManager(10))
+
+	for _, name := range []string{"b", "a"} {
+		err := store.PutSchedule(&structs.Schedule{
+			Name: name,
+			Cron: "* * * * *",
+			Job: &structs.JobTemplate{
+				URL:     "http://example.com",
+				Payload: []byte(`{"foo": "bar"}`),
+			},
+		})
+		assert.NoError(t, err)
+	}
+
+	schedule, err := store.GetSchedule("a")
+	assert.NoError(t, err)
+	assert.Equal(t, "* * * * *", schedule.Cron)
+	assert.Equal(t, "http://example.com", schedule.Job.URL)
+	assert.Equal(t, `{"foo": "bar"}`, string(schedule.Job.Payload))
+
+	schedules, err := store.ListSchedules()
+	assert.NoError(t, err)
+	assert.Len(t, schedules, 2)
+	assert.Equal(t, "a", schedules[0].Name)
+
+	err = store.DeleteSchedule("a")
+	assert.NoError(t, err)
+
+	_, err = store.GetSchedule("a")
+	assert.IsType(t, &ErrScheduleNotFound{}, err)
+
+	err = store.DeleteSchedule("a")
+	assert.IsType(t, &ErrScheduleNotFound{}, err)
+}
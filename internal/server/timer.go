@@ -0,0 +1,217 @@
+package server
+
+import (
+	"container/heap"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/kohkimakimoto/hq/internal/structs"
+)
+
+// Timer holds the scheduled jobs and moves them into the QueueManager when they are due.
+// The scheduled jobs are persisted in the boltdb, so they survive restarts.
+type Timer struct {
+	logger       echo.Logger
+	queueManager *QueueManager
+	store        *Store
+	mutex        sync.Mutex
+	entries      timerEntries
+	wakeCh       chan struct{}
+	stopCh       chan bool
+	wg           *sync.WaitGroup
+}
+
+func NewTimer(logger echo.Logger, queueManager *QueueManager, store *Store) *Timer {
+	return &Timer{
+		logger:       logger,
+		queueManager: queueManager,
+		store:        store,
+		entries:      timerEntries{},
+		wakeCh:       make(chan struct{}, 1),
+		stopCh:       make(chan bool),
+		wg:           &sync.WaitGroup{},
+	}
+}
+
+// Restore loads the timer entries that were persisted by the previous process.
+func (t *Timer) Restore() error {
+	jobs, err := t.store.LoadTimerEntries()
+	if err != nil {
+		return err
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for _, job := range jobs {
+		heap.Push(&t.entries, &timerEntry{
+			RunAt: *job.RunAt,
+			Job:   job,
+		})
+		t.queueManager.RegisterScheduledJob(job)
+	}
+	t.wake()
+
+	return nil
+}
+
+// Schedule adds the job to the timer. The job is enqueued at the time of the job's RunAt.
+func (t *Timer) Schedule(job *structs.Job) error {
+	if job.RunAt == nil {
+		return fmt.Errorf("the job %d does not have the time to run", job.ID)
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if err := t.store.PutTimerEntry(*job.RunAt, job.ID); err != nil {
+		return err
+	}
+
+	heap.Push(&t.entries, &timerEntry{
+		RunAt: *job.RunAt,
+		Job:   job,
+	})
+	t.queueManager.RegisterScheduledJob(job)
+	t.wake()
+
+	return nil
+}
+
+// Remove removes the job from the timer. It returns false if the job is not scheduled.
+func (t *Timer) Remove(id uint64) (bool, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for i, e := range t.entries {
+		if e.Job.ID != id {
+			continue
+		}
+
+		if err := t.store.DeleteTimerEntry(e.RunAt, e.Job.ID); err != nil {
+			return false, err
+		}
+		heap.Remove(&t.entries, i)
+		t.queueManager.RemoveScheduledJob(e.Job)
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func (t *Timer) Start() {
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		for {
+			next := t.fire()
+
+			var tm *time.Timer
+			var timerC <-chan time.Time
+			if next != nil {
+				tm = time.NewTimer(time.Until(*next))
+				timerC = tm.C
+			}
+
+			select {
+			case <-timerC:
+			case <-t.wakeCh:
+			case <-t.stopCh:
+				if tm != nil {
+					tm.Stop()
+				}
+				return
+			}
+
+			if tm != nil {
+				tm.Stop()
+			}
+		}
+	}()
+}
+
+func (t *Timer) Stop() {
+	close(t.stopCh)
+	t.wg.Wait()
+}
+
+// fire enqueues all the due jobs and returns the time to run of the next job.
+func (t *Timer) fire() *time.Time {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := time.Now()
+	for len(t.entries) > 0 {
+		e := t.entries[0]
+		if e.RunAt.After(now) {
+			next := e.RunAt
+			return &next
+		}
+
+		heap.Pop(&t.entries)
+		// The timer entry is removed before enqueuing. If the process dies between them,
+		// the job is recovered as a waiting job on the next startup.
+		if err := t.store.DeleteTimerEntry(e.RunAt, e.Job.ID); err != nil {
+			t.logger.Error(err)
+		}
+		if err := t.queueManager.Requeue(e.Job); err != nil {
+			t.logger.Error(err)
+			continue
+		}
+		t.logger.Debugf("job: %d is due. enqueued it", e.Job.ID)
+	}
+
+	return nil
+}
+
+// wake wakes up the timer loop to recalculate the next time.
+// It must be called with the lock held.
+func (t *Timer) wake() {
+	select {
+	case t.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+// NumJobs returns the number of the scheduled jobs.
+func (t *Timer) NumJobs() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return len(t.entries)
+}
+
+type timerEntry struct {
+	RunAt time.Time
+	Job   *structs.Job
+}
+
+// timerEntries implements heap.Interface ordered by the time to run.
+type timerEntries []*timerEntry
+
+func (h timerEntries) Len() int { return len(h) }
+
+func (h timerEntries) Less(i, j int) bool {
+	if h[i].RunAt.Equal(h[j].RunAt) {
+		return h[i].Job.ID < h[j].Job.ID
+	}
+	return h[i].RunAt.Before(h[j].RunAt)
+}
+
+func (h timerEntries) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *timerEntries) Push(x interface{}) {
+	*h = append(*h, x.(*timerEntry))
+}
+
+func (h *timerEntries) Pop() interface{} {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return e
+}
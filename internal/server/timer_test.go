@@ -0,0 +1,103 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kohkimakimoto/hq/internal/structs"
+)
+
+func TestTimer_Schedule(t *testing.T) {
+	m := NewQueueManager(10)
+	store := testStore(t, m)
+	tm := NewTimer(testLogger(t), m, store)
+
+	runAt := time.Now().UTC().Add(100 * time.Millisecond)
+	job := &structs.Job{
+		ID:    1,
+		RunAt: &runAt,
+	}
+	err := store.CreateJob(job)
+	assert.NoError(t, err)
+
+	err = tm.Schedule(job)
+	assert.NoError(t, err)
+
+	// check timer status
+	assert.Equal(t, 1, tm.NumJobs())
+	assert.Equal(t, 1, m.NumJobsScheduled())
+	assert.Equal(t, 0, m.NumJobsInQueue())
+
+	tm.Start()
+	defer tm.Stop()
+
+	// the job is enqueued after the time to run
+	ret := m.Dequeue("")
+	assert.Equal(t, uint64(1), ret.ID)
+	assert.False(t, time.Now().Before(runAt))
+	assert.Equal(t, 0, tm.NumJobs())
+	assert.Equal(t, 0, m.NumJobsScheduled())
+}
+
+func TestTimer_ScheduleWithoutRunAt(t *testing.T) {
+	m := NewQueueManager(10)
+	tm := NewTimer(testLogger(t), m, testStore(t, m))
+
+	err := tm.Schedule(&structs.Job{ID: 1})
+	assert.Error(t, err)
+}
+
+func TestTimer_Remove(t *testing.T) {
+	m := NewQueueManager(10)
+	store := testStore(t, m)
+	tm := NewTimer(testLogger(t), m, store)
+
+	runAt := time.Now().UTC().Add(time.Hour)
+	job := &structs.Job{
+		ID:    1,
+		RunAt: &runAt,
+	}
+	err := tm.Schedule(job)
+	assert.NoError(t, err)
+
+	removed, err := tm.Remove(1)
+	assert.NoError(t, err)
+	assert.True(t, removed)
+	assert.Equal(t, 0, tm.NumJobs())
+	assert.Equal(t, 0, m.NumJobsScheduled())
+
+	// the job is already removed
+	removed, err = tm.Remove(1)
+	assert.NoError(t, err)
+	assert.False(t, removed)
+}
+
+func TestTimer_Restore(t *testing.T) {
+	m := NewQueueManager(10)
+	store := testStore(t, m)
+	tm := NewTimer(testLogger(t), m, store)
+
+	for i := uint64(1); i <= 3; i++ {
+		runAt := time.Now().UTC().Add(time.Duration(i) * time.Hour).Truncate(time.Millisecond)
+		job := &structs.Job{
+			ID:    i,
+			RunAt: &runAt,
+		}
+		err := store.CreateJob(job)
+		assert.NoError(t, err)
+		err = tm.Schedule(job)
+		assert.NoError(t, err)
+	}
+
+	// restore the timer entries as if the server restarted
+	m2 := NewQueueManager(10)
+	tm2 := NewTimer(testLogger(t), m2, store)
+	err := tm2.Restore()
+	assert.NoError(t, err)
+
+	assert.Equal(t, 3, tm2.NumJobs())
+	assert.Equal(t, 3, m2.NumJobsScheduled())
+	assert.Equal(t, uint64(1), tm2.entries[0].Job.ID)
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kohkimakimoto/hq/internal/structs"
+)
+
+// DefaultWaitTimeout is the time to wait for a job to finish when the client does not specify it.
+var DefaultWaitTimeout = 60 * time.Second
+
+// MaxWaitTimeout is the max time to wait for a job to finish.
+var MaxWaitTimeout = 10 * time.Minute
+
+// JobWaiters notifies the clients that are waiting for the jobs to finish.
+type JobWaiters struct {
+	mutex   sync.Mutex
+	waiters map[uint64]map[chan struct{}]struct{}
+}
+
+func NewJobWaiters() *JobWaiters {
+	return &JobWaiters{
+		waiters: map[uint64]map[chan struct{}]struct{}{},
+	}
+}
+
+// Add registers a waiter for the job. The returned channel is closed when the job finishes.
+// The returned function removes the waiter and must be called after waiting.
+func (w *JobWaiters) Add(id uint64) (<-chan struct{}, func()) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	ch := make(chan struct{})
+	if w.waiters[id] == nil {
+		w.waiters[id] = map[chan struct{}]struct{}{}
+	}
+	w.waiters[id][ch] = struct{}{}
+
+	return ch, func() {
+		w.mutex.Lock()
+		defer w.mutex.Unlock()
+
+		if chs, ok := w.waiters[id]; ok {
+			delete(chs, ch)
+			if len(chs) == 0 {
+				delete(w.waiters, id)
+			}
+		}
+	}
+}
+
+// Notify wakes up all the waiters of the job.
+func (w *JobWaiters) Notify(id uint64) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	for ch := range w.waiters[id] {
+		close(ch)
+	}
+	delete(w.waiters, id)
+}
+
+// Len returns the number of the jobs that have waiters.
+func (w *JobWaiters) Len() int {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return len(w.waiters)
+}
+
+// waitJob blocks until the job finishes or the context is done, and returns the latest job.
+func (a *App) waitJob(ctx context.Context, id uint64) (*structs.Job, error) {
+	// The waiter is added before loading the job, so that the finish between them is not missed.
+	ch, remove := a.JobWaiters.Add(id)
+	defer remove()
+
+	job, err := a.Store.GetJob(id)
+	if err != nil {
+		return nil, err
+	}
+	if job.FinishedAt != nil {
+		return job, nil
+	}
+
+	select {
+	case <-ch:
+	case <-ctx.Done():
+	}
+
+	return a.Store.GetJob(id)
+}
+
+// parseWaitTimeout parses the time to wait like "60s" or "60" (seconds) that is passed by the parameter of the name.
+func parseWaitTimeout(name string, s string) (time.Duration, error) {
+	if s == "" {
+		return DefaultWaitTimeout, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		sec, e := strconv.ParseInt(s, 10, 64)
+		if e != nil {
+			return 0, fmt.Errorf("'%s' must be a duration like '60s' but '%s'", name, s)
+		}
+		d = time.Duration(sec) * time.Second
+	}
+
+	if d <= 0 {
+		return 0, fmt.Errorf("'%s' must be greater than 0", name)
+	}
+	if d > MaxWaitTimeout {
+		return 0, fmt.Errorf("'%s' must be less than or equal to %v", name, MaxWaitTimeout)
+	}
+	return d, nil
+}
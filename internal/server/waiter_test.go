@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kohkimakimoto/hq/internal/structs"
+)
+
+func TestJobWaiters(t *testing.T) {
+	w := NewJobWaiters()
+
+	ch1, remove1 := w.Add(1)
+	ch2, remove2 := w.Add(1)
+	_, remove3 := w.Add(2)
+	assert.Equal(t, 2, w.Len())
+
+	w.Notify(1)
+	select {
+	case <-ch1:
+	default:
+		t.Fatal("the waiter was not notified")
+	}
+	select {
+	case <-ch2:
+	default:
+		t.Fatal("the waiter was not notified")
+	}
+
+	// removing the notified waiter is safe.
+	remove1()
+	remove2()
+	remove3()
+	assert.Equal(t, 0, w.Len())
+}
+
+func TestApp_WaitJob(t *testing.T) {
+	testInitApp(t)
+
+	job := &structs.Job{ID: 1, URL: "http://example.com"}
+	assert.NoError(t, g.Store.CreateJob(job))
+	assert.NoError(t, g.QueueManager.Enqueue(job))
+
+	// the job does not finish within the timeout.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	ret, err := g.waitJob(ctx, 1)
+	assert.NoError(t, err)
+	assert.Nil(t, ret.FinishedAt)
+	assert.Equal(t, structs.JobStatusWaiting, ret.Status())
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		testFinishJob(t, job, true)
+	}()
+
+	ctx, cancel = context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	ret, err = g.waitJob(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, structs.JobStatusSuccess, ret.Status())
+	assert.Equal(t, 0, g.JobWaiters.Len())
+
+	// the finished job is returned immediately.
+	ret, err = g.waitJob(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, structs.JobStatusSuccess, ret.Status())
+}
+
+func TestParseWaitTimeout(t *testing.T) {
+	d, err := parseWaitTimeout("timeout", "")
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultWaitTimeout, d)
+
+	d, err = parseWaitTimeout("timeout", "90s")
+	assert.NoError(t, err)
+	assert.Equal(t, 90*time.Second, d)
+
+	d, err = parseWaitTimeout("timeout", "30")
+	assert.NoError(t, err)
+	assert.Equal(t, 30*time.Second, d)
+
+	_, err = parseWaitTimeout("timeout", "0s")
+	assert.Error(t, err)
+
+	_, err = parseWaitTimeout("timeout", "1h")
+	assert.Error(t, err)
+
+	_, err = parseWaitTimeout("timeout", "abc")
+	assert.Error(t, err)
+}
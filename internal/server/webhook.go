@@ -0,0 +1,203 @@
+package server
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kohkimakimoto/hq/internal/structs"
+)
+
+// WebhookTimeout is the timeout in seconds of the request to a webhook url.
+var WebhookTimeout int64 = 30
+
+// WebhookSignatureHeader is the header to send the HMAC-SHA256 signature of the webhook body.
+const WebhookSignatureHeader = "X-Hq-Signature"
+
+// webhookURLs returns the urls to notify of the finished job.
+func (a *App) webhookURLs(job *structs.Job) []string {
+	urls := []string{}
+	if job.NotifyURL != "" {
+		urls = append(urls, job.NotifyURL)
+	}
+	for _, u := range a.config().Webhook.URLs {
+		if u != job.NotifyURL {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// notifyFinishedJob starts delivering the finished job to its notifyUrl and the webhook urls of the config.
+func (a *App) notifyFinishedJob(job *structs.Job) {
+	logger := a.Echo.Logger
+
+	urls := a.webhookURLs(job)
+	if len(urls) == 0 || job.FinishedAt == nil {
+		return
+	}
+
+	deliveries := make([]*structs.Delivery, 0, len(urls))
+	for _, u := range urls {
+		deliveries = append(deliveries, &structs.Delivery{
+			URL:    u,
+			Status: structs.DeliveryStatusPending,
+		})
+	}
+
+	if err := a.Store.PutDeliveries(job, deliveries); err != nil {
+		logger.Error(errors.Wrapf(err, "failed to store the webhook deliveries of the job %d", job.ID))
+		return
+	}
+
+	a.startDeliveries(job, deliveries)
+}
+
+// resumeDeliveries resumes the pending webhook deliveries that were left by the previous process.
+func (a *App) resumeDeliveries() error {
+	ids, err := a.Store.ListPendingDeliveries()
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		job, err := a.Store.GetJob(id)
+		if err != nil {
+			return err
+		}
+		// The deliveries of the restarted job are not loaded.
+		if len(job.Deliveries) == 0 {
+			continue
+		}
+		a.startDeliveries(job, job.Deliveries)
+	}
+	return nil
+}
+
+// startDeliveries sends the pending deliveries of the finished job in the background.
+func (a *App) startDeliveries(job *structs.Job, deliveries []*structs.Delivery) {
+	id := job.ID
+	finishedAt := *job.FinishedAt
+	for i, d := range deliveries {
+		if d.Status != structs.DeliveryStatusPending {
+			continue
+		}
+
+		a.webhookWg.Add(1)
+		go func(i int, d *structs.Delivery) {
+			defer a.webhookWg.Done()
+			a.deliver(id, finishedAt, i, d)
+		}(i, d)
+	}
+}
+
+// deliver sends the finished job to the url of the delivery until it succeeds or the retries are exhausted.
+// The retries are delayed exponentially from the retry_delay of the config.
+func (a *App) deliver(id uint64, finishedAt time.Time, i int, delivery *structs.Delivery) {
+	logger := a.Echo.Logger
+
+	for {
+		job, err := a.Store.GetJob(id)
+		if err != nil {
+			if _, ok := err.(*ErrJobNotFound); !ok {
+				logger.Error(err)
+			}
+			return
+		}
+		if job.FinishedAt == nil || !job.FinishedAt.Equal(finishedAt) {
+			// The job was restarted.
+			return
+		}
+
+		c := a.config().Webhook
+		statusCode, err := a.sendWebhook(delivery.URL, c.Secret, job, delivery.Attempts+1)
+
+		// Truncate millisecond. It is compatible time for katsubushi ID generator timestamp.
+		now := time.Now().UTC().Truncate(time.Millisecond)
+		delivery.Attempts++
+		delivery.StatusCode = statusCode
+		delivery.UpdatedAt = &now
+		if err == nil {
+			delivery.Status = structs.DeliveryStatusDelivered
+			delivery.Err = ""
+		} else {
+			delivery.Err = err.Error()
+			if delivery.Attempts > c.MaxRetries {
+				delivery.Status = structs.DeliveryStatusFailed
+				logger.Error(errors.Wrapf(err, "failed to deliver the job %d to %s", id, delivery.URL))
+			}
+		}
+
+		updated, e := a.Store.UpdateDelivery(job, i, delivery)
+		if e != nil {
+			logger.Error(e)
+			return
+		}
+		if !updated || delivery.Status != structs.DeliveryStatusPending {
+			// The job was deleted or restarted, or the delivery finished.
+			return
+		}
+
+		delay := time.Duration(c.RetryDelay) * time.Second * time.Duration(1<<uint(delivery.Attempts-1))
+		select {
+		case <-time.After(delay):
+		case <-a.webhookStopCh:
+			// The pending delivery is resumed on the next startup.
+			return
+		}
+	}
+}
+
+// sendWebhook posts the job to the url and returns the status code of the response.
+func (a *App) sendWebhook(rawurl string, secret string, job *structs.Job, attempt int) (*int, error) {
+	// The deliveries are not sent, so that the body is the same on every attempt.
+	out := *job
+	out.Deliveries = nil
+	body, err := json.Marshal(&out)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode the job")
+	}
+
+	req, err := http.NewRequest("POST", rawurl, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create new request")
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("User-Agent", WorkerDefaultUserAgent)
+	req.Header.Add("X-Hq-Job-Id", fmt.Sprintf("%d", job.ID))
+	req.Header.Add("X-Hq-Delivery-Attempt", fmt.Sprintf("%d", attempt))
+	if secret != "" {
+		req.Header.Add(WebhookSignatureHeader, signWebhook(secret, body))
+	}
+
+	client := a.httpClientFactory()
+	client.Timeout = time.Duration(WebhookTimeout) * time.Second
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to do http request")
+	}
+	defer resp.Body.Close()
+
+	statusCode := resp.StatusCode
+	if statusCode < 200 || statusCode > 299 {
+		return &statusCode, fmt.Errorf("unexpected status code %d", statusCode)
+	}
+
+	return &statusCode, nil
+}
+
+// signWebhook returns the signature of the body like "sha256=<hex>".
+func signWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kohkimakimoto/hq/internal/structs"
+)
+
+func TestSignWebhook(t *testing.T) {
+	// echo -n 'hello' | openssl dgst -sha256 -hmac 'secret'
+	assert.Equal(t, "sha256=88aab3ede8d3adf94d26ab90d3bafd4a2083070c3bcce9c014ee04a443847c0b", signWebhook("secret", []byte("hello")))
+}
+
+func TestApp_NotifyFinishedJob(t *testing.T) {
+	testInitApp(t)
+
+	g.Config.Webhook = &WebhookConfig{
+		URLs:       []string{"http://example.com/all"},
+		Secret:     "secret",
+		MaxRetries: 1,
+		RetryDelay: 0,
+	}
+
+	var mutex sync.Mutex
+	attempts := map[string]int{}
+	g.httpClientFactory = func() *http.Client {
+		return testHttpClient(t, func(req *http.Request) *http.Response {
+			body, _ := ioutil.ReadAll(req.Body)
+			assert.Equal(t, signWebhook("secret", body), req.Header.Get(WebhookSignatureHeader))
+			assert.Equal(t, "1", req.Header.Get("X-Hq-Job-Id"))
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			attempts[req.URL.String()]++
+
+			statusCode := http.StatusOK
+			if req.URL.String() == "http://example.com/all" || attempts[req.URL.String()] == 1 {
+				// the subscriber is down, and the first attempt to the job's url fails.
+				statusCode = http.StatusInternalServerError
+			}
+			return &http.Response{StatusCode: statusCode, Body: ioutil.NopCloser(bytes.NewBufferString(""))}
+		})
+	}
+
+	job := &structs.Job{ID: 1, URL: "http://example.com", NotifyURL: "http://example.com/notify"}
+	assert.NoError(t, g.Store.CreateJob(job))
+	assert.NoError(t, g.QueueManager.Enqueue(job))
+	testFinishJob(t, job, true)
+
+	done := make(chan struct{})
+	go func() {
+		g.webhookWg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("the webhooks were not delivered")
+	}
+
+	ret, err := g.Store.GetJob(1)
+	assert.NoError(t, err)
+	assert.Len(t, ret.Deliveries, 2)
+
+	assert.Equal(t, "http://example.com/notify", ret.Deliveries[0].URL)
+	assert.Equal(t, structs.DeliveryStatusDelivered, ret.Deliveries[0].Status)
+	assert.Equal(t, 2, ret.Deliveries[0].Attempts)
+	assert.Equal(t, http.StatusOK, *ret.Deliveries[0].StatusCode)
+
+	assert.Equal(t, "http://example.com/all", ret.Deliveries[1].URL)
+	assert.Equal(t, structs.DeliveryStatusFailed, ret.Deliveries[1].Status)
+	assert.Equal(t, 2, ret.Deliveries[1].Attempts)
+	assert.Equal(t, "unexpected status code 500", ret.Deliveries[1].Err)
+}
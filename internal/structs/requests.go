@@ -3,12 +3,31 @@ package structs
 import "encoding/json"
 
 type PushJobRequest struct {
-	Name    string            `json:"name" form:"name" query:"name"`
-	Comment string            `json:"comment" form:"comment" query:"comment"`
-	URL     string            `json:"url" form:"url" query:"url"`
-	Payload json.RawMessage   `json:"payload" form:"payload" query:"payload"`
-	Headers map[string]string `json:"headers" form:"headers" query:"headers"`
-	Timeout int64             `json:"timeout" form:"timeout" query:"timeout"`
+	Name               string            `json:"name" form:"name" query:"name"`
+	Comment            string            `json:"comment" form:"comment" query:"comment"`
+	URL                string            `json:"url" form:"url" query:"url"`
+	Payload            json.RawMessage   `json:"payload" form:"payload" query:"payload"`
+	Headers            map[string]string `json:"headers" form:"headers" query:"headers"`
+	Timeout            int64             `json:"timeout" form:"timeout" query:"timeout"`
+	CancelURL          string            `json:"cancelUrl" form:"cancelUrl" query:"cancelUrl"`
+	NotifyURL          string            `json:"notifyUrl" form:"notifyUrl" query:"notifyUrl"`
+	Async              bool              `json:"async" form:"async" query:"async"`
+	Queue              string            `json:"queue" form:"queue" query:"queue"`
+	Priority           int               `json:"priority" form:"priority" query:"priority"`
+	DependsOn          JobIDs            `json:"dependsOn" form:"dependsOn" query:"dependsOn"`
+	RunOnParentFailure bool              `json:"runOnParentFailure" form:"runOnParentFailure" query:"runOnParentFailure"`
+	UniqueKey          string            `json:"uniqueKey" form:"uniqueKey" query:"uniqueKey"`
+	UniqueFor          int64             `json:"uniqueFor" form:"uniqueFor" query:"uniqueFor"`
+	ConcurrencyKey     string            `json:"concurrencyKey" form:"concurrencyKey" query:"concurrencyKey"`
+	ConcurrencyLimit   int               `json:"concurrencyLimit" form:"concurrencyLimit" query:"concurrencyLimit"`
+	MaxRetries         int               `json:"maxRetries" form:"maxRetries" query:"maxRetries"`
+	RetryDelay         int64             `json:"retryDelay" form:"retryDelay" query:"retryDelay"`
+	RetryBackoff       float64           `json:"retryBackoff" form:"retryBackoff" query:"retryBackoff"`
+	StatusPolicy       *StatusPolicy     `json:"statusPolicy" form:"statusPolicy" query:"statusPolicy"`
+	RunAt              string            `json:"runAt" form:"runAt" query:"runAt"`
+	Delay              int64             `json:"delay" form:"delay" query:"delay"`
+	ExpiresAt          string            `json:"expiresAt" form:"expiresAt" query:"expiresAt"`
+	TTL                int64             `json:"ttl" form:"ttl" query:"ttl"`
 }
 
 type ListJobsRequest struct {
@@ -23,3 +42,54 @@ type ListJobsRequest struct {
 type RestartJobRequest struct {
 	Copy bool `json:"copy" form:"copy" query:"copy"`
 }
+
+type CreateScheduleRequest struct {
+	Name     string       `json:"name" form:"name" query:"name"`
+	Cron     string       `json:"cron" form:"cron" query:"cron"`
+	Timezone string       `json:"timezone" form:"timezone" query:"timezone"`
+	Overlap  string       `json:"overlap" form:"overlap" query:"overlap"`
+	CatchUp  string       `json:"catchUp" form:"catchUp" query:"catchUp"`
+	Paused   bool         `json:"paused" form:"paused" query:"paused"`
+	Job      *JobTemplate `json:"job" form:"job" query:"job"`
+}
+
+type CreateBatchRequest struct {
+	Jobs       []*PushJobRequest `json:"jobs" form:"jobs" query:"jobs"`
+	OnComplete string            `json:"onComplete" form:"onComplete" query:"onComplete"`
+}
+
+type PauseRequest struct {
+	Name string `json:"name" form:"name" query:"name"`
+}
+
+type DrainRequest struct {
+	Wait int64 `json:"wait" form:"wait" query:"wait"`
+}
+
+type ProgressJobRequest struct {
+	Percent *int     `json:"percent" form:"percent" query:"percent"`
+	Message *string  `json:"message" form:"message" query:"message"`
+	Log     []string `json:"log" form:"log" query:"log"`
+}
+
+type WaitJobRequest struct {
+	Timeout string `query:"timeout"`
+}
+
+type EventsRequest struct {
+	Name   string `query:"name"`
+	Status string `query:"status"`
+	ID     uint64 `query:"id"`
+}
+
+type GetJobLogRequest struct {
+	After uint64 `query:"after"`
+	Limit int    `query:"limit"`
+}
+
+type CompleteJobRequest struct {
+	Success    bool   `json:"success" form:"success" query:"success"`
+	StatusCode int    `json:"statusCode" form:"statusCode" query:"statusCode"`
+	Output     string `json:"output" form:"output" query:"output"`
+	Err        string `json:"err" form:"err" query:"err"`
+}
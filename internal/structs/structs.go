@@ -3,6 +3,7 @@ package structs
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"time"
 )
 
@@ -12,47 +13,99 @@ type Info struct {
 }
 
 type Stats struct {
-	Queues              int64 `json:"queues"`
-	Dispatchers         int64 `json:"dispatchers"`
-	MaxWorkers          int64 `json:"maxWorkers"`
-	NumWorkers          int64 `json:"numWorkers"`
-	NumJobsInQueue      int   `json:"numJobsInQueue"`
-	NumJobsWaiting      int   `json:"numJobsWaiting"`
-	NumJobsRunning      int   `json:"numJobsRunning"`
-	NumStoredJobs       int   `json:"numStoredJobs"`
-	NumJobsInLastMinute int   `json:"numJobsInLastMinute"`
+	Queues                   int64                  `json:"queues"`
+	Dispatchers              int64                  `json:"dispatchers"`
+	MaxWorkers               int64                  `json:"maxWorkers"`
+	NumWorkers               int64                  `json:"numWorkers"`
+	NumJobsInQueue           int                    `json:"numJobsInQueue"`
+	NumJobsWaiting           int                    `json:"numJobsWaiting"`
+	NumJobsRunning           int                    `json:"numJobsRunning"`
+	NumJobsRunningAsync      int                    `json:"numJobsRunningAsync"`
+	NumJobsScheduled         int                    `json:"numJobsScheduled"`
+	NumJobsBlocked           int                    `json:"numJobsBlocked"`
+	NumStoredJobs            int                    `json:"numStoredJobs"`
+	NumJobsInLastMinute      int                    `json:"numJobsInLastMinute"`
+	NumJobsWaitingByPriority map[int]int            `json:"numJobsWaitingByPriority"`
+	NamedQueues              map[string]*QueueStats `json:"namedQueues"`
+	NumBreakersOpen          int                    `json:"numBreakersOpen"`
+	Paused                   bool                   `json:"paused"`
+	PausedNames              []string               `json:"pausedNames"`
+	Draining                 bool                   `json:"draining"`
+}
+
+// QueueStats is the statistics of a named queue.
+type QueueStats struct {
+	Concurrency    int64 `json:"concurrency"`
+	NumWorkers     int64 `json:"numWorkers"`
+	NumJobsInQueue int   `json:"numJobsInQueue"`
+	NumJobsWaiting int   `json:"numJobsWaiting"`
+	NumJobsRunning int   `json:"numJobsRunning"`
 }
 
 type Job struct {
-	ID         uint64            `json:"id,string"`
-	Name       string            `json:"name"`
-	Comment    string            `json:"comment"`
-	URL        string            `json:"url"`
-	Payload    json.RawMessage   `json:"payload"`
-	Headers    map[string]string `json:"headers"`
-	Timeout    int64             `json:"timeout"`
-	CreatedAt  time.Time         `json:"createdAt"`
-	StartedAt  *time.Time        `json:"startedAt"`
-	FinishedAt *time.Time        `json:"finishedAt"`
-	Failure    bool              `json:"failure"`
-	Success    bool              `json:"success"`
-	Canceled   bool              `json:"canceled"`
-	StatusCode *int              `json:"statusCode"`
-	Err        string            `json:"err"`
-	Output     string            `json:"output"`
-	Waiting    bool              `json:"waiting"`
-	Running    bool              `json:"running"`
+	ID                 uint64            `json:"id,string"`
+	Name               string            `json:"name"`
+	Comment            string            `json:"comment"`
+	URL                string            `json:"url"`
+	Payload            json.RawMessage   `json:"payload"`
+	Headers            map[string]string `json:"headers"`
+	Timeout            int64             `json:"timeout"`
+	CancelURL          string            `json:"cancelUrl"`
+	NotifyURL          string            `json:"notifyUrl"`
+	Async              bool              `json:"async"`
+	CallbackToken      string            `json:"-"`
+	Queue              string            `json:"queue"`
+	Priority           int               `json:"priority"`
+	DependsOn          JobIDs            `json:"dependsOn"`
+	RunOnParentFailure bool              `json:"runOnParentFailure"`
+	Children           JobIDs            `json:"children"`
+	BatchID            uint64            `json:"batchId,string"`
+	UniqueKey          string            `json:"uniqueKey"`
+	UniqueFor          int64             `json:"uniqueFor"`
+	ConcurrencyKey     string            `json:"concurrencyKey"`
+	ConcurrencyLimit   int               `json:"concurrencyLimit"`
+	MaxRetries         int               `json:"maxRetries"`
+	RetryDelay         int64             `json:"retryDelay"`
+	RetryBackoff       float64           `json:"retryBackoff"`
+	StatusPolicy       *StatusPolicy     `json:"statusPolicy"`
+	Attempt            int               `json:"attempt"`
+	RunAt              *time.Time        `json:"runAt"`
+	ExpiresAt          *time.Time        `json:"expiresAt"`
+	CreatedAt          time.Time         `json:"createdAt"`
+	StartedAt          *time.Time        `json:"startedAt"`
+	AcceptedAt         *time.Time        `json:"acceptedAt"`
+	HeartbeatAt        *time.Time        `json:"heartbeatAt"`
+	FinishedAt         *time.Time        `json:"finishedAt"`
+	Failure            bool              `json:"failure"`
+	Success            bool              `json:"success"`
+	Canceled           bool              `json:"canceled"`
+	Expired            bool              `json:"expired"`
+	StatusCode         *int              `json:"statusCode"`
+	Err                string            `json:"err"`
+	Output             string            `json:"output"`
+	Events             []*JobEvent       `json:"events"`
+	Progress           *JobProgress      `json:"progress"`
+	Deliveries         []*Delivery       `json:"deliveries"`
+	Waiting            bool              `json:"waiting"`
+	Running            bool              `json:"running"`
+	RunningAsync       bool              `json:"runningAsync"`
+	Scheduled          bool              `json:"scheduled"`
+	Blocked            bool              `json:"blocked"`
 }
 
 const (
-	JobStatusWaiting    = "waiting"
-	JobStatusRunning    = "running"
-	JobStatusCanceling  = "canceling"
-	JobStatusCanceled   = "canceled"
-	JobStatusFailure    = "failure"
-	JobStatusSuccess    = "success"
-	JobStatusUnfinished = "unfinished"
-	JobStatusUnknown    = "unknown"
+	JobStatusWaiting      = "waiting"
+	JobStatusRunning      = "running"
+	JobStatusRunningAsync = "running-async"
+	JobStatusScheduled    = "scheduled"
+	JobStatusBlocked      = "blocked"
+	JobStatusCanceling    = "canceling"
+	JobStatusCanceled     = "canceled"
+	JobStatusExpired      = "expired"
+	JobStatusFailure      = "failure"
+	JobStatusSuccess      = "success"
+	JobStatusUnfinished   = "unfinished"
+	JobStatusUnknown      = "unknown"
 )
 
 func (j *Job) Status() string {
@@ -62,12 +115,20 @@ func (j *Job) Status() string {
 		} else {
 			return JobStatusRunning
 		}
+	} else if j.RunningAsync {
+		return JobStatusRunningAsync
 	} else if j.Waiting {
 		if j.Canceled {
 			return JobStatusCanceling
 		} else {
 			return JobStatusWaiting
 		}
+	} else if j.Scheduled {
+		return JobStatusScheduled
+	} else if j.Blocked {
+		return JobStatusBlocked
+	} else if j.Expired {
+		return JobStatusExpired
 	} else if j.Failure {
 		return JobStatusFailure
 	} else if j.Success {
@@ -83,29 +144,305 @@ func (j *Job) Status() string {
 
 func (j *Job) MarshalJSON() ([]byte, error) {
 	jobMap := map[string]interface{}{
-		"id":         fmt.Sprintf("%d", j.ID),
-		"name":       j.Name,
-		"comment":    j.Comment,
-		"url":        j.URL,
-		"payload":    j.Payload,
-		"headers":    j.Headers,
-		"timeout":    j.Timeout,
-		"createdAt":  j.CreatedAt,
-		"startedAt":  j.StartedAt,
-		"finishedAt": j.FinishedAt,
-		"failure":    j.Failure,
-		"success":    j.Success,
-		"canceled":   j.Canceled,
-		"statusCode": j.StatusCode,
-		"err":        j.Err,
-		"output":     j.Output,
-		"waiting":    j.Waiting,
-		"running":    j.Running,
-		"status":     j.Status(),
+		"id":                 fmt.Sprintf("%d", j.ID),
+		"name":               j.Name,
+		"comment":            j.Comment,
+		"url":                j.URL,
+		"payload":            j.Payload,
+		"headers":            j.Headers,
+		"timeout":            j.Timeout,
+		"cancelUrl":          j.CancelURL,
+		"notifyUrl":          j.NotifyURL,
+		"async":              j.Async,
+		"queue":              j.Queue,
+		"priority":           j.Priority,
+		"dependsOn":          j.DependsOn,
+		"runOnParentFailure": j.RunOnParentFailure,
+		"children":           j.Children,
+		"batchId":            fmt.Sprintf("%d", j.BatchID),
+		"uniqueKey":          j.UniqueKey,
+		"uniqueFor":          j.UniqueFor,
+		"concurrencyKey":     j.ConcurrencyKey,
+		"concurrencyLimit":   j.ConcurrencyLimit,
+		"maxRetries":         j.MaxRetries,
+		"retryDelay":         j.RetryDelay,
+		"retryBackoff":       j.RetryBackoff,
+		"statusPolicy":       j.StatusPolicy,
+		"attempt":            j.Attempt,
+		"runAt":              j.RunAt,
+		"expiresAt":          j.ExpiresAt,
+		"createdAt":          j.CreatedAt,
+		"startedAt":          j.StartedAt,
+		"acceptedAt":         j.AcceptedAt,
+		"heartbeatAt":        j.HeartbeatAt,
+		"finishedAt":         j.FinishedAt,
+		"failure":            j.Failure,
+		"success":            j.Success,
+		"canceled":           j.Canceled,
+		"expired":            j.Expired,
+		"statusCode":         j.StatusCode,
+		"err":                j.Err,
+		"output":             j.Output,
+		"events":             j.Events,
+		"progress":           j.Progress,
+		"deliveries":         j.Deliveries,
+		"waiting":            j.Waiting,
+		"running":            j.Running,
+		"runningAsync":       j.RunningAsync,
+		"scheduled":          j.Scheduled,
+		"blocked":            j.Blocked,
+		"status":             j.Status(),
 	}
 	return json.Marshal(jobMap)
 }
 
+// JobIDs is a list of job IDs.
+// It is represented as an array of strings in JSON as well as the job ID. It also accepts an array of numbers.
+type JobIDs []uint64
+
+func (ids JobIDs) MarshalJSON() ([]byte, error) {
+	strs := make([]string, 0, len(ids))
+	for _, id := range ids {
+		strs = append(strs, strconv.FormatUint(id, 10))
+	}
+	return json.Marshal(strs)
+}
+
+func (ids *JobIDs) UnmarshalJSON(b []byte) error {
+	values := []json.Number{}
+	if err := json.Unmarshal(b, &values); err != nil {
+		return err
+	}
+
+	ret := make(JobIDs, 0, len(values))
+	for _, v := range values {
+		id, err := strconv.ParseUint(string(v), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid job id '%s'", v)
+		}
+		ret = append(ret, id)
+	}
+	*ids = ret
+	return nil
+}
+
+// JobEvent is a notable thing that happened to a job.
+type JobEvent struct {
+	Time    time.Time `json:"time"`
+	Type    string    `json:"type"`
+	Message string    `json:"message"`
+}
+
+const (
+	JobEventRecovered = "recovered"
+)
+
+// AddEvent appends a new event to the job.
+func (j *Job) AddEvent(typ string, message string) {
+	j.Events = append(j.Events, &JobEvent{
+		Time:    time.Now().UTC().Truncate(time.Millisecond),
+		Type:    typ,
+		Message: message,
+	})
+}
+
+// Event is a lifecycle event of a job that is streamed by GET /events.
+type Event struct {
+	ID   uint64    `json:"id,string"`
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	Job  *Job      `json:"job"`
+}
+
+const (
+	EventTypePushed   = "pushed"
+	EventTypeStarted  = "started"
+	EventTypeProgress = "progress"
+	EventTypeFinished = "finished"
+	EventTypeCanceled = "canceled"
+	EventTypeDeleted  = "deleted"
+)
+
+// JobProgress is the progress of the running attempt that is reported by the worker.
+type JobProgress struct {
+	Attempt   int       `json:"attempt"`
+	Percent   *int      `json:"percent"`
+	Message   string    `json:"message"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// JobLogLine is a line of the log that is appended by the worker.
+type JobLogLine struct {
+	Seq     uint64    `json:"seq,string"`
+	Attempt int       `json:"attempt"`
+	Time    time.Time `json:"time"`
+	Line    string    `json:"line"`
+}
+
+type JobLog struct {
+	ID    uint64        `json:"id,string"`
+	Lines []*JobLogLine `json:"lines"`
+	// Next is the seq to pass as "after" to get the lines that are appended later.
+	Next uint64 `json:"next,string"`
+}
+
+// Delivery is the state of the webhook delivery of the finished job to a url.
+type Delivery struct {
+	URL        string     `json:"url"`
+	Status     string     `json:"status"`
+	Attempts   int        `json:"attempts"`
+	StatusCode *int       `json:"statusCode"`
+	Err        string     `json:"err"`
+	UpdatedAt  *time.Time `json:"updatedAt"`
+}
+
+const (
+	DeliveryStatusPending   = "pending"
+	DeliveryStatusDelivered = "delivered"
+	DeliveryStatusFailed    = "failed"
+)
+
+// StatusPolicy decides how the HTTP status code from a worker application is treated.
+// Each list contains status code patterns like "200", "2xx" or "500-599".
+type StatusPolicy struct {
+	Success   []string `json:"success" toml:"success"`
+	Retryable []string `json:"retryable" toml:"retryable"`
+	Permanent []string `json:"permanent" toml:"permanent"`
+}
+
+// Schedule is a recurring job definition. HQ pushes a new job from the Job template on every tick of the Cron expression.
+type Schedule struct {
+	Name      string       `json:"name"`
+	Cron      string       `json:"cron"`
+	Timezone  string       `json:"timezone"`
+	Overlap   string       `json:"overlap"`
+	CatchUp   string       `json:"catchUp"`
+	Paused    bool         `json:"paused"`
+	Declared  bool         `json:"declared"`
+	Job       *JobTemplate `json:"job"`
+	LastRunAt *time.Time   `json:"lastRunAt"`
+	LastJobID uint64       `json:"lastJobId,string"`
+	NextRunAt *time.Time   `json:"nextRunAt"`
+	CreatedAt time.Time    `json:"createdAt"`
+	UpdatedAt time.Time    `json:"updatedAt"`
+}
+
+const (
+	// ScheduleOverlapSkip skips the run if the previous job is still active.
+	ScheduleOverlapSkip = "skip"
+	// ScheduleOverlapAllow pushes a new job even if the previous job is still active.
+	ScheduleOverlapAllow = "allow"
+	// ScheduleOverlapCancel cancels the previous job if it is still active, and pushes a new job.
+	ScheduleOverlapCancel = "cancel"
+)
+
+const (
+	// ScheduleCatchUpNone does not run the missed runs.
+	ScheduleCatchUpNone = "none"
+	// ScheduleCatchUpOnce runs once for all the missed runs.
+	ScheduleCatchUpOnce = "once"
+	// ScheduleCatchUpAll runs every missed run.
+	ScheduleCatchUpAll = "all"
+)
+
+// JobTemplate is a template of the jobs that are pushed by a schedule.
+type JobTemplate struct {
+	Name             string            `json:"name"`
+	Comment          string            `json:"comment"`
+	URL              string            `json:"url"`
+	Payload          json.RawMessage   `json:"payload"`
+	Headers          map[string]string `json:"headers"`
+	Timeout          int64             `json:"timeout"`
+	CancelURL        string            `json:"cancelUrl"`
+	NotifyURL        string            `json:"notifyUrl"`
+	Async            bool              `json:"async"`
+	Queue            string            `json:"queue"`
+	Priority         int               `json:"priority"`
+	ConcurrencyKey   string            `json:"concurrencyKey"`
+	ConcurrencyLimit int               `json:"concurrencyLimit"`
+	MaxRetries       int               `json:"maxRetries"`
+	RetryDelay       int64             `json:"retryDelay"`
+	RetryBackoff     float64           `json:"retryBackoff"`
+	StatusPolicy     *StatusPolicy     `json:"statusPolicy"`
+	// TTL is the seconds that each job can wait before it expires.
+	TTL int64 `json:"ttl"`
+}
+
+type ScheduleList struct {
+	Schedules []*Schedule `json:"schedules"`
+}
+
+type DeletedSchedule struct {
+	Name string `json:"name"`
+}
+
+// Breaker is the state of the circuit breaker of a worker host.
+type Breaker struct {
+	Host     string     `json:"host"`
+	State    string     `json:"state"`
+	Failures int64      `json:"failures"`
+	OpenedAt *time.Time `json:"openedAt"`
+	RetryAt  *time.Time `json:"retryAt"`
+}
+
+const (
+	// BreakerStateClosed sends the jobs to the host.
+	BreakerStateClosed = "closed"
+	// BreakerStateOpen holds the jobs for the host in the queue until the cooldown passes.
+	BreakerStateOpen = "open"
+	// BreakerStateHalfOpen sends a job to the host as a probe.
+	BreakerStateHalfOpen = "half-open"
+)
+
+type BreakerList struct {
+	Breakers []*Breaker `json:"breakers"`
+}
+
+// Batch is a group of jobs that are pushed at once.
+type Batch struct {
+	ID          uint64     `json:"id,string"`
+	OnComplete  string     `json:"onComplete"`
+	Jobs        JobIDs     `json:"jobs"`
+	NumJobs     int        `json:"numJobs"`
+	NumPending  int        `json:"numPending"`
+	NumRunning  int        `json:"numRunning"`
+	NumSuccess  int        `json:"numSuccess"`
+	NumFailure  int        `json:"numFailure"`
+	NumCanceled int        `json:"numCanceled"`
+	NumExpired  int        `json:"numExpired"`
+	Finished    bool       `json:"finished"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	FinishedAt  *time.Time `json:"finishedAt"`
+}
+
+// PauseState is the state of pausing dispatching the jobs.
+// Paused means dispatching all the jobs is paused. Names are the regular expressions of the paused job names.
+type PauseState struct {
+	Paused bool     `json:"paused"`
+	Names  []string `json:"names"`
+}
+
+// DrainState is the state of the draining mode.
+// Drained means there are no jobs in the queue and no workers are running.
+type DrainState struct {
+	Draining            bool  `json:"draining"`
+	Drained             bool  `json:"drained"`
+	NumJobsInQueue      int   `json:"numJobsInQueue"`
+	NumJobsWaiting      int   `json:"numJobsWaiting"`
+	NumJobsRunning      int   `json:"numJobsRunning"`
+	NumJobsRunningAsync int   `json:"numJobsRunningAsync"`
+	NumJobsScheduled    int   `json:"numJobsScheduled"`
+	NumJobsBlocked      int   `json:"numJobsBlocked"`
+	NumWorkers          int64 `json:"numWorkers"`
+}
+
+// ReloadResult is the result of reloading the config.
+// Applied are the settings that were changed. Rejected are the changed settings that need restarting the server.
+type ReloadResult struct {
+	Applied  []string `json:"applied"`
+	Rejected []string `json:"rejected"`
+}
+
 type DeletedJob struct {
 	ID uint64 `json:"id,string"`
 }
@@ -11,3 +11,9 @@ func TestJob_Status(t *testing.T) {
 	job.Waiting = true
 	assert.Equal(t, JobStatusWaiting, job.Status())
 }
+
+func TestJob_StatusScheduled(t *testing.T) {
+	job := &Job{}
+	job.Scheduled = true
+	assert.Equal(t, JobStatusScheduled, job.Status())
+}
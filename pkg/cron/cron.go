@@ -0,0 +1,216 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed cron expression.
+// Each field is a bit set of the values that match the field.
+type Schedule struct {
+	Minute uint64
+	Hour   uint64
+	Dom    uint64
+	Month  uint64
+	Dow    uint64
+
+	// domStar and dowStar record whether the day fields are '*'.
+	// When both day fields are restricted, a time matches if either field matches (the same as the standard cron).
+	domStar bool
+	dowStar bool
+}
+
+type bounds struct {
+	min   int
+	max   int
+	names map[string]int
+}
+
+var (
+	minuteBounds = bounds{0, 59, nil}
+	hourBounds   = bounds{0, 23, nil}
+	domBounds    = bounds{1, 31, nil}
+	monthBounds  = bounds{1, 12, map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	dowBounds = bounds{0, 7, map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse parses a standard cron expression that has 5 fields (minute, hour, day of month, month and day of week).
+// It also supports the macros like '@daily' and '@hourly'.
+func Parse(spec string) (*Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if m, ok := macros[strings.ToLower(spec)]; ok {
+		spec = m
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields but got %d in '%s'", len(fields), spec)
+	}
+
+	s := &Schedule{}
+	var err error
+	if s.Minute, err = parseField(fields[0], minuteBounds); err != nil {
+		return nil, err
+	}
+	if s.Hour, err = parseField(fields[1], hourBounds); err != nil {
+		return nil, err
+	}
+	if s.Dom, err = parseField(fields[2], domBounds); err != nil {
+		return nil, err
+	}
+	if s.Month, err = parseField(fields[3], monthBounds); err != nil {
+		return nil, err
+	}
+	if s.Dow, err = parseField(fields[4], dowBounds); err != nil {
+		return nil, err
+	}
+	// 7 is also Sunday
+	if s.Dow&(1<<7) != 0 {
+		s.Dow = s.Dow&^(1<<7) | 1
+	}
+	s.domStar = fields[2] == "*" || fields[2] == "?"
+	s.dowStar = fields[4] == "*" || fields[4] == "?"
+
+	return s, nil
+}
+
+// parseField parses a field that is a comma separated list of '*', 'N', 'N-M' with an optional step '/S'.
+func parseField(field string, b bounds) (uint64, error) {
+	var bits uint64
+	for _, expr := range strings.Split(field, ",") {
+		bit, err := parseRange(expr, b)
+		if err != nil {
+			return 0, err
+		}
+		bits |= bit
+	}
+	return bits, nil
+}
+
+func parseRange(expr string, b bounds) (uint64, error) {
+	rangeAndStep := strings.Split(expr, "/")
+	if len(rangeAndStep) > 2 {
+		return 0, fmt.Errorf("invalid expression '%s'", expr)
+	}
+
+	var start, end int
+	step := 1
+	if r := rangeAndStep[0]; r == "*" || r == "?" {
+		start, end = b.min, b.max
+	} else {
+		lowAndHigh := strings.Split(r, "-")
+		if len(lowAndHigh) > 2 {
+			return 0, fmt.Errorf("invalid expression '%s'", expr)
+		}
+
+		var err error
+		if start, err = parseValue(lowAndHigh[0], b); err != nil {
+			return 0, err
+		}
+		end = start
+		if len(lowAndHigh) == 2 {
+			if end, err = parseValue(lowAndHigh[1], b); err != nil {
+				return 0, err
+			}
+		} else if len(rangeAndStep) == 2 {
+			// 'N/S' means from N to the max.
+			end = b.max
+		}
+	}
+
+	if len(rangeAndStep) == 2 {
+		var err error
+		if step, err = strconv.Atoi(rangeAndStep[1]); err != nil || step <= 0 {
+			return 0, fmt.Errorf("invalid step in '%s'", expr)
+		}
+	}
+
+	if start < b.min || end > b.max || start > end {
+		return 0, fmt.Errorf("'%s' is out of range (%d-%d)", expr, b.min, b.max)
+	}
+
+	var bits uint64
+	for i := start; i <= end; i += step {
+		bits |= 1 << uint(i)
+	}
+	return bits, nil
+}
+
+func parseValue(v string, b bounds) (int, error) {
+	if n, ok := b.names[strings.ToLower(v)]; ok {
+		return n, nil
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value '%s'", v)
+	}
+	return n, nil
+}
+
+// Next returns the next time that matches the schedule after the t.
+// The returned time is in the location of the t.
+// It returns the zero time if no time matches within 5 years (e.g. '0 0 30 2 *').
+func (s *Schedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Add(time.Minute - time.Duration(t.Second())*time.Second - time.Duration(t.Nanosecond()))
+
+	yearLimit := t.Year() + 5
+	for t.Year() <= yearLimit {
+		if s.Month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+
+		if s.Hour&(1<<uint(t.Hour())) == 0 {
+			next := time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			if !next.After(t) {
+				// It is possible on the end of daylight saving time.
+				next = t.Add(time.Hour).Truncate(time.Hour)
+			}
+			t = next
+			continue
+		}
+
+		if s.Minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	domMatch := s.Dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.Dow&(1<<uint(t.Weekday())) != 0
+
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
@@ -0,0 +1,78 @@
+package cron
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	for _, spec := range []string{
+		"* * * * *",
+		"*/5 * * * *",
+		"0 3 * * mon-fri",
+		"0,30 9-18 1,15 jan-jun 7",
+		"@daily",
+		"@Hourly",
+	} {
+		_, err := Parse(spec)
+		assert.NoError(t, err, spec)
+	}
+
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"@every",
+	} {
+		_, err := Parse(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestSchedule_Next(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	cases := []struct {
+		spec     string
+		from     time.Time
+		expected time.Time
+	}{
+		{"* * * * *", time.Date(2020, 1, 1, 0, 0, 30, 0, time.UTC), time.Date(2020, 1, 1, 0, 1, 0, 0, time.UTC)},
+		{"* * * * *", time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2020, 1, 1, 0, 1, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2020, 1, 1, 0, 16, 0, 0, time.UTC), time.Date(2020, 1, 1, 0, 30, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2020, 1, 1, 3, 0, 0, 0, time.UTC), time.Date(2020, 1, 2, 3, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2020, 12, 15, 0, 0, 0, 0, time.UTC), time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// 2020-01-04 is Saturday
+		{"0 9 * * mon-fri", time.Date(2020, 1, 4, 0, 0, 0, 0, time.UTC), time.Date(2020, 1, 6, 9, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2020, 1, 4, 0, 0, 0, 0, time.UTC), time.Date(2020, 1, 5, 0, 0, 0, 0, time.UTC)},
+		// either day of month or day of week matches
+		{"0 0 10 * sun", time.Date(2020, 1, 6, 0, 0, 0, 0, time.UTC), time.Date(2020, 1, 10, 0, 0, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2020, 1, 1, 0, 0, 0, 0, tokyo), time.Date(2020, 1, 1, 3, 0, 0, 0, tokyo)},
+	}
+
+	for _, c := range cases {
+		s, err := Parse(c.spec)
+		assert.NoError(t, err)
+		assert.True(t, c.expected.Equal(s.Next(c.from)), "%s: expected %v but got %v", c.spec, c.expected, s.Next(c.from))
+	}
+}
+
+func TestSchedule_NextNeverMatches(t *testing.T) {
+	s, err := Parse("0 0 30 2 *")
+	assert.NoError(t, err)
+	assert.True(t, s.Next(time.Now()).IsZero())
+}
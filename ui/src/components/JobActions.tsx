@@ -65,7 +65,13 @@ export const JobActions = ({ job, onStop, onRestart, onRestartAsNew, onDelete }:
   return (
     <>
       {(() => {
-        if (job.status == 'running' || job.status == 'waiting') {
+        if (
+          job.status == 'running' ||
+          job.status == 'running-async' ||
+          job.status == 'waiting' ||
+          job.status == 'scheduled' ||
+          job.status == 'blocked'
+        ) {
           return (
             <HStack spacing={2}>
               <IconButton
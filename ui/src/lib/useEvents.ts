@@ -0,0 +1,55 @@
+import { useEffect, useRef, useState } from 'react';
+
+import { config } from '@/lib/config';
+
+// The types of the lifecycle events of the jobs that are streamed by GET /events.
+const eventTypes = ['pushed', 'started', 'progress', 'finished', 'canceled', 'deleted'];
+
+// useEvents calls the callback when the jobs change. The calls are throttled by the wait in milliseconds.
+// It returns false while the event stream is not connected, so that the caller can fall back to polling.
+export function useEvents(callback: () => void, wait: number): boolean {
+  const savedCallback = useRef(callback);
+  const [connected, setConnected] = useState(false);
+
+  // Remember the latest callback if it changes.
+  useEffect(() => {
+    savedCallback.current = callback;
+  }, [callback]);
+
+  useEffect(() => {
+    // The browser does not support the event stream.
+    if (typeof EventSource === 'undefined') {
+      return;
+    }
+
+    let timer: ReturnType<typeof setTimeout> | null = null;
+    const handler = () => {
+      if (timer !== null) {
+        return;
+      }
+      timer = setTimeout(() => {
+        timer = null;
+        savedCallback.current();
+      }, wait);
+    };
+
+    // EventSource reconnects automatically, and the stream resumes with the Last-Event-ID.
+    const source = new EventSource(`${config.basename}/internal/events`);
+    source.onopen = () => {
+      setConnected(true);
+      // The jobs may have changed while the stream was disconnected.
+      handler();
+    };
+    source.onerror = () => setConnected(false);
+    eventTypes.forEach((type) => source.addEventListener(type, handler));
+
+    return () => {
+      if (timer !== null) {
+        clearTimeout(timer);
+      }
+      source.close();
+    };
+  }, [wait]);
+
+  return connected;
+}
@@ -3,6 +3,22 @@ import dayjs, { Dayjs } from 'dayjs';
 
 import { Status } from './Status';
 
+export interface JobProgress {
+  attempt: number;
+  percent: number | null;
+  message: string;
+  updatedAt: string;
+}
+
+export interface Delivery {
+  url: string;
+  status: 'pending' | 'delivered' | 'failed';
+  attempts: number;
+  statusCode: number | null;
+  err: string;
+  updatedAt: string | null;
+}
+
 export class Job {
   public id = '';
 
@@ -18,6 +34,32 @@ export class Job {
 
   public timeout = 0;
 
+  public cancelUrl = '';
+
+  public notifyUrl = '';
+
+  public async = false;
+
+  public queue = '';
+
+  public priority = 0;
+
+  public dependsOn: string[] = [];
+
+  public runOnParentFailure = false;
+
+  public children: string[] = [];
+
+  public batchId = '0';
+
+  public uniqueKey = '';
+
+  public uniqueFor = 0;
+
+  public concurrencyKey = '';
+
+  public concurrencyLimit = 0;
+
   @Type(() => Date)
   @Transform(({ value }) => dayjs(value), { toClassOnly: true })
   public createdAt: Dayjs = dayjs();
@@ -30,25 +72,59 @@ export class Job {
   @Transform(({ value }) => (value ? dayjs(value) : null), { toClassOnly: true })
   public finishedAt: Dayjs | null = null;
 
+  @Type(() => Date)
+  @Transform(({ value }) => (value ? dayjs(value) : null), { toClassOnly: true })
+  public runAt: Dayjs | null = null;
+
+  @Type(() => Date)
+  @Transform(({ value }) => (value ? dayjs(value) : null), { toClassOnly: true })
+  public expiresAt: Dayjs | null = null;
+
+  @Type(() => Date)
+  @Transform(({ value }) => (value ? dayjs(value) : null), { toClassOnly: true })
+  public acceptedAt: Dayjs | null = null;
+
+  @Type(() => Date)
+  @Transform(({ value }) => (value ? dayjs(value) : null), { toClassOnly: true })
+  public heartbeatAt: Dayjs | null = null;
+
   public failure = false;
 
   public success = false;
 
   public canceled = false;
 
+  public expired = false;
+
   public statusCode: number | null = null;
 
   public err = '';
 
   public output = '';
 
+  public progress: JobProgress | null = null;
+
+  public deliveries: Delivery[] | null = null;
+
   public waiting = false;
 
   public running = false;
 
+  public runningAsync = false;
+
+  public scheduled = false;
+
+  public blocked = false;
+
   public status: Status = 'unknown';
 
   get duration(): string {
+    if (!this.finishedAt && this.startedAt && (this.running || this.runningAsync)) {
+      // the elapsed time and the progress of the running job.
+      const elapsed = dayjs.duration(dayjs().diff(this.startedAt, 'seconds'), 'seconds').humanize();
+      return this.progressPercent !== null ? `${elapsed} (${this.progressPercent}%)` : elapsed;
+    }
+
     if (!this.finishedAt || !this.startedAt) {
       return '';
     }
@@ -56,4 +132,8 @@ export class Job {
     const diff = this.finishedAt.diff(this.startedAt, 'seconds');
     return dayjs.duration(diff, 'seconds').humanize();
   }
+
+  get progressPercent(): number | null {
+    return this.progress && this.progress.percent !== null ? this.progress.percent : null;
+  }
 }
@@ -1,3 +1,11 @@
+export interface QueueStats {
+  concurrency: number;
+  numWorkers: number;
+  numJobsInQueue: number;
+  numJobsWaiting: number;
+  numJobsRunning: number;
+}
+
 export class Stats {
   public queues = 0;
 
@@ -13,6 +21,24 @@ export class Stats {
 
   public numJobsRunning = 0;
 
+  public numJobsRunningAsync = 0;
+
+  public numJobsScheduled = 0;
+
+  public numJobsBlocked = 0;
+
+  public numJobsWaitingByPriority: { [priority: string]: number } = {};
+
+  public namedQueues: { [name: string]: QueueStats } = {};
+
+  public numBreakersOpen = 0;
+
+  public paused = false;
+
+  public pausedNames: string[] = [];
+
+  public draining = false;
+
   public numStoredJobs = 0;
 
   public numJobsInLastMinute = 0;
@@ -1,6 +1,6 @@
 import { useColorMode } from '@chakra-ui/react';
 
-export type Status = 'failure' | 'success' | 'running' | 'waiting' | 'canceled' | 'canceling' | 'unfinished' | 'unknown';
+export type Status = 'failure' | 'success' | 'running' | 'running-async' | 'waiting' | 'scheduled' | 'blocked' | 'canceled' | 'expired' | 'canceling' | 'unfinished' | 'unknown';
 
 export type StatusColors = {
   [key in Status]: string;
@@ -13,8 +13,12 @@ export const useStatusColors = (): StatusColors => {
       success: 'green.500',
       failure: 'red.500',
       running: 'blue.500',
+      'running-async': 'blue.500',
       waiting: 'gray.500',
+      scheduled: 'purple.500',
+      blocked: 'orange.500',
       canceled: 'gray.500',
+      expired: 'gray.500',
       canceling: 'gray.500',
       unfinished: 'gray.500',
       unknown: 'gray.500',
@@ -24,8 +28,12 @@ export const useStatusColors = (): StatusColors => {
       success: 'green.500',
       failure: 'red.500',
       running: 'blue.500',
+      'running-async': 'blue.500',
       waiting: 'gray.500',
+      scheduled: 'purple.500',
+      blocked: 'orange.500',
       canceled: 'gray.500',
+      expired: 'gray.500',
       canceling: 'gray.500',
       unfinished: 'gray.500',
       unknown: 'gray.500',
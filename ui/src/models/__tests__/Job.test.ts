@@ -23,3 +23,20 @@ test('plainToInstance', () => {
   expect(job.createdAt.format('YYYY-MM-DD')).toBe('2021-12-18');
   expect(job.failure).toBe(true);
 });
+
+test('duration of the running job', () => {
+  const job = plainToInstance<Job, any>(Job, {
+    id: '1',
+    startedAt: new Date().toISOString(),
+    running: true,
+    progress: {
+      attempt: 1,
+      percent: 45,
+      message: 'uploading',
+      updatedAt: new Date().toISOString(),
+    },
+  });
+
+  expect(job.progressPercent).toBe(45);
+  expect(job.duration).toMatch(/\(45%\)$/);
+});
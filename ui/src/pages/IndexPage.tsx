@@ -35,6 +35,7 @@ import { useQueryParam, StringParam } from 'use-query-params';
 import { useGetDashboard } from '@/api/api';
 import { JobActions } from '@/components/JobActions';
 import { JobListContainer } from '@/components/JobListContainer';
+import { useEvents } from '@/lib/useEvents';
 import { useInterval } from '@/lib/useInterval';
 import { useStatusColors } from '@/models/Status';
 
@@ -50,7 +51,9 @@ export const IndexPage = () => {
   const [term, setTerm] = useQueryParam('term', StringParam);
   const [{ dashboard }, executeGetDashboard] = useGetDashboard({ term: term, reverse: true });
 
-  useInterval(executeGetDashboard, 2000);
+  // The dashboard is refreshed by the events. It is also polled for the stats that change without the events.
+  const connected = useEvents(executeGetDashboard, 500);
+  useInterval(executeGetDashboard, connected ? 10000 : 2000);
 
   if (!dashboard) {
     return (
@@ -155,7 +158,7 @@ export const IndexPage = () => {
                   <Td>
                     <HStack spacing={2}>
                       {(() => {
-                        if (job.status == 'running') {
+                        if (job.status == 'running' || job.status == 'running-async') {
                           return <Spinner size="xs" color={statusColors[job.status]} />;
                         }
                       })()}
@@ -113,6 +113,15 @@ export const JobDetailPage = () => {
           </Heading>
           <Text>{job.finishedAt ? job.finishedAt.format('YYYY-MM-DD HH:mm:ss') : ''}</Text>
         </Box>
+        <Box>
+          <Heading as="h2" size="sm">
+            Progress
+          </Heading>
+          <Text>
+            {job.progressPercent !== null ? `${job.progressPercent}% ` : ''}
+            {job.progress ? job.progress.message : ''}
+          </Text>
+        </Box>
         <Box>
           <Heading as="h2" size="sm">
             Status Code